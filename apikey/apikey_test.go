@@ -0,0 +1,107 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grayscalecloud/hertzcommon/kvconfig"
+)
+
+// fakeSource 内存配置中心，PublishConfig 会同步通知监听者
+type fakeSource struct {
+	mu        sync.Mutex
+	content   map[string]string
+	listeners map[string][]func(string)
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{content: map[string]string{}, listeners: map[string][]func(string){}}
+}
+
+func (f *fakeSource) GetKvConfig(dataId, group string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.content[group+"/"+dataId], nil
+}
+
+func (f *fakeSource) PublishConfig(dataId, group, content string) error {
+	f.mu.Lock()
+	f.content[group+"/"+dataId] = content
+	listeners := f.listeners[group+"/"+dataId]
+	f.mu.Unlock()
+	for _, l := range listeners {
+		l(content)
+	}
+	return nil
+}
+
+func (f *fakeSource) ListenConfig(dataId, group string, callback func(string)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listeners[group+"/"+dataId] = append(f.listeners[group+"/"+dataId], callback)
+	return nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	raw, key, err := Generate(Key{Name: "erp", TenantID: "t1", Scopes: []string{"order:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw, keyPrefix+key.ID+"_") || key.Hash == Hash(raw+"x") {
+		t.Fatalf("生成的密钥格式错误: %s", raw)
+	}
+	expired := &Key{ID: "old", Hash: Hash("old"), ExpiresAt: time.Now().Add(-time.Minute)}
+	disabled := &Key{ID: "off", Hash: Hash("off"), Disabled: true}
+	store := NewMemoryStore(key, expired, disabled)
+
+	got, err := Authenticate(ctx, store, raw)
+	if err != nil || got.TenantID != "t1" {
+		t.Fatalf("Authenticate() = %+v, %v", got, err)
+	}
+	for raw, want := range map[string]error{"": ErrKeyNotFound, "hk_unknown": ErrKeyNotFound, "old": ErrKeyExpired, "off": ErrKeyDisabled} {
+		if _, err := Authenticate(ctx, store, raw); !errors.Is(err, want) {
+			t.Errorf("Authenticate(%q) err = %v, want %v", raw, err, want)
+		}
+	}
+}
+
+func TestKVStore(t *testing.T) {
+	ctx := context.Background()
+	src := newFakeSource()
+	a, err := NewKVStore(src, "DEFAULT_GROUP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewKVStore(src, "DEFAULT_GROUP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, key, _ := Generate(Key{Name: "erp", TenantID: "t1", Scopes: []string{"order:*"}, ExpiresAt: time.Now().Add(time.Hour)})
+	if err := a.Create(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := src.GetKvConfig(kvconfig.ApiKeysDataId, "DEFAULT_GROUP"); strings.Contains(content, raw) {
+		t.Fatal("配置中心不应保存明文密钥")
+	}
+	// 其他实例通过配置推送获得新密钥
+	got, err := Authenticate(ctx, b, raw)
+	if err != nil || got.ID != key.ID || got.Scopes[0] != "order:*" {
+		t.Fatalf("Authenticate() = %+v, %v", got, err)
+	}
+
+	if err := b.Delete(ctx, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Authenticate(ctx, a, raw); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("删除后 err = %v, want %v", err, ErrKeyNotFound)
+	}
+	if err := b.Delete(ctx, key.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("重复删除 err = %v, want %v", err, ErrKeyNotFound)
+	}
+}
@@ -0,0 +1,137 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/kvconfig"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigSource 配置中心读写接口，*kvconfig.ConfigFactory 实现了该接口
+type ConfigSource interface {
+	GetKvConfig(dataId, group string) (string, error)
+	PublishConfig(dataId, group, content string) error
+	ListenConfig(dataId, group string, callback func(string)) error
+}
+
+// keyFile 配置中心中 apikeys 配置的格式
+//
+//	keys:
+//	  - id: 3f2a9c0d1b7e4a65
+//	    name: partner-erp
+//	    hash: 9b74c9897bac770ffc029102a200c5de...
+//	    tenant_id: t1
+//	    scopes: ["order:read"]
+//	    expires_at: 2027-01-01T00:00:00Z
+type keyFile struct {
+	Keys []*Key `yaml:"keys"`
+}
+
+// KVStore 基于配置中心的 API Key 存储，读取走本地索引，配置变化时自动刷新
+type KVStore struct {
+	src   ConfigSource
+	group string
+
+	mu     sync.Mutex // 串行化写入
+	byHash atomic.Pointer[map[string]*Key]
+}
+
+// NewKVStore 从配置中心 apikeys 配置加载密钥并监听变化
+func NewKVStore(src ConfigSource, group string) (*KVStore, error) {
+	s := &KVStore{src: src, group: group}
+	content, err := src.GetKvConfig(kvconfig.ApiKeysDataId, group)
+	if err != nil {
+		return nil, fmt.Errorf("读取 API Key 配置失败: %w", err)
+	}
+	if err := s.load(content); err != nil {
+		return nil, err
+	}
+	err = src.ListenConfig(kvconfig.ApiKeysDataId, group, func(content string) {
+		if err := s.load(content); err != nil {
+			hlog.Errorf("刷新 API Key 配置失败 [group: %s]: %v", group, err)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("监听 API Key 配置失败: %w", err)
+	}
+	return s, nil
+}
+
+func (s *KVStore) Lookup(_ context.Context, hash string) (*Key, error) {
+	if k, ok := (*s.byHash.Load())[hash]; ok {
+		return k, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (s *KVStore) List(_ context.Context) ([]*Key, error) {
+	return sortedKeys(*s.byHash.Load()), nil
+}
+
+func (s *KVStore) Create(_ context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.snapshot()
+	next[key.Hash] = key
+	return s.publish(next)
+}
+
+func (s *KVStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.snapshot()
+	found := false
+	for hash, k := range next {
+		if k.ID == id {
+			delete(next, hash)
+			found = true
+		}
+	}
+	if !found {
+		return ErrKeyNotFound
+	}
+	return s.publish(next)
+}
+
+// load 解析配置内容并替换本地索引
+func (s *KVStore) load(content string) error {
+	var file keyFile
+	if err := yaml.Unmarshal([]byte(content), &file); err != nil {
+		return fmt.Errorf("解析 API Key 配置失败: %w", err)
+	}
+	byHash := make(map[string]*Key, len(file.Keys))
+	for _, k := range file.Keys {
+		if k == nil || k.Hash == "" {
+			continue
+		}
+		byHash[k.Hash] = k
+	}
+	s.byHash.Store(&byHash)
+	return nil
+}
+
+func (s *KVStore) snapshot() map[string]*Key {
+	cur := *s.byHash.Load()
+	next := make(map[string]*Key, len(cur)+1)
+	for hash, k := range cur {
+		next[hash] = k
+	}
+	return next
+}
+
+// publish 把密钥写回配置中心，成功后立即更新本地索引，不等待配置推送
+func (s *KVStore) publish(byHash map[string]*Key) error {
+	content, err := yaml.Marshal(&keyFile{Keys: sortedKeys(byHash)})
+	if err != nil {
+		return fmt.Errorf("序列化 API Key 配置失败: %w", err)
+	}
+	if err := s.src.PublishConfig(kvconfig.ApiKeysDataId, s.group, string(content)); err != nil {
+		return fmt.Errorf("发布 API Key 配置失败: %w", err)
+	}
+	s.byHash.Store(&byHash)
+	return nil
+}
@@ -0,0 +1,66 @@
+package apikey
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryStore 内存 API Key 存储，用于测试与单实例部署
+type MemoryStore struct {
+	mu     sync.RWMutex
+	byHash map[string]*Key
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore(keys ...*Key) *MemoryStore {
+	s := &MemoryStore{byHash: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		s.byHash[k.Hash] = k
+	}
+	return s
+}
+
+func (s *MemoryStore) Lookup(_ context.Context, hash string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if k, ok := s.byHash[hash]; ok {
+		return k, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (s *MemoryStore) List(_ context.Context) ([]*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedKeys(s.byHash), nil
+}
+
+func (s *MemoryStore) Create(_ context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byHash[key.Hash] = key
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, k := range s.byHash {
+		if k.ID == id {
+			delete(s.byHash, hash)
+			return nil
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// sortedKeys 按创建时间排序输出
+func sortedKeys(byHash map[string]*Key) []*Key {
+	out := make([]*Key, 0, len(byHash))
+	for _, k := range byHash {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
@@ -0,0 +1,199 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/grayscalecloud/hertzcommon/hdserver"
+	"github.com/grayscalecloud/hertzcommon/kvconfig"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 拒绝原因
+const (
+	ReasonUnauthenticated   = "unauthenticated"
+	ReasonAppType           = "app_type_not_allowed"
+	ReasonMissingRole       = "missing_role"
+	ReasonMissingPermission = "missing_permission"
+)
+
+// Decision 授权结果
+type Decision struct {
+	Allowed bool
+	Reason  string
+	// Missing 缺少的角色或权限
+	Missing     []string
+	Principal   *Principal
+	Requirement Requirement
+}
+
+// AuditFunc 授权拒绝时的审计回调
+type AuditFunc func(ctx context.Context, c *app.RequestContext, d *Decision)
+
+// Authorizer 根据策略判断请求主体是否满足路由的访问要求，策略可通过 Update 热更新
+type Authorizer struct {
+	policy atomic.Pointer[compiledPolicy]
+	audit  AuditFunc
+}
+
+// NewAuthorizer 创建授权器，audit 为空时只记录日志与 Span
+func NewAuthorizer(p *Policy, audit AuditFunc) *Authorizer {
+	a := &Authorizer{audit: audit}
+	a.policy.Store(compile(p))
+	return a
+}
+
+// NewWatchedAuthorizer 从配置中心读取 authz 策略创建授权器，并在配置变化时热更新
+func NewWatchedAuthorizer(f *kvconfig.ConfigFactory, group string, audit AuditFunc) (*Authorizer, error) {
+	p, err := kvconfig.GetYamlConfig[Policy](f, kvconfig.AuthzDataId, group)
+	if err != nil {
+		return nil, fmt.Errorf("读取授权策略失败: %w", err)
+	}
+	a := NewAuthorizer(p, audit)
+	err = kvconfig.WatchYamlConfig(f, kvconfig.AuthzDataId, group, func(p *Policy) {
+		a.Update(p)
+		hlog.Infof("授权策略已更新 [group: %s]", group)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("监听授权策略失败: %w", err)
+	}
+	return a, nil
+}
+
+// Update 替换授权策略
+func (a *Authorizer) Update(p *Policy) {
+	a.policy.Store(compile(p))
+}
+
+// Authorize 判断主体是否满足访问要求
+func (a *Authorizer) Authorize(p *Principal, req Requirement) *Decision {
+	d := &Decision{Principal: p, Requirement: req}
+	if len(req.AppTypes) == 0 && len(req.AnyRoles) == 0 && len(req.Permissions) == 0 {
+		d.Allowed = true
+		return d
+	}
+	// 先判断是否登录，未登录的请求返回 401 而不是 403
+	if !p.authenticated() {
+		d.Reason = ReasonUnauthenticated
+		return d
+	}
+	if len(req.AppTypes) > 0 && !contains(req.AppTypes, p.AppType) {
+		d.Reason = ReasonAppType
+		d.Missing = req.AppTypes
+		return d
+	}
+	if len(req.AnyRoles) == 0 && len(req.Permissions) == 0 {
+		d.Allowed = true
+		return d
+	}
+
+	policy := a.policy.Load()
+	if p.IsAdmin && policy.adminBypass {
+		d.Allowed = true
+		return d
+	}
+
+	if len(req.AnyRoles) > 0 {
+		matched := false
+		for _, role := range req.AnyRoles {
+			if contains(p.Roles, role) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			d.Reason = ReasonMissingRole
+			d.Missing = req.AnyRoles
+			return d
+		}
+	}
+
+	granted := policy.granted(p)
+	for _, required := range req.Permissions {
+		ok := false
+		for _, g := range granted {
+			if matchPermission(g, required) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			d.Missing = append(d.Missing, required)
+		}
+	}
+	if len(d.Missing) > 0 {
+		d.Reason = ReasonMissingPermission
+		return d
+	}
+	d.Allowed = true
+	return d
+}
+
+// Middleware 创建按访问要求授权的中间件，未通过时返回 401/403
+func (a *Authorizer) Middleware(req Requirement) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		a.handle(ctx, c, req)
+	}
+}
+
+func (a *Authorizer) handle(ctx context.Context, c *app.RequestContext, req Requirement) {
+	d := a.Authorize(PrincipalFromContext(ctx), req)
+	span := trace.SpanFromContext(ctx)
+	if d.Allowed {
+		span.SetAttributes(attribute.String("authz.decision", "allow"))
+		c.Next(ctx)
+		return
+	}
+
+	a.recordDenial(ctx, c, span, d)
+	if d.Reason == ReasonUnauthenticated {
+		hdserver.AbortWithError(ctx, c, consts.StatusUnauthorized, "未登录或登录已失效")
+		return
+	}
+	hdserver.AbortWithError(ctx, c, consts.StatusForbidden, "没有访问权限")
+}
+
+// Require 要求同时具备全部权限
+func (a *Authorizer) Require(permissions ...string) app.HandlerFunc {
+	return a.Middleware(Requirement{Permissions: permissions})
+}
+
+// RequireRole 要求至少具备其中一个角色
+func (a *Authorizer) RequireRole(roles ...string) app.HandlerFunc {
+	return a.Middleware(Requirement{AnyRoles: roles})
+}
+
+// RequireAppType 只允许指定的应用类型访问
+func (a *Authorizer) RequireAppType(appTypes ...string) app.HandlerFunc {
+	return a.Middleware(Requirement{AppTypes: appTypes})
+}
+
+// recordDenial 记录审计日志并把拒绝信息写入 Span
+func (a *Authorizer) recordDenial(ctx context.Context, c *app.RequestContext, span trace.Span, d *Decision) {
+	p := d.Principal
+	hlog.CtxWarnf(ctx, "授权拒绝 [method: %s, path: %s, tenant: %s, merchant: %s, user: %s, app_type: %s, reason: %s, missing: %s]",
+		c.Method(), c.Path(), p.TenantID, p.MerchantID, p.UserID, p.AppType, d.Reason, strings.Join(d.Missing, ","))
+
+	span.SetAttributes(
+		attribute.String("authz.decision", "deny"),
+		attribute.String("authz.reason", d.Reason),
+	)
+	span.AddEvent("authz.denied", trace.WithAttributes(
+		attribute.String("authz.reason", d.Reason),
+		attribute.StringSlice("authz.missing", d.Missing),
+		attribute.StringSlice("authz.roles", p.Roles),
+		attribute.String("http.route", c.FullPath()),
+	))
+	span.SetStatus(codes.Error, "authz denied")
+
+	if a.audit != nil {
+		a.audit(ctx, c, d)
+	}
+}
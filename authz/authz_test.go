@@ -0,0 +1,108 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+)
+
+var testPolicy = &Policy{
+	AdminBypass: true,
+	Roles: map[string][]string{
+		"owner":   {"*"},
+		"cashier": {"order:read", "order:write"},
+		"auditor": {"order:read", "report:*"},
+	},
+}
+
+func TestAuthorize(t *testing.T) {
+	a := NewAuthorizer(testPolicy, nil)
+	tests := []struct {
+		name   string
+		p      Principal
+		req    Requirement
+		allow  bool
+		reason string
+	}{
+		{name: "精确权限", p: Principal{UserID: "u1", Roles: []string{"cashier"}}, req: Requirement{Permissions: []string{"order:write"}}, allow: true},
+		{name: "缺少权限", p: Principal{UserID: "u1", Roles: []string{"auditor"}}, req: Requirement{Permissions: []string{"order:write"}}, reason: ReasonMissingPermission},
+		{name: "资源通配", p: Principal{UserID: "u1", Roles: []string{"auditor"}}, req: Requirement{Permissions: []string{"report:export"}}, allow: true},
+		{name: "全局通配", p: Principal{UserID: "u1", Roles: []string{"owner"}}, req: Requirement{Permissions: []string{"refund:approve"}}, allow: true},
+		{name: "直接授予", p: Principal{UserID: "u1", Permissions: []string{"refund:*"}}, req: Requirement{Permissions: []string{"refund:approve"}}, allow: true},
+		{name: "通配不跨前缀", p: Principal{UserID: "u1", Permissions: []string{"order:*"}}, req: Requirement{Permissions: []string{"orders:read"}}, reason: ReasonMissingPermission},
+		{name: "角色", p: Principal{UserID: "u1", Roles: []string{"auditor"}}, req: Requirement{AnyRoles: []string{"owner", "auditor"}}, allow: true},
+		{name: "缺少角色", p: Principal{UserID: "u1", Roles: []string{"cashier"}}, req: Requirement{AnyRoles: []string{"owner"}}, reason: ReasonMissingRole},
+		{name: "管理员跳过", p: Principal{UserID: "u1", IsAdmin: true}, req: Requirement{Permissions: []string{"anything"}}, allow: true},
+		{name: "应用类型", p: Principal{UserID: "u1", AppType: "pos", Roles: []string{"owner"}}, req: Requirement{AppTypes: []string{"admin"}}, reason: ReasonAppType},
+		{name: "管理员也受应用类型限制", p: Principal{UserID: "u1", AppType: "pos", IsAdmin: true}, req: Requirement{AppTypes: []string{"admin"}}, reason: ReasonAppType},
+		{name: "未登录", p: Principal{}, req: Requirement{Permissions: []string{"order:read"}}, reason: ReasonUnauthenticated},
+		{name: "未登录先于应用类型", p: Principal{AppType: "pos"}, req: Requirement{AppTypes: []string{"admin"}}, reason: ReasonUnauthenticated},
+		{name: "不限制", p: Principal{}, req: Requirement{}, allow: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.p
+			d := a.Authorize(&p, tt.req)
+			if d.Allowed != tt.allow || d.Reason != tt.reason {
+				t.Errorf("Authorize() = (%v, %q), want (%v, %q)", d.Allowed, d.Reason, tt.allow, tt.reason)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var audited []*Decision
+	a := NewAuthorizer(&Policy{}, func(ctx context.Context, c *app.RequestContext, d *Decision) {
+		audited = append(audited, d)
+	})
+	SetDefault(a)
+	t.Cleanup(func() { SetDefault(NewAuthorizer(nil, nil)) })
+
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(func(ctx context.Context, c *app.RequestContext) {
+		if role := string(c.GetHeader("X-Test-Role")); role != "" {
+			ctx = ctxx.WithUserID(ctx, "u1")
+			ctx = ctxx.WithRoles(ctx, []string{role})
+		}
+		c.Next(ctx)
+	})
+	// 路由注册早于策略加载，请求时再解析全局授权器
+	engine.POST("/orders", Require("order:write"), func(ctx context.Context, c *app.RequestContext) {
+		c.String(consts.StatusOK, "ok")
+	})
+	engine.GET("/console", RequireAppType("admin"), func(ctx context.Context, c *app.RequestContext) {
+		c.String(consts.StatusOK, "ok")
+	})
+	a.Update(testPolicy)
+
+	tests := []struct {
+		role string
+		want int
+	}{
+		{role: "cashier", want: consts.StatusOK},
+		{role: "auditor", want: consts.StatusForbidden},
+		{role: "", want: consts.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := ut.PerformRequest(engine, consts.MethodPost, "/orders", nil, ut.Header{Key: "X-Test-Role", Value: tt.role})
+		if got := w.Result().StatusCode(); got != tt.want {
+			t.Errorf("role %q: status = %d, want %d", tt.role, got, tt.want)
+		}
+	}
+	if len(audited) != 2 {
+		t.Fatalf("审计次数 = %d, want 2", len(audited))
+	}
+	// 只限制应用类型的路由，未登录时也返回 401
+	if got := ut.PerformRequest(engine, consts.MethodGet, "/console", nil).Result().StatusCode(); got != consts.StatusUnauthorized {
+		t.Errorf("未登录访问限制应用类型的路由 status = %d, want 401", got)
+	}
+	if audited[0].Reason != ReasonMissingPermission || audited[0].Missing[0] != "order:write" {
+		t.Errorf("审计记录 = %+v", audited[0])
+	}
+}
@@ -0,0 +1,48 @@
+package authz
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+var defaultAuthorizer atomic.Pointer[Authorizer]
+
+func init() {
+	defaultAuthorizer.Store(NewAuthorizer(nil, nil))
+}
+
+// SetDefault 设置全局授权器，通常在启动时以 NewWatchedAuthorizer 的结果调用
+func SetDefault(a *Authorizer) {
+	if a != nil {
+		defaultAuthorizer.Store(a)
+	}
+}
+
+// Default 获取全局授权器
+func Default() *Authorizer {
+	return defaultAuthorizer.Load()
+}
+
+// Middleware 使用全局授权器创建中间件，授权器在请求时解析，路由注册可早于 SetDefault
+func Middleware(req Requirement) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		Default().handle(ctx, c, req)
+	}
+}
+
+// Require 要求同时具备全部权限，如 authz.Require("order:write")
+func Require(permissions ...string) app.HandlerFunc {
+	return Middleware(Requirement{Permissions: permissions})
+}
+
+// RequireRole 要求至少具备其中一个角色
+func RequireRole(roles ...string) app.HandlerFunc {
+	return Middleware(Requirement{AnyRoles: roles})
+}
+
+// RequireAppType 只允许指定的应用类型访问
+func RequireAppType(appTypes ...string) app.HandlerFunc {
+	return Middleware(Requirement{AppTypes: appTypes})
+}
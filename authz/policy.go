@@ -0,0 +1,111 @@
+package authz
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+)
+
+// Policy 授权策略，从配置中心的 authz 配置加载
+//
+//	admin_bypass: true
+//	roles:
+//	  owner: ["*"]
+//	  cashier: ["order:read", "order:write"]
+//	  auditor: ["order:read", "report:*"]
+type Policy struct {
+	// Roles 角色 -> 权限列表，权限支持 "order:*" 与 "*" 通配
+	Roles map[string][]string `yaml:"roles" json:"roles"`
+	// AdminBypass 为 true 时管理员跳过角色与权限检查（应用类型限制仍然生效）
+	AdminBypass bool `yaml:"admin_bypass" json:"admin_bypass"`
+}
+
+// Principal 请求主体
+type Principal struct {
+	TenantID    string
+	MerchantID  string
+	UserID      string
+	AppType     string
+	Roles       []string
+	Permissions []string
+	IsAdmin     bool
+}
+
+// PrincipalFromContext 从 ctxx 中构建请求主体
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p := &Principal{
+		TenantID:    ctxx.GetTenantID(ctx),
+		MerchantID:  ctxx.GetMerchantID(ctx),
+		UserID:      ctxx.GetUserID(ctx),
+		AppType:     ctxx.GetAppType(ctx),
+		Roles:       ctxx.GetRoles(ctx),
+		Permissions: ctxx.GetPermissions(ctx),
+	}
+	if isAdmin := ctxx.IsAdmin(ctx); isAdmin != nil {
+		p.IsAdmin = *isAdmin
+	}
+	return p
+}
+
+// authenticated 主体是否携带身份信息
+func (p *Principal) authenticated() bool {
+	return p.UserID != "" || len(p.Roles) > 0 || len(p.Permissions) > 0
+}
+
+// Requirement 路由声明的访问要求，各项之间为“且”的关系
+type Requirement struct {
+	// Permissions 需要同时具备的权限
+	Permissions []string
+	// AnyRoles 至少具备其中一个角色
+	AnyRoles []string
+	// AppTypes 只允许这些应用类型访问
+	AppTypes []string
+}
+
+// compiledPolicy 预先展开的策略
+type compiledPolicy struct {
+	roles       map[string][]string
+	adminBypass bool
+}
+
+func compile(p *Policy) *compiledPolicy {
+	cp := &compiledPolicy{roles: make(map[string][]string)}
+	if p == nil {
+		return cp
+	}
+	cp.adminBypass = p.AdminBypass
+	for role, perms := range p.Roles {
+		cp.roles[role] = append([]string(nil), perms...)
+	}
+	return cp
+}
+
+// granted 主体通过角色与直接授予获得的全部权限
+func (cp *compiledPolicy) granted(p *Principal) []string {
+	out := append([]string(nil), p.Permissions...)
+	for _, role := range p.Roles {
+		out = append(out, cp.roles[role]...)
+	}
+	return out
+}
+
+// matchPermission 判断已授予的权限是否覆盖所需权限，支持 "*" 与 "resource:*" 通配
+func matchPermission(granted, required string) bool {
+	if granted == "*" || granted == required {
+		return true
+	}
+	if strings.HasSuffix(granted, ":*") {
+		return strings.HasPrefix(required, granted[:len(granted)-1])
+	}
+	return false
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+// Package avatar 用户头像处理：消费 hdmodel.UserImageMqDto，读取用户上传的原图（没有时生成默认头像），
+// 裁剪缩放并编码为 PNG/JPEG/WebP 后按租户路径存储，再发送 hdmodel.UserImageResultMqDto
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/mq"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/grayscalecloud/hertzcommon/avatar"
+
+// ErrNoSource 用户没有上传原图，SourceLoader 返回该错误时生成默认头像
+var ErrNoSource = errors.New("用户没有上传头像原图")
+
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// SourceLoader 读取用户上传的头像原图
+type SourceLoader interface {
+	// Load 返回原图内容，用户没有上传时返回 ErrNoSource
+	Load(ctx context.Context, tenantID string, userID int64) (io.ReadCloser, error)
+}
+
+// SourceLoaderFunc 函数形式的 SourceLoader
+type SourceLoaderFunc func(ctx context.Context, tenantID string, userID int64) (io.ReadCloser, error)
+
+// Load 实现 SourceLoader
+func (f SourceLoaderFunc) Load(ctx context.Context, tenantID string, userID int64) (io.ReadCloser, error) {
+	return f(ctx, tenantID, userID)
+}
+
+// Storage 头像存储
+type Storage interface {
+	// Put 写入对象，key 为相对路径，如 tenants/t1/avatars/42.png
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// URL 返回对象的访问地址
+	URL(ctx context.Context, key string) (string, error)
+}
+
+// Options 头像处理配置
+type Options struct {
+	// Storage 头像存储，必填
+	Storage Storage
+	// Loader 原图读取，为空时总是生成默认头像
+	Loader SourceLoader
+	// Results 发送处理结果的生产者，为空时不发送
+	Results mq.Producer
+	// Topic 消费的 topic，默认 user_image
+	Topic string
+	// ResultTopic 处理结果的 topic，默认 user_image_result
+	ResultTopic string
+	// Registry 消息类型注册表，默认 mq.DefaultRegistry
+	Registry *mq.Registry
+	// Size 输出头像的边长（像素），默认 256
+	Size int
+	// Format 输出格式：png（默认）、jpeg、webp
+	Format string
+	// JPEGQuality JPEG 质量，默认 85
+	JPEGQuality int
+	// MaxSourceBytes 原图的最大字节数，默认 10MB
+	MaxSourceBytes int64
+	// MaxSourcePixels 原图的最大像素数，默认 4000 万
+	MaxSourcePixels int64
+}
+
+// Worker 头像处理
+type Worker struct {
+	opts Options
+}
+
+// NewWorker 创建头像处理
+func NewWorker(opts *Options) (*Worker, error) {
+	if opts == nil || opts.Storage == nil {
+		return nil, errors.New("创建头像处理失败: 未配置 Storage")
+	}
+	w := &Worker{opts: *opts}
+	if w.opts.Topic == "" {
+		w.opts.Topic = hdmodel.MqTypeUserImage
+	}
+	if w.opts.ResultTopic == "" {
+		w.opts.ResultTopic = hdmodel.MqTypeUserImageResult
+	}
+	if w.opts.Registry == nil {
+		w.opts.Registry = mq.DefaultRegistry
+	}
+	if w.opts.Size <= 0 {
+		w.opts.Size = 256
+	}
+	if w.opts.Format == "" {
+		w.opts.Format = FormatPNG
+	}
+	if _, ok := contentTypes[w.opts.Format]; !ok {
+		return nil, fmt.Errorf("创建头像处理失败: 不支持的图片格式 %s", w.opts.Format)
+	}
+	if w.opts.JPEGQuality <= 0 || w.opts.JPEGQuality > 100 {
+		w.opts.JPEGQuality = 85
+	}
+	if w.opts.MaxSourceBytes <= 0 {
+		w.opts.MaxSourceBytes = 10 << 20
+	}
+	if w.opts.MaxSourcePixels <= 0 {
+		w.opts.MaxSourcePixels = 40_000_000
+	}
+	return w, nil
+}
+
+// Run 消费头像处理消息，直到 ctx 取消或消费者关闭
+func (w *Worker) Run(ctx context.Context, c mq.Consumer) error {
+	return c.Consume(ctx, w.opts.Topic, w.Handle)
+}
+
+// Handle 实现 mq.Handler：处理成功或因原图问题失败时发送结果并确认消息；
+// 存储或发送结果失败时返回错误，由消费者按重试策略重试
+func (w *Worker) Handle(ctx context.Context, msg *mq.Message) error {
+	env, err := w.opts.Registry.Parse(msg.Body, hdmodel.MqTypeUserImage)
+	if err != nil {
+		return mq.Permanent(err)
+	}
+	var dto hdmodel.UserImageMqDto
+	if err := w.opts.Registry.Decode(env, &dto); err != nil {
+		return mq.Permanent(err)
+	}
+	// 租户以信封与消息头为准，消息体中的租户只用于引入信封之前、没有租户消息头的消息
+	tenant := env.TenantID
+	if headerTenant := ctxx.GetTenantID(ctx); tenant == "" {
+		tenant = headerTenant
+	} else if headerTenant != "" && headerTenant != tenant {
+		return mq.Permanent(fmt.Errorf("头像处理消息的信封租户 %s 与消息头租户 %s 不一致", tenant, headerTenant))
+	}
+	switch {
+	case tenant == "":
+		tenant = dto.TenantId
+	case dto.TenantId != "" && dto.TenantId != tenant:
+		return mq.Permanent(fmt.Errorf("头像处理消息体中的租户 %s 与消息租户 %s 不一致", dto.TenantId, tenant))
+	}
+	dto.TenantId = tenant
+	if ctxx.GetTenantID(ctx) == "" && tenant != "" {
+		// 引入信封之前的消息没有租户消息头，结果消息需要带上租户
+		ctx = ctxx.WithTenantID(ctx, tenant)
+	}
+
+	result, err := w.Process(ctx, &dto)
+	if err != nil {
+		if !IsImageError(err) {
+			return err
+		}
+		result = &hdmodel.UserImageResultMqDto{UserID: dto.UserID, TenantId: dto.TenantId, Error: err.Error()}
+	}
+	return w.publishResult(ctx, result)
+}
+
+// imageError 原图不可用（格式错误、尺寸超限等），重试也无法成功
+type imageError struct {
+	err error
+}
+
+func (e *imageError) Error() string { return e.err.Error() }
+func (e *imageError) Unwrap() error { return e.err }
+
+// Process 生成用户头像并存储，返回成功的处理结果；
+// 原图不可用时返回的错误满足 IsImageError，其他错误（读取原图、存储失败）可以重试
+func (w *Worker) Process(ctx context.Context, dto *hdmodel.UserImageMqDto) (res *hdmodel.UserImageResultMqDto, err error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "avatar.process", trace.WithAttributes(
+		attribute.String("tenant.id", dto.TenantId),
+		attribute.Int64("user.id", dto.UserID),
+		attribute.String("avatar.format", w.opts.Format),
+		attribute.Int("avatar.size", w.opts.Size),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	if !tenantIDPattern.MatchString(dto.TenantId) {
+		return nil, &imageError{fmt.Errorf("租户 ID 不合法: %q", dto.TenantId)}
+	}
+	if dto.UserID <= 0 {
+		return nil, &imageError{fmt.Errorf("用户 ID 不合法: %d", dto.UserID)}
+	}
+
+	src, generated, err := w.load(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Bool("avatar.generated", generated))
+
+	var buf bytes.Buffer
+	if err := encode(&buf, src, w.opts.Format, w.opts.JPEGQuality); err != nil {
+		return nil, fmt.Errorf("编码头像失败: %w", err)
+	}
+
+	key := w.Key(dto.TenantId, dto.UserID)
+	if err := w.opts.Storage.Put(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), contentTypes[w.opts.Format]); err != nil {
+		return nil, fmt.Errorf("存储头像失败 [%s]: %w", key, err)
+	}
+	url, err := w.opts.Storage.URL(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("获取头像地址失败 [%s]: %w", key, err)
+	}
+	span.SetAttributes(attribute.String("avatar.key", key), attribute.Int("avatar.bytes", buf.Len()))
+	return &hdmodel.UserImageResultMqDto{UserID: dto.UserID, TenantId: dto.TenantId, AvatarUrl: url, Success: true}, nil
+}
+
+// Key 头像在存储中的路径，按租户隔离：tenants/<租户>/avatars/<用户>.<扩展名>
+func (w *Worker) Key(tenantID string, userID int64) string {
+	return "tenants/" + tenantID + "/avatars/" + strconv.FormatInt(userID, 10) + extensions[w.opts.Format]
+}
+
+// IsImageError 判断 Process 的错误是否因原图不可用
+func IsImageError(err error) bool {
+	var ie *imageError
+	return errors.As(err, &ie)
+}
+
+// load 读取原图并缩放，没有原图时生成默认头像
+func (w *Worker) load(ctx context.Context, dto *hdmodel.UserImageMqDto) (img *image.RGBA, generated bool, err error) {
+	if w.opts.Loader == nil {
+		return identicon(dto.TenantId+"/"+strconv.FormatInt(dto.UserID, 10), w.opts.Size), true, nil
+	}
+	rc, err := w.opts.Loader.Load(ctx, dto.TenantId, dto.UserID)
+	if errors.Is(err, ErrNoSource) {
+		return identicon(dto.TenantId+"/"+strconv.FormatInt(dto.UserID, 10), w.opts.Size), true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("读取头像原图失败: %w", err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, w.opts.MaxSourceBytes+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("读取头像原图失败: %w", err)
+	}
+	if int64(len(data)) > w.opts.MaxSourceBytes {
+		return nil, false, &imageError{fmt.Errorf("头像原图超过 %d 字节", w.opts.MaxSourceBytes)}
+	}
+	src, err := decode(data, w.opts.MaxSourcePixels)
+	if err != nil {
+		return nil, false, &imageError{err}
+	}
+	return squareResize(src, w.opts.Size), false, nil
+}
+
+func (w *Worker) publishResult(ctx context.Context, res *hdmodel.UserImageResultMqDto) error {
+	if !res.Success {
+		hlog.CtxWarnf(ctx, "头像处理失败 [tenant: %s, user: %d]: %s", res.TenantId, res.UserID, res.Error)
+	}
+	if w.opts.Results == nil {
+		return nil
+	}
+	msg, err := w.opts.Registry.NewMessage(ctx, strconv.FormatInt(res.UserID, 10), res)
+	if err != nil {
+		return mq.Permanent(err)
+	}
+	return w.opts.Results.Publish(ctx, w.opts.ResultTopic, msg)
+}
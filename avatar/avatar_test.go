@@ -0,0 +1,291 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/mq"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/image/webp"
+)
+
+// memStorage 内存存储
+type memStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+	err     error
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{objects: map[string][]byte{}, types: map[string]string{}}
+}
+
+func (s *memStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if s.err != nil {
+		return s.err
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(b)) != size {
+		return errors.New("size 不一致")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = b
+	s.types[key] = contentType
+	return nil
+}
+
+func (s *memStorage) URL(ctx context.Context, key string) (string, error) {
+	return "https://cdn.example.com/" + key, nil
+}
+
+func sourcePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 0x80, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func staticLoader(data []byte) SourceLoader {
+	return SourceLoaderFunc(func(ctx context.Context, tenantID string, userID int64) (io.ReadCloser, error) {
+		if data == nil {
+			return nil, ErrNoSource
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+func TestProcessFormats(t *testing.T) {
+	src := sourcePNG(t, 400, 300)
+	decoders := map[string]func(io.Reader) (image.Image, error){
+		FormatPNG:  png.Decode,
+		FormatJPEG: jpeg.Decode,
+		FormatWebP: webp.Decode,
+	}
+	for format, decodeFn := range decoders {
+		t.Run(format, func(t *testing.T) {
+			store := newMemStorage()
+			w, err := NewWorker(&Options{Storage: store, Loader: staticLoader(src), Format: format, Size: 64})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := w.Process(context.Background(), &hdmodel.UserImageMqDto{UserID: 42, TenantId: "t1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := "tenants/t1/avatars/42" + extensions[format]
+			if !res.Success || res.AvatarUrl != "https://cdn.example.com/"+key {
+				t.Errorf("res = %+v", res)
+			}
+			if store.types[key] != contentTypes[format] {
+				t.Errorf("content type = %q", store.types[key])
+			}
+			img, err := decodeFn(bytes.NewReader(store.objects[key]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+				t.Errorf("尺寸 = %v, want 64x64", b)
+			}
+		})
+	}
+}
+
+func TestProcessGeneratesDefault(t *testing.T) {
+	store := newMemStorage()
+	w, err := NewWorker(&Options{Storage: store, Loader: staticLoader(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := w.Process(context.Background(), &hdmodel.UserImageMqDto{UserID: 7, TenantId: "t1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first := store.objects["tenants/t1/avatars/7.png"]
+	img, err := png.Decode(bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 256 {
+		t.Errorf("默认头像尺寸 = %v", img.Bounds())
+	}
+	if !bytes.Equal(img.(*image.RGBA).Pix, identicon("t1/7", 256).Pix) {
+		t.Error("同一用户的默认头像应相同")
+	}
+	if bytes.Equal(identicon("t1/7", 32).Pix, identicon("t2/7", 32).Pix) {
+		t.Error("不同租户的默认头像应不同")
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	w, err := NewWorker(&Options{Storage: newMemStorage(), Loader: staticLoader([]byte("not an image"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dto := range []hdmodel.UserImageMqDto{
+		{UserID: 1, TenantId: "t1"},
+		{UserID: 1, TenantId: "../t2"},
+		{UserID: 0, TenantId: "t1"},
+	} {
+		if _, err := w.Process(context.Background(), &dto); !IsImageError(err) {
+			t.Errorf("%+v: err = %v, 应为原图错误", dto, err)
+		}
+	}
+
+	w, _ = NewWorker(&Options{Storage: newMemStorage(), Loader: staticLoader(sourcePNG(t, 100, 100)), MaxSourcePixels: 100})
+	if _, err := w.Process(context.Background(), &hdmodel.UserImageMqDto{UserID: 1, TenantId: "t1"}); !IsImageError(err) {
+		t.Errorf("超过像素上限: err = %v", err)
+	}
+
+	if _, err := NewWorker(&Options{Storage: newMemStorage(), Format: "bmp"}); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+	if _, err := NewWorker(&Options{}); err == nil {
+		t.Error("未配置 Storage 应返回错误")
+	}
+}
+
+func TestWorkerConsume(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	broker := mq.NewMemoryBroker()
+	defer broker.Close()
+	store := newMemStorage()
+	w, err := NewWorker(&Options{Storage: store, Loader: staticLoader(sourcePNG(t, 50, 50)), Results: broker})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := ctxx.WithTenantID(context.Background(), "t1")
+	msg, err := mq.DefaultRegistry.NewMessage(ctx, "42", &hdmodel.UserImageMqDto{UserID: 42, TenantId: "t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Publish(ctx, hdmodel.MqTypeUserImage, msg); err != nil {
+		t.Fatal(err)
+	}
+	// 引入信封之前的消息，且原图损坏
+	w2, _ := NewWorker(&Options{Storage: store, Loader: staticLoader([]byte("x")), Results: broker})
+	legacy := &mq.Message{Body: []byte(`{"UserID":43,"TenantId":"t2"}`)}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(runCtx, broker.Consumer(nil)) }()
+	if err := w2.Handle(context.Background(), legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	var results []*mq.Message
+	deadline := time.Now().Add(3 * time.Second)
+	for len(results) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("结果消息 = %d, want 2", len(results))
+		}
+		time.Sleep(5 * time.Millisecond)
+		results = broker.Messages(hdmodel.MqTypeUserImageResult)
+	}
+	cancel()
+	<-done
+
+	byUser := map[int64]*hdmodel.UserImageResultMqDto{}
+	for _, m := range results {
+		env, err := mq.DefaultRegistry.Parse(m.Body, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res hdmodel.UserImageResultMqDto
+		if err := mq.DefaultRegistry.Decode(env, &res); err != nil {
+			t.Fatal(err)
+		}
+		if env.TenantID != res.TenantId {
+			t.Errorf("信封租户 = %q, 结果租户 = %q", env.TenantID, res.TenantId)
+		}
+		byUser[res.UserID] = &res
+	}
+	if r := byUser[42]; r == nil || !r.Success || !strings.HasSuffix(r.AvatarUrl, "tenants/t1/avatars/42.png") {
+		t.Errorf("user 42 = %+v", r)
+	}
+	if r := byUser[43]; r == nil || r.Success || r.Error == "" || r.TenantId != "t2" {
+		t.Errorf("user 43 = %+v", r)
+	}
+
+	var found bool
+	for _, s := range recorder.Ended() {
+		if s.Name() == "avatar.process" && s.Parent().IsValid() {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("缺少挂在消费 Span 下的 avatar.process Span")
+	}
+}
+
+func TestHandleStorageFailureRetries(t *testing.T) {
+	store := newMemStorage()
+	store.err = errors.New("对象存储不可用")
+	w, _ := NewWorker(&Options{Storage: store})
+	msg, err := mq.DefaultRegistry.NewMessage(context.Background(), "", &hdmodel.UserImageMqDto{UserID: 1, TenantId: "t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Handle(context.Background(), msg)
+	if err == nil || mq.IsPermanent(err) {
+		t.Errorf("存储失败应返回可重试的错误, got %v", err)
+	}
+	if err := w.Handle(context.Background(), &mq.Message{Body: []byte("{")}); !mq.IsPermanent(err) {
+		t.Errorf("无法解析的消息应返回永久错误, got %v", err)
+	}
+}
+
+func TestHandleTenantMismatch(t *testing.T) {
+	store := newMemStorage()
+	w, _ := NewWorker(&Options{Storage: store, Loader: staticLoader(sourcePNG(t, 64, 64))})
+	// 信封租户为 t1，消息体声称 t2
+	ctx := ctxx.WithTenantID(context.Background(), "t1")
+	msg, err := mq.DefaultRegistry.NewMessage(ctx, "", &hdmodel.UserImageMqDto{UserID: 1, TenantId: "t2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Handle(context.Background(), msg); !mq.IsPermanent(err) {
+		t.Errorf("信封与消息体租户不一致应返回永久错误, got %v", err)
+	}
+	// 消费者从消息头写入的租户与信封不一致
+	msg, _ = mq.DefaultRegistry.NewMessage(ctx, "", &hdmodel.UserImageMqDto{UserID: 1})
+	if err := w.Handle(ctxx.WithTenantID(context.Background(), "t2"), msg); !mq.IsPermanent(err) {
+		t.Errorf("消息头与信封租户不一致应返回永久错误, got %v", err)
+	}
+	if len(store.objects) != 0 {
+		t.Errorf("不应写入存储: %v", store.objects)
+	}
+}
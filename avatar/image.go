@@ -0,0 +1,109 @@
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/HugoSmits86/nativewebp"
+	xdraw "golang.org/x/image/draw"
+
+	// 注册解码器
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+)
+
+// 输出格式
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+)
+
+var contentTypes = map[string]string{
+	FormatPNG:  "image/png",
+	FormatJPEG: "image/jpeg",
+	FormatWebP: "image/webp",
+}
+
+var extensions = map[string]string{
+	FormatPNG:  ".png",
+	FormatJPEG: ".jpg",
+	FormatWebP: ".webp",
+}
+
+// decode 解码 PNG/JPEG/GIF/WebP 图片，先读取尺寸拒绝像素数超过 maxPixels 的图片，防止解压炸弹
+func decode(data []byte, maxPixels int64) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("无法识别的图片: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, fmt.Errorf("图片尺寸超出限制: %dx%d", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %w", err)
+	}
+	return img, nil
+}
+
+// squareResize 居中裁剪为正方形后缩放到 size x size
+func squareResize(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	edge := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, edge, edge).Add(image.Pt(b.Min.X+(b.Dx()-edge)/2, b.Min.Y+(b.Dy()-edge)/2))
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+	return dst
+}
+
+// encode 按格式编码，JPEG 不支持透明，透明区域填充为白色
+func encode(w io.Writer, img image.Image, format string, jpegQuality int) error {
+	switch format {
+	case FormatPNG:
+		return png.Encode(w, img)
+	case FormatJPEG:
+		bg := image.NewRGBA(img.Bounds())
+		draw.Draw(bg, bg.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(bg, bg.Bounds(), img, img.Bounds().Min, draw.Over)
+		return jpeg.Encode(w, bg, &jpeg.Options{Quality: jpegQuality})
+	case FormatWebP:
+		return nativewebp.Encode(w, img, nil)
+	default:
+		return fmt.Errorf("不支持的图片格式: %s", format)
+	}
+}
+
+// identicon 根据 seed 生成左右对称的 5x5 像素块头像，同一用户每次生成的结果相同
+func identicon(seed string, size int) *image.RGBA {
+	sum := sha256.Sum256([]byte(seed))
+	fg := color.RGBA{R: sum[0]/2 + 64, G: sum[1]/2 + 64, B: sum[2]/2 + 64, A: 0xff}
+	bg := color.RGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	const grid = 5
+	margin := size / 10
+	cell := (size - 2*margin) / grid
+	offset := (size - cell*grid) / 2
+	for row := 0; row < grid; row++ {
+		for col := 0; col < (grid+1)/2; col++ {
+			if sum[3+row*3+col]&1 == 0 {
+				continue
+			}
+			for _, c := range []int{col, grid - 1 - col} {
+				r := image.Rect(offset+c*cell, offset+row*cell, offset+(c+1)*cell, offset+(row+1)*cell)
+				draw.Draw(img, r, &image.Uniform{C: fg}, image.Point{}, draw.Src)
+			}
+		}
+	}
+	return img
+}
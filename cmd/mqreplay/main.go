@@ -0,0 +1,79 @@
+// mqreplay 把 Redis Streams 死信队列中的消息重新发送到原 topic
+//
+//	go run ./cmd/mqreplay -addr 127.0.0.1:6379 -topic user_image.dlq -count 100
+//
+// 默认只列出死信，加 -apply 后才重新发送，发送成功的死信会从死信队列删除（-keep 保留）
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/mq"
+	"github.com/grayscalecloud/hertzcommon/pkg/redisx"
+)
+
+func main() {
+	var (
+		addr     = flag.String("addr", "127.0.0.1:6379", "Redis 地址")
+		username = flag.String("username", "", "Redis 用户名")
+		password = flag.String("password", os.Getenv("REDIS_PASSWORD"), "Redis 密码，默认读取 REDIS_PASSWORD")
+		db       = flag.Int("db", 0, "Redis DB")
+		prefix   = flag.String("prefix", "", "stream 键前缀，默认 hc:mq:")
+		topic    = flag.String("topic", "", "死信 topic，如 user_image.dlq")
+		count    = flag.Int64("count", 100, "最多处理的条数")
+		apply    = flag.Bool("apply", false, "重新发送；不加时只列出死信")
+		keep     = flag.Bool("keep", false, "重新发送后保留死信")
+	)
+	flag.Parse()
+	if *topic == "" {
+		fmt.Fprintln(os.Stderr, "缺少 -topic")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	client, err := redisx.NewClientWithPing(ctx, &hdmodel.Redis{Address: *addr, Username: *username, Password: *password, DB: *db})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	msgs, err := mq.RangeRedis(ctx, client, *prefix, *topic, *count)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	producer := mq.NewRedisProducer(client, &mq.RedisOptions{Prefix: *prefix})
+	failed := 0
+	for _, m := range msgs {
+		fmt.Printf("%s topic=%s attempts=%s trace_id=%s failed_at=%s error=%q\n", m.ID,
+			m.Headers[mq.HeaderDLQOriginalTopic], m.Headers[mq.HeaderDLQAttempts], m.Headers[mq.HeaderDLQTraceID],
+			m.Headers[mq.HeaderDLQFailedAt], m.Headers[mq.HeaderDLQError])
+		if !*apply {
+			continue
+		}
+		replayed, err := mq.Replay(ctx, producer, m)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "  重放失败: %v\n", err)
+			continue
+		}
+		fmt.Printf("  已重放为 %s\n", replayed.ID)
+		if !*keep {
+			if err := mq.DeleteRedis(ctx, client, *prefix, *topic, m.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "  删除死信失败: %v\n", err)
+			}
+		}
+	}
+	fmt.Printf("共 %d 条死信，失败 %d 条\n", len(msgs), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,44 @@
+// mqschema 生成 mq.DefaultRegistry 中全部消息类型与版本的 JSON Schema
+//
+//	go run ./cmd/mqschema -out docs/mqschema
+//
+// 每个版本一个文件，文件名为 <type>.v<version>.json；不指定 -out 时输出到标准输出
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/grayscalecloud/hertzcommon/mq"
+)
+
+func main() {
+	out := flag.String("out", "", "输出目录，为空时输出到标准输出")
+	flag.Parse()
+
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	for _, tv := range mq.DefaultRegistry.Types() {
+		schema, err := mq.DefaultRegistry.Schema(tv.Type, tv.Version)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if *out == "" {
+			fmt.Printf("%s\n", schema)
+			continue
+		}
+		name := filepath.Join(*out, fmt.Sprintf("%s.v%d.json", tv.Type, tv.Version))
+		if err := os.WriteFile(name, append(schema, '\n'), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(name)
+	}
+}
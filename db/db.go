@@ -0,0 +1,218 @@
+// Package db 根据 hdmodel.MySQL 创建 MySQL 连接池：连接池参数、超时、只读副本，
+// 每条语句都会生成 Span、记录耗时与错误指标，超过阈值的语句记录带租户与请求 ID 的慢查询日志
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/monitor"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultMaxOpenConns    = 50
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnMaxIdleTime = 5 * time.Minute
+	defaultConnectTimeout  = 5 * time.Second
+	defaultSlowThreshold   = 200 * time.Millisecond
+)
+
+// 连接池角色，作为指标与 Span 的标签
+const (
+	RolePrimary = "primary"
+	RoleReplica = "replica"
+)
+
+// Options 连接池的观测配置
+type Options struct {
+	// Name 指标与 Span 中的数据库名，默认取主库 DSN 中的库名
+	Name string
+	// Registerer 注册指标的 Prometheus 注册表，默认 monitor.Reg；两者都为空时不记录指标
+	Registerer prometheus.Registerer
+}
+
+// DB 主库与只读副本的连接池，写请求与事务使用主库，读请求可以通过 Replica 分摊到副本
+type DB struct {
+	primary  *sql.DB
+	replicas []*sql.DB
+	next     atomic.Uint64
+
+	name  string
+	reg   prometheus.Registerer
+	stats []prometheus.Collector
+}
+
+// Open 根据配置创建主库与只读副本的连接池；与 sql.Open 一样不会立即建立连接，需要时调用 Ping 检查
+func Open(cfg *hdmodel.MySQL, opts *Options) (*DB, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	primaryCfg, err := parseDSN(cfg, cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	name := opts.Name
+	if name == "" {
+		name = primaryCfg.DBName
+	}
+	reg := opts.Registerer
+	if reg == nil && monitor.Reg != nil {
+		reg = monitor.Reg
+	}
+	var m *dbMetrics
+	if reg != nil {
+		if m, err = newDBMetrics(reg); err != nil {
+			return nil, err
+		}
+	}
+	slow := cfg.SlowThreshold
+	if slow == 0 {
+		slow = defaultSlowThreshold
+	}
+
+	d := &DB{}
+	if d.primary, err = openPool(cfg, primaryCfg, &instrument{name: name, role: RolePrimary, slow: slow, metrics: m}); err != nil {
+		return nil, err
+	}
+	for i, dsn := range cfg.Replicas {
+		rc, err := parseDSN(cfg, dsn)
+		if err != nil {
+			_ = d.Close()
+			return nil, fmt.Errorf("只读副本 %d: %w", i, err)
+		}
+		pool, err := openPool(cfg, rc, &instrument{name: name, role: RoleReplica, slow: slow, metrics: m})
+		if err != nil {
+			_ = d.Close()
+			return nil, fmt.Errorf("只读副本 %d: %w", i, err)
+		}
+		d.replicas = append(d.replicas, pool)
+	}
+	d.name, d.reg = name, reg
+	d.registerStats()
+	return d, nil
+}
+
+// parseDSN 解析 DSN，DSN 中没有设置的超时使用配置中的值
+func parseDSN(cfg *hdmodel.MySQL, dsn string) (*mysql.Config, error) {
+	if dsn == "" {
+		return nil, errors.New("解析 MySQL DSN 失败: 未配置 dsn")
+	}
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("解析 MySQL DSN 失败: %w", err)
+	}
+	if mc.Timeout == 0 {
+		mc.Timeout = cfg.ConnectTimeout
+		if mc.Timeout <= 0 {
+			mc.Timeout = defaultConnectTimeout
+		}
+	}
+	if mc.ReadTimeout == 0 && cfg.ReadTimeout > 0 {
+		mc.ReadTimeout = cfg.ReadTimeout
+	}
+	if mc.WriteTimeout == 0 && cfg.WriteTimeout > 0 {
+		mc.WriteTimeout = cfg.WriteTimeout
+	}
+	return mc, nil
+}
+
+func openPool(cfg *hdmodel.MySQL, mc *mysql.Config, ins *instrument) (*sql.DB, error) {
+	c, err := mysql.NewConnector(mc)
+	if err != nil {
+		return nil, fmt.Errorf("打开 MySQL 失败: %w", err)
+	}
+	pool := sql.OpenDB(&connector{Connector: c, ins: ins})
+	configurePool(pool, cfg)
+	return pool, nil
+}
+
+func configurePool(pool *sql.DB, cfg *hdmodel.MySQL) {
+	maxOpen := cfg.MaxOpenConns
+	if maxOpen <= 0 {
+		maxOpen = defaultMaxOpenConns
+	}
+	maxIdle := cfg.MaxIdleConns
+	if maxIdle <= 0 {
+		maxIdle = defaultMaxIdleConns
+	}
+	lifetime := cfg.ConnMaxLifetime
+	if lifetime <= 0 {
+		lifetime = defaultConnMaxLifetime
+	}
+	idleTime := cfg.ConnMaxIdleTime
+	if idleTime <= 0 {
+		idleTime = defaultConnMaxIdleTime
+	}
+	pool.SetMaxOpenConns(maxOpen)
+	pool.SetMaxIdleConns(min(maxIdle, maxOpen))
+	pool.SetConnMaxLifetime(lifetime)
+	pool.SetConnMaxIdleTime(idleTime)
+}
+
+// Primary 返回主库连接池，可以交给 GORM、outbox 等使用
+func (d *DB) Primary() *sql.DB {
+	return d.primary
+}
+
+// Replica 轮询返回一个只读副本的连接池，没有配置副本时返回主库；
+// 副本存在复制延迟，需要读到刚写入的数据时应使用主库
+func (d *DB) Replica() *sql.DB {
+	if len(d.replicas) == 0 {
+		return d.primary
+	}
+	return d.replicas[(d.next.Add(1)-1)%uint64(len(d.replicas))]
+}
+
+// ExecContext 在主库执行语句
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.primary.ExecContext(ctx, query, args...)
+}
+
+// QueryContext 在主库查询
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.primary.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext 在主库查询单行
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.primary.QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx 在主库开启事务
+func (d *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return d.primary.BeginTx(ctx, opts)
+}
+
+// Ping 检查主库与所有只读副本的连接
+func (d *DB) Ping(ctx context.Context) error {
+	if err := d.primary.PingContext(ctx); err != nil {
+		return fmt.Errorf("连接 MySQL 主库失败: %w", err)
+	}
+	for i, r := range d.replicas {
+		if err := r.PingContext(ctx); err != nil {
+			return fmt.Errorf("连接 MySQL 只读副本 %d 失败: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Close 关闭所有连接池并注销连接池状态指标
+func (d *DB) Close() error {
+	d.unregisterStats()
+	var errs []error
+	if d.primary != nil {
+		errs = append(errs, d.primary.Close())
+	}
+	for _, r := range d.replicas {
+		errs = append(errs, r.Close())
+	}
+	return errors.Join(errs...)
+}
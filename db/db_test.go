@@ -0,0 +1,209 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// fakeConnector 不连接数据库的驱动：带参数的语句返回 driver.ErrSkip，与未开启 interpolateParams 的 MySQL 驱动一致
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("不支持事务") }
+
+func (fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		return nil, driver.ErrSkip
+	}
+	if strings.Contains(query, "missing") {
+		return nil, errors.New("Table 'orders.missing' doesn't exist")
+	}
+	if strings.Contains(query, "SLEEP") {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeStmt struct{}
+
+func (fakeStmt) Close() error                                    { return nil }
+func (fakeStmt) NumInput() int                                   { return -1 }
+func (fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (fakeStmt) Query(args []driver.Value) (driver.Rows, error)  { return &fakeRows{}, nil }
+
+type fakeRows struct{ done bool }
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func TestOpen(t *testing.T) {
+	cfg := &hdmodel.MySQL{
+		DSN:            "app:secret@tcp(10.0.0.1:3306)/orders?timeout=2s",
+		Replicas:       []string{"app:secret@tcp(10.0.0.2:3306)/orders", "app:secret@tcp(10.0.0.3:3306)/orders"},
+		MaxOpenConns:   7,
+		ConnectTimeout: 3 * time.Second,
+		ReadTimeout:    10 * time.Second,
+	}
+	d, err := Open(cfg, &Options{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if n := d.Primary().Stats().MaxOpenConnections; n != 7 {
+		t.Errorf("MaxOpenConnections = %d, want 7", n)
+	}
+	r1, r2, r3 := d.Replica(), d.Replica(), d.Replica()
+	if r1 == d.Primary() || r1 == r2 || r1 != r3 {
+		t.Error("应轮询只读副本")
+	}
+
+	mc, err := parseDSN(cfg, cfg.DSN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mc.Timeout != 2*time.Second || mc.ReadTimeout != 10*time.Second || mc.WriteTimeout != 0 {
+		t.Errorf("超时 = %v/%v/%v", mc.Timeout, mc.ReadTimeout, mc.WriteTimeout)
+	}
+	if mc, _ = parseDSN(&hdmodel.MySQL{}, "app@tcp(10.0.0.1)/orders"); mc.Timeout != defaultConnectTimeout {
+		t.Errorf("默认连接超时 = %v", mc.Timeout)
+	}
+
+	single, err := Open(&hdmodel.MySQL{DSN: "app@tcp(10.0.0.1)/orders"}, &Options{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer single.Close()
+	if single.Replica() != single.Primary() {
+		t.Error("没有副本时应返回主库")
+	}
+	if _, err := Open(&hdmodel.MySQL{DSN: "not a dsn"}, nil); err == nil {
+		t.Error("无效的 DSN 应返回错误")
+	}
+	if _, err := Open(&hdmodel.MySQL{DSN: cfg.DSN, Replicas: []string{"not a dsn"}}, nil); err == nil {
+		t.Error("无效的副本 DSN 应返回错误")
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	var logs bytes.Buffer
+	hlog.SetOutput(&logs)
+	t.Cleanup(func() { hlog.SetOutput(os.Stderr) })
+
+	reg := prometheus.NewRegistry()
+	m, err := newDBMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := sql.OpenDB(&connector{Connector: fakeConnector{}, ins: &instrument{name: "orders", role: RolePrimary, slow: 10 * time.Millisecond, metrics: m}})
+	defer pool.Close()
+
+	ctx := ctxx.WithRequestID(ctxx.WithTenantID(context.Background(), "t1"), "req-1")
+	if _, err := pool.ExecContext(ctx, "INSERT INTO orders (id) VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	// 带参数的语句走预处理，只记录一次
+	var n int
+	if err := pool.QueryRowContext(ctx, "select id from orders where id = ?", 1).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.QueryContext(ctx, "SELECT * FROM missing"); err == nil {
+		t.Fatal("应返回错误")
+	}
+	rows, err := pool.QueryContext(ctx, "SELECT SLEEP(0.02)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("span 数 = %d, want 4", len(spans))
+	}
+	if spans[0].Name() != "INSERT orders" || spans[1].Name() != "SELECT orders" {
+		t.Errorf("span 名称 = %s, %s", spans[0].Name(), spans[1].Name())
+	}
+	if spans[2].Status().Code != codes.Error {
+		t.Errorf("失败语句的 span 状态 = %v", spans[2].Status())
+	}
+	var statement string
+	for _, kv := range spans[1].Attributes() {
+		if kv.Key == "db.statement" {
+			statement = kv.Value.AsString()
+		}
+	}
+	if statement != "select id from orders where id = ?" {
+		t.Errorf("db.statement = %q", statement)
+	}
+
+	if got := testutil.ToFloat64(m.errors.WithLabelValues("orders", RolePrimary, "SELECT")); got != 1 {
+		t.Errorf("errors = %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(reg, "hertzcommon_db_query_duration_seconds"); n != 3 {
+		t.Errorf("duration 序列数 = %d, want 3", n)
+	}
+
+	out := logs.String()
+	if strings.Count(out, "慢查询") != 1 || !strings.Contains(out, "tenant: t1, request_id: req-1") || !strings.Contains(out, "SELECT SLEEP(0.02)") {
+		t.Errorf("慢查询日志 = %q", out)
+	}
+}
+
+func TestOperation(t *testing.T) {
+	for query, want := range map[string]string{
+		"  select 1":                    "SELECT",
+		"\n(SELECT 1) UNION (SELECT 2)": "SELECT",
+		"update t set a = 1":            "UPDATE",
+		"COMMIT":                        "COMMIT",
+		"OPTIMIZE TABLE t":              "OTHER",
+		"":                              "OTHER",
+	} {
+		if got := operation(query); got != want {
+			t.Errorf("operation(%q) = %s, want %s", query, got, want)
+		}
+	}
+}
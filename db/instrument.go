@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/monitor"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/grayscalecloud/hertzcommon/db"
+	// maxStatementLen Span 与慢查询日志中语句的最大长度
+	maxStatementLen = 2048
+)
+
+var errNamedArgs = errors.New("底层驱动不支持命名参数")
+
+// operations 作为指标标签的语句类型，其他语句归为 OTHER，避免标签基数过高
+var operations = map[string]bool{
+	"SELECT": true, "INSERT": true, "UPDATE": true, "DELETE": true, "REPLACE": true, "WITH": true,
+	"BEGIN": true, "COMMIT": true, "ROLLBACK": true, "SET": true, "SHOW": true, "CALL": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true,
+}
+
+// instrument 一个连接池的观测：Span、指标与慢查询日志
+type instrument struct {
+	name    string
+	role    string
+	slow    time.Duration
+	metrics *dbMetrics
+}
+
+// observe 记录一条从 start 开始、已经执行完成的语句；Span 使用实际的开始与结束时间
+func (i *instrument) observe(ctx context.Context, query string, start time.Time, err error) {
+	end := time.Now()
+	elapsed := end.Sub(start)
+	op := operation(query)
+	statement := truncate(query)
+
+	_, span := otel.Tracer(tracerName).Start(ctx, op+" "+i.name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			semconv.DBSystemMySQL,
+			semconv.DBNameKey.String(i.name),
+			semconv.DBOperationKey.String(op),
+			semconv.DBStatementKey.String(statement),
+			attribute.String("db.role", i.role),
+		))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+
+	if i.metrics != nil {
+		status := "ok"
+		if err != nil {
+			status = "error"
+		}
+		i.metrics.duration.WithLabelValues(i.name, i.role, op, status).Observe(elapsed.Seconds())
+		if err != nil {
+			i.metrics.errors.WithLabelValues(i.name, i.role, op).Inc()
+		}
+	}
+
+	if i.slow > 0 && elapsed >= i.slow {
+		hlog.CtxWarnf(ctx, "慢查询 [db: %s, role: %s, tenant: %s, request_id: %s, elapsed: %v, err: %v]: %s",
+			i.name, i.role, ctxx.GetTenantID(ctx), ctxx.GetRequestID(ctx), elapsed, err, statement)
+	}
+}
+
+// operation 返回语句的第一个关键字，跳过开头的空白与括号
+func operation(query string) string {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	end := strings.IndexAny(query, " \t\r\n(;")
+	if end < 0 {
+		end = len(query)
+	}
+	op := strings.ToUpper(query[:end])
+	if operations[op] {
+		return op
+	}
+	return "OTHER"
+}
+
+func truncate(query string) string {
+	if len(query) <= maxStatementLen {
+		return query
+	}
+	return query[:maxStatementLen] + "..."
+}
+
+type dbMetrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+func newDBMetrics(reg prometheus.Registerer) (*dbMetrics, error) {
+	m := &dbMetrics{}
+	var err error
+	if m.duration, err = monitor.RegisterOrExisting(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hertzcommon_db_query_duration_seconds",
+		Help:    "SQL 语句的执行耗时，查询不含读取结果集的时间",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"db", "role", "operation", "status"})); err != nil {
+		return nil, err
+	}
+	if m.errors, err = monitor.RegisterOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hertzcommon_db_query_errors_total",
+		Help: "执行失败的 SQL 语句数",
+	}, []string{"db", "role", "operation"})); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// registerStats 注册连接池状态指标（go_sql_*），同名连接池已注册时保留先注册的
+func (d *DB) registerStats() {
+	if d.reg == nil || d.stats != nil {
+		return
+	}
+	pools := map[string]*sql.DB{d.name + "_" + RolePrimary: d.primary}
+	for i, r := range d.replicas {
+		pools[d.name+"_"+RoleReplica+"_"+strconv.Itoa(i)] = r
+	}
+	for name, pool := range pools {
+		c := collectors.NewDBStatsCollector(pool, name)
+		if err := d.reg.Register(c); err != nil {
+			var are prometheus.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				hlog.Warnf("注册连接池指标失败 [%s]: %v", name, err)
+			}
+			continue
+		}
+		d.stats = append(d.stats, c)
+	}
+}
+
+// unregisterStats 注销连接池状态指标
+func (d *DB) unregisterStats() {
+	for _, c := range d.stats {
+		d.reg.Unregister(c)
+	}
+	d.stats = nil
+}
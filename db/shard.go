@@ -0,0 +1,476 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/kvconfig"
+	"github.com/grayscalecloud/hertzcommon/monitor"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 租户迁移模式，迁移的一般步骤：dual_write 后回填历史数据，校验无误后切到 read_new，
+// 观察一段时间后把 shard 改为新分片并去掉 migrate_to 与 mode
+const (
+	// MigrationDualWrite 读旧分片，同时写新旧分片，旧分片的写入结果为准
+	MigrationDualWrite = "dual_write"
+	// MigrationReadNew 读新分片，同时写新旧分片，新分片的写入结果为准
+	MigrationReadNew = "read_new"
+)
+
+const defaultCloseDelay = 30 * time.Second
+
+var (
+	// ErrNoTenant 上下文中没有租户 ID，无法确定分片
+	ErrNoTenant = errors.New("上下文中没有租户 ID，无法路由分片")
+	// ErrRouterClosed 分片路由已关闭，不再接受路由表更新
+	ErrRouterClosed = errors.New("分片路由已关闭")
+)
+
+// RouterOptions 分片路由的配置
+type RouterOptions struct {
+	// Registerer 注册指标的 Prometheus 注册表，默认 monitor.Reg；两者都为空时不记录指标
+	Registerer prometheus.Registerer
+	// CloseDelay 热更新后延迟关闭被替换的连接池，等待已经取得连接池的请求完成，默认 30 秒
+	CloseDelay time.Duration
+}
+
+// Router 按上下文中的租户 ID 把请求路由到分片，每个分片一个连接池（含只读副本）；
+// 分片的查询指标以分片名作为 db 标签
+type Router struct {
+	closeDelay time.Duration
+	reg        prometheus.Registerer
+	metrics    *shardMetrics
+	// open 创建分片的连接池，测试时替换
+	open func(name string, cfg *hdmodel.MySQL) (*DB, error)
+
+	mu     sync.Mutex // 串行化 Update 与 Close，保护 closed 与 retiring
+	closed bool
+	// retiring 热更新后等待延迟关闭的连接池，Close 时一并关闭
+	retiring map[*DB]struct{}
+	state    atomic.Pointer[routerState]
+}
+
+type routerState struct {
+	cfg   *hdmodel.ShardRouting
+	pools map[string]*DB
+}
+
+// Route 一个租户的路由结果
+type Route struct {
+	TenantID string
+	// ReadShard 读取使用的分片
+	ReadShard string
+	// WriteShards 写入的分片，第一个是以其写入结果为准的分片；迁移中有两个
+	WriteShards []string
+	// Mode 迁移模式，没有迁移时为空
+	Mode string
+
+	pools map[string]*DB
+}
+
+// Reader 读取使用的连接池
+func (r *Route) Reader() *DB {
+	return r.pools[r.ReadShard]
+}
+
+// Writer 以其写入结果为准的分片的连接池，事务只能在这个分片上执行
+func (r *Route) Writer() *DB {
+	return r.pools[r.WriteShards[0]]
+}
+
+// NewRouter 根据路由表创建分片路由，各分片的连接池在创建时打开
+func NewRouter(cfg *hdmodel.ShardRouting, opts *RouterOptions) (*Router, error) {
+	if opts == nil {
+		opts = &RouterOptions{}
+	}
+	r := &Router{closeDelay: opts.CloseDelay, reg: opts.Registerer, retiring: map[*DB]struct{}{}}
+	if r.closeDelay <= 0 {
+		r.closeDelay = defaultCloseDelay
+	}
+	if r.reg == nil && monitor.Reg != nil {
+		r.reg = monitor.Reg
+	}
+	if r.reg != nil {
+		m, err := newShardMetrics(r.reg)
+		if err != nil {
+			return nil, err
+		}
+		r.metrics = m
+	}
+	r.open = func(name string, cfg *hdmodel.MySQL) (*DB, error) {
+		return Open(cfg, &Options{Name: name, Registerer: r.reg})
+	}
+	if err := r.Update(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewWatchedRouter 从配置中心的 dbshards 配置创建分片路由，并在配置变化时热更新；新配置无效时保留旧的路由。
+// 路由 Close 后忽略配置变化
+func NewWatchedRouter(f *kvconfig.ConfigFactory, group string, opts *RouterOptions) (*Router, error) {
+	cfg, err := kvconfig.GetYamlConfig[hdmodel.ShardRouting](f, kvconfig.DBShardsDataId, group)
+	if err != nil {
+		return nil, fmt.Errorf("读取分片路由配置失败: %w", err)
+	}
+	r, err := NewRouter(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = kvconfig.WatchYamlConfig(f, kvconfig.DBShardsDataId, group, func(cfg *hdmodel.ShardRouting) {
+		if err := r.Update(cfg); errors.Is(err, ErrRouterClosed) {
+			return
+		} else if err != nil {
+			hlog.Errorf("分片路由配置无效，继续使用旧配置 [group: %s]: %v", group, err)
+			return
+		}
+		hlog.Infof("分片路由配置已更新 [group: %s, shards: %d, tenants: %d]", group, len(cfg.Shards), len(cfg.Tenants))
+	})
+	if err != nil {
+		_ = r.Close()
+		return nil, fmt.Errorf("监听分片路由配置失败: %w", err)
+	}
+	return r, nil
+}
+
+// Update 替换路由表：配置没有变化的分片沿用原来的连接池，新增或变化的分片打开新连接池，
+// 被替换或删除的连接池延迟 CloseDelay 后关闭；新配置无效或打开连接池失败时保留旧的路由表，
+// Close 之后返回 ErrRouterClosed
+func (r *Router) Update(cfg *hdmodel.ShardRouting) error {
+	cfg, err := normalizeRouting(cfg)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrRouterClosed
+	}
+	old := r.state.Load()
+	pools := make(map[string]*DB, len(cfg.Shards))
+	var opened []*DB
+	for name, mc := range cfg.Shards {
+		if old != nil {
+			if p, ok := old.pools[name]; ok && reflect.DeepEqual(old.cfg.Shards[name], mc) {
+				pools[name] = p
+				continue
+			}
+		}
+		mc := mc
+		p, err := r.open(name, &mc)
+		if err != nil {
+			for _, p := range opened {
+				_ = p.Close()
+			}
+			return fmt.Errorf("分片 %s: %w", name, err)
+		}
+		pools[name] = p
+		opened = append(opened, p)
+	}
+
+	var retired []*DB
+	if old != nil {
+		for name, p := range old.pools {
+			if pools[name] != p {
+				retired = append(retired, p)
+			}
+		}
+	}
+	// 被替换的连接池先注销状态指标，同名的新连接池才能注册
+	for _, p := range retired {
+		p.unregisterStats()
+	}
+	for _, p := range opened {
+		p.registerStats()
+	}
+	r.state.Store(&routerState{cfg: cfg, pools: pools})
+	r.metrics.update(cfg)
+
+	if len(retired) > 0 {
+		for _, p := range retired {
+			r.retiring[p] = struct{}{}
+		}
+		time.AfterFunc(r.closeDelay, func() { r.closeRetired(retired) })
+	}
+	return nil
+}
+
+// closeRetired 关闭延迟到期的连接池，已经被 Close 关闭的跳过
+func (r *Router) closeRetired(retired []*DB) {
+	r.mu.Lock()
+	var pending []*DB
+	for _, p := range retired {
+		if _, ok := r.retiring[p]; ok {
+			delete(r.retiring, p)
+			pending = append(pending, p)
+		}
+	}
+	r.mu.Unlock()
+	for _, p := range pending {
+		if err := p.Close(); err != nil {
+			hlog.Warnf("关闭分片连接池失败 [db: %s]: %v", p.name, err)
+		}
+	}
+}
+
+// normalizeRouting 校验路由表，并把租户专属 DSN 展开成名为 tenant-<租户 ID> 的分片；不修改传入的配置
+func normalizeRouting(cfg *hdmodel.ShardRouting) (*hdmodel.ShardRouting, error) {
+	if cfg == nil {
+		return nil, errors.New("分片路由配置为空")
+	}
+	out := &hdmodel.ShardRouting{
+		Shards:  make(map[string]hdmodel.MySQL, len(cfg.Shards)),
+		Default: cfg.Default,
+		Tenants: make(map[string]hdmodel.TenantShard, len(cfg.Tenants)),
+	}
+	for name, mc := range cfg.Shards {
+		out.Shards[name] = mc
+	}
+	for tenant, tr := range cfg.Tenants {
+		if tr.DSN != "" {
+			name := "tenant-" + tenant
+			if _, ok := out.Shards[name]; ok {
+				return nil, fmt.Errorf("租户 %s 的专属分片 %s 与已有分片重名", tenant, name)
+			}
+			out.Shards[name] = hdmodel.MySQL{DSN: tr.DSN}
+			tr.Shard, tr.DSN = name, ""
+		}
+		out.Tenants[tenant] = tr
+	}
+
+	if len(out.Shards) == 0 {
+		return nil, errors.New("分片路由配置中没有分片")
+	}
+	if _, ok := out.Shards[out.Default]; !ok {
+		return nil, fmt.Errorf("默认分片 %q 不存在", out.Default)
+	}
+	for tenant, tr := range out.Tenants {
+		if _, ok := out.Shards[tr.Shard]; !ok {
+			return nil, fmt.Errorf("租户 %s 的分片 %q 不存在", tenant, tr.Shard)
+		}
+		switch tr.Mode {
+		case "":
+			if tr.MigrateTo != "" {
+				return nil, fmt.Errorf("租户 %s 设置了 migrate_to 但没有设置迁移模式", tenant)
+			}
+		case MigrationDualWrite, MigrationReadNew:
+			if _, ok := out.Shards[tr.MigrateTo]; !ok {
+				return nil, fmt.Errorf("租户 %s 的迁移目标分片 %q 不存在", tenant, tr.MigrateTo)
+			}
+			if tr.MigrateTo == tr.Shard {
+				return nil, fmt.Errorf("租户 %s 的迁移目标分片与当前分片相同", tenant)
+			}
+		default:
+			return nil, fmt.Errorf("租户 %s 的迁移模式 %q 无效", tenant, tr.Mode)
+		}
+	}
+	return out, nil
+}
+
+// Route 返回上下文中租户的路由，没有单独路由的租户使用默认分片
+func (r *Router) Route(ctx context.Context) (*Route, error) {
+	tenant := ctxx.GetTenantID(ctx)
+	if tenant == "" {
+		return nil, ErrNoTenant
+	}
+	st := r.state.Load()
+	route := &Route{TenantID: tenant, pools: st.pools}
+	tr, ok := st.cfg.Tenants[tenant]
+	switch {
+	case !ok:
+		route.ReadShard = st.cfg.Default
+		route.WriteShards = []string{st.cfg.Default}
+	case tr.Mode == MigrationDualWrite:
+		route.ReadShard = tr.Shard
+		route.WriteShards = []string{tr.Shard, tr.MigrateTo}
+	case tr.Mode == MigrationReadNew:
+		route.ReadShard = tr.MigrateTo
+		route.WriteShards = []string{tr.MigrateTo, tr.Shard}
+	default:
+		route.ReadShard = tr.Shard
+		route.WriteShards = []string{tr.Shard}
+	}
+	route.Mode = tr.Mode
+	return route, nil
+}
+
+// Reader 返回租户读取使用的分片连接池
+func (r *Router) Reader(ctx context.Context) (*DB, error) {
+	route, err := r.Route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.metrics.route(route.ReadShard, "read")
+	return route.Reader(), nil
+}
+
+// Writer 返回租户以其写入结果为准的分片连接池；迁移中的租户通过它写入时不会同步写另一个分片，
+// 需要双写的语句应使用 Exec
+func (r *Router) Writer(ctx context.Context) (*DB, error) {
+	route, err := r.Route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.metrics.route(route.WriteShards[0], "write")
+	return route.Writer(), nil
+}
+
+// Exec 在租户的分片主库执行写语句；迁移中的租户依次写入两个分片，返回为准分片的结果，
+// 为准分片失败时不再写另一个分片，另一个分片失败时只记录日志与指标，由回填或校验修复
+func (r *Router) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	route, err := r.Route(ctx)
+	if err != nil {
+		return nil, err
+	}
+	primary := route.WriteShards[0]
+	r.metrics.route(primary, "write")
+	res, err := route.pools[primary].ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, shard := range route.WriteShards[1:] {
+		r.metrics.route(shard, "write")
+		if _, err := route.pools[shard].ExecContext(ctx, query, args...); err != nil {
+			r.metrics.dualWriteError(shard)
+			hlog.CtxErrorf(ctx, "迁移双写失败 [tenant: %s, shard: %s, mode: %s]: %v", route.TenantID, shard, route.Mode, err)
+		}
+	}
+	return res, nil
+}
+
+// QueryContext 在租户读取使用的分片主库查询
+func (r *Router) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	d, err := r.Reader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return d.QueryContext(ctx, query, args...)
+}
+
+// Shard 返回指定分片的连接池，用于迁移回填、运维等需要跨租户访问的场景
+func (r *Router) Shard(name string) (*DB, bool) {
+	d, ok := r.state.Load().pools[name]
+	return d, ok
+}
+
+// Shards 返回所有分片名
+func (r *Router) Shards() []string {
+	pools := r.state.Load().pools
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close 关闭所有分片的连接池，包括热更新后还在等待延迟关闭的连接池；之后的 Update 返回 ErrRouterClosed
+func (r *Router) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	var errs []error
+	for _, p := range r.state.Load().pools {
+		errs = append(errs, p.Close())
+	}
+	for p := range r.retiring {
+		errs = append(errs, p.Close())
+	}
+	clear(r.retiring)
+	return errors.Join(errs...)
+}
+
+type shardMetrics struct {
+	routes     *prometheus.CounterVec
+	tenants    *prometheus.GaugeVec
+	migrations *prometheus.GaugeVec
+	dualWrite  *prometheus.CounterVec
+	lastShards []string
+}
+
+func newShardMetrics(reg prometheus.Registerer) (*shardMetrics, error) {
+	m := &shardMetrics{}
+	var err error
+	if m.routes, err = monitor.RegisterOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hertzcommon_db_shard_routes_total",
+		Help: "路由到各分片的请求数，access 为 read 或 write",
+	}, []string{"shard", "access"})); err != nil {
+		return nil, err
+	}
+	if m.tenants, err = monitor.RegisterOrExisting(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hertzcommon_db_shard_tenants",
+		Help: "路由表中单独路由到各分片的租户数，不含使用默认分片的租户",
+	}, []string{"shard"})); err != nil {
+		return nil, err
+	}
+	if m.migrations, err = monitor.RegisterOrExisting(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hertzcommon_db_shard_migrations",
+		Help: "正在迁移的租户数",
+	}, []string{"from", "to", "mode"})); err != nil {
+		return nil, err
+	}
+	if m.dualWrite, err = monitor.RegisterOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hertzcommon_db_shard_dual_write_errors_total",
+		Help: "迁移双写时非为准分片写入失败的次数",
+	}, []string{"shard"})); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *shardMetrics) update(cfg *hdmodel.ShardRouting) {
+	if m == nil {
+		return
+	}
+	m.tenants.Reset()
+	m.migrations.Reset()
+	for name := range cfg.Shards {
+		m.tenants.WithLabelValues(name)
+	}
+	for _, tr := range cfg.Tenants {
+		m.tenants.WithLabelValues(tr.Shard).Inc()
+		if tr.Mode != "" {
+			m.migrations.WithLabelValues(tr.Shard, tr.MigrateTo, tr.Mode).Inc()
+		}
+	}
+	// 删除的分片不再保留请求计数
+	shards := make([]string, 0, len(cfg.Shards))
+	for name := range cfg.Shards {
+		shards = append(shards, name)
+	}
+	for _, name := range m.lastShards {
+		if !slices.Contains(shards, name) {
+			m.routes.DeletePartialMatch(prometheus.Labels{"shard": name})
+			m.dualWrite.DeleteLabelValues(name)
+		}
+	}
+	m.lastShards = shards
+}
+
+func (m *shardMetrics) route(shard, access string) {
+	if m != nil {
+		m.routes.WithLabelValues(shard, access).Inc()
+	}
+}
+
+func (m *shardMetrics) dualWriteError(shard string) {
+	if m != nil {
+		m.dualWrite.WithLabelValues(shard).Inc()
+	}
+}
@@ -0,0 +1,276 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// shardRecorder 记录各分片执行过的写语句，fail 中的分片写入失败
+type shardRecorder struct {
+	mu     sync.Mutex
+	writes []string
+	fail   map[string]bool
+}
+
+func (r *shardRecorder) exec(shard string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail[shard] {
+		return errors.New("Lost connection to MySQL server")
+	}
+	r.writes = append(r.writes, shard)
+	return nil
+}
+
+type shardConnector struct {
+	shard string
+	rec   *shardRecorder
+}
+
+func (c shardConnector) Connect(context.Context) (driver.Conn, error) { return shardConn(c), nil }
+func (c shardConnector) Driver() driver.Driver                        { return nil }
+
+type shardConn shardConnector
+
+func (c shardConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{}, nil }
+func (c shardConn) Close() error                              { return nil }
+func (c shardConn) Begin() (driver.Tx, error)                 { return nil, errors.New("不支持事务") }
+
+func (c shardConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.rec.exec(c.shard); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func newTestRouter(t *testing.T, cfg *hdmodel.ShardRouting, rec *shardRecorder) (*Router, *prometheus.Registry) {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	r, err := NewRouter(cfg, &RouterOptions{Registerer: reg, CloseDelay: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 换成不连接数据库的连接池后重新加载
+	r.open = func(name string, cfg *hdmodel.MySQL) (*DB, error) {
+		ins := &instrument{name: name, role: RolePrimary}
+		return &DB{primary: sql.OpenDB(&connector{Connector: shardConnector{shard: name, rec: rec}, ins: ins}), name: name, reg: reg}, nil
+	}
+	for _, p := range r.state.Load().pools {
+		p.Close()
+	}
+	r.state.Store(&routerState{cfg: &hdmodel.ShardRouting{}, pools: map[string]*DB{}})
+	if err := r.Update(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r, reg
+}
+
+func testRouting() *hdmodel.ShardRouting {
+	return &hdmodel.ShardRouting{
+		Shards: map[string]hdmodel.MySQL{
+			"s0": {DSN: "app:secret@tcp(10.0.0.1:3306)/orders"},
+			"s1": {DSN: "app:secret@tcp(10.0.0.2:3306)/orders"},
+		},
+		Default: "s0",
+		Tenants: map[string]hdmodel.TenantShard{
+			"big":    {Shard: "s1"},
+			"vip":    {DSN: "app:secret@tcp(10.0.1.1:3306)/orders"},
+			"moving": {Shard: "s0", MigrateTo: "s1", Mode: MigrationDualWrite},
+		},
+	}
+}
+
+func TestRouterRoute(t *testing.T) {
+	r, reg := newTestRouter(t, testRouting(), &shardRecorder{})
+
+	tests := []struct {
+		tenant     string
+		wantRead   string
+		wantWrites []string
+	}{
+		{"small", "s0", []string{"s0"}},
+		{"big", "s1", []string{"s1"}},
+		{"vip", "tenant-vip", []string{"tenant-vip"}},
+		{"moving", "s0", []string{"s0", "s1"}},
+	}
+	for _, tt := range tests {
+		route, err := r.Route(ctxx.WithTenantID(context.Background(), tt.tenant))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if route.ReadShard != tt.wantRead || !reflect.DeepEqual(route.WriteShards, tt.wantWrites) {
+			t.Errorf("%s: read = %s, writes = %v", tt.tenant, route.ReadShard, route.WriteShards)
+		}
+		if route.Reader() == nil || route.Reader().name != tt.wantRead {
+			t.Errorf("%s: 读连接池不正确", tt.tenant)
+		}
+	}
+
+	if _, err := r.Route(context.Background()); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("没有租户 err = %v", err)
+	}
+	if got := r.Shards(); !reflect.DeepEqual(got, []string{"s0", "s1", "tenant-vip"}) {
+		t.Errorf("Shards() = %v", got)
+	}
+	if got := testutil.ToFloat64(r.metrics.tenants.WithLabelValues("s1")); got != 1 {
+		t.Errorf("s1 租户数 = %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(reg, "hertzcommon_db_shard_migrations"); n != 1 {
+		t.Errorf("迁移序列数 = %d, want 1", n)
+	}
+}
+
+func TestRouterMigration(t *testing.T) {
+	rec := &shardRecorder{fail: map[string]bool{}}
+	r, _ := newTestRouter(t, testRouting(), rec)
+	ctx := ctxx.WithTenantID(context.Background(), "moving")
+
+	if _, err := r.Exec(ctx, "UPDATE orders SET amount = ? WHERE id = ?", 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rec.writes, []string{"s0", "s1"}) {
+		t.Errorf("dual_write 写入 = %v", rec.writes)
+	}
+
+	// 非为准分片失败只记录，不影响结果
+	rec.writes, rec.fail["s1"] = nil, true
+	if _, err := r.Exec(ctx, "DELETE FROM orders WHERE id = ?", 2); err != nil {
+		t.Fatalf("另一个分片失败不应返回错误: %v", err)
+	}
+	if got := testutil.ToFloat64(r.metrics.dualWrite.WithLabelValues("s1")); got != 1 {
+		t.Errorf("dual_write_errors_total = %v, want 1", got)
+	}
+
+	// read_new 以新分片为准，新分片失败时不写旧分片
+	cfg := testRouting()
+	cfg.Tenants["moving"] = hdmodel.TenantShard{Shard: "s0", MigrateTo: "s1", Mode: MigrationReadNew}
+	if err := r.Update(cfg); err != nil {
+		t.Fatal(err)
+	}
+	d, err := r.Reader(ctx)
+	if err != nil || d.name != "s1" {
+		t.Fatalf("read_new 读分片 = %v, %v", d, err)
+	}
+	rec.writes = nil
+	if _, err := r.Exec(ctx, "DELETE FROM orders WHERE id = ?", 2); err == nil {
+		t.Error("为准分片失败时应返回错误")
+	}
+	if len(rec.writes) != 0 {
+		t.Errorf("为准分片失败后仍写入了 %v", rec.writes)
+	}
+	rec.fail["s1"] = false
+	if _, err := r.Exec(ctx, "DELETE FROM orders WHERE id = ?", 2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rec.writes, []string{"s1", "s0"}) {
+		t.Errorf("read_new 写入 = %v", rec.writes)
+	}
+	if got := testutil.ToFloat64(r.metrics.routes.WithLabelValues("s1", "read")); got != 1 {
+		t.Errorf("s1 读路由数 = %v, want 1", got)
+	}
+}
+
+func TestRouterUpdate(t *testing.T) {
+	r, _ := newTestRouter(t, testRouting(), &shardRecorder{})
+	s0, _ := r.Shard("s0")
+	s1, _ := r.Shard("s1")
+
+	invalid := []func(*hdmodel.ShardRouting){
+		func(c *hdmodel.ShardRouting) { c.Default = "s9" },
+		func(c *hdmodel.ShardRouting) { c.Tenants["big"] = hdmodel.TenantShard{Shard: "s9"} },
+		func(c *hdmodel.ShardRouting) {
+			c.Tenants["big"] = hdmodel.TenantShard{Shard: "s1", MigrateTo: "s1", Mode: MigrationDualWrite}
+		},
+		func(c *hdmodel.ShardRouting) {
+			c.Tenants["big"] = hdmodel.TenantShard{Shard: "s1", MigrateTo: "s0", Mode: "copy"}
+		},
+		func(c *hdmodel.ShardRouting) { c.Tenants["big"] = hdmodel.TenantShard{Shard: "s1", MigrateTo: "s0"} },
+	}
+	for i, modify := range invalid {
+		cfg := testRouting()
+		modify(cfg)
+		if err := r.Update(cfg); err == nil {
+			t.Errorf("无效配置 %d 应返回错误", i)
+		}
+	}
+	if got, _ := r.Shard("s0"); got != s0 {
+		t.Error("无效配置不应替换连接池")
+	}
+
+	// s0 不变沿用连接池，s1 地址变化换成新连接池，旧连接池延迟关闭
+	cfg := testRouting()
+	cfg.Shards["s1"] = hdmodel.MySQL{DSN: "app:secret@tcp(10.0.0.3:3306)/orders"}
+	delete(cfg.Tenants, "vip")
+	if err := r.Update(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := r.Shard("s0"); got != s0 {
+		t.Error("配置没有变化的分片应沿用连接池")
+	}
+	if got, _ := r.Shard("s1"); got == s1 {
+		t.Error("配置变化的分片应打开新连接池")
+	}
+	if _, ok := r.Shard("tenant-vip"); ok {
+		t.Error("删除的专属分片仍然存在")
+	}
+	if err := s1.Primary().Ping(); err != nil {
+		t.Fatalf("旧连接池不应立即关闭: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := s1.Primary().Ping(); err == nil {
+		t.Error("旧连接池应在延迟后关闭")
+	}
+}
+
+func TestRouterClose(t *testing.T) {
+	r, _ := newTestRouter(t, testRouting(), &shardRecorder{})
+	r.closeDelay = time.Hour
+	s1, _ := r.Shard("s1")
+
+	cfg := testRouting()
+	cfg.Shards["s1"] = hdmodel.MySQL{DSN: "app:secret@tcp(10.0.0.3:3306)/orders"}
+	if err := r.Update(cfg); err != nil {
+		t.Fatal(err)
+	}
+	s1New, _ := r.Shard("s1")
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.Primary().Ping(); err == nil {
+		t.Error("Close 应关闭等待延迟关闭的连接池")
+	}
+	if err := s1New.Primary().Ping(); err == nil {
+		t.Error("Close 应关闭当前的连接池")
+	}
+
+	// 关闭后不再打开新的连接池
+	opened := 0
+	open := r.open
+	r.open = func(name string, cfg *hdmodel.MySQL) (*DB, error) {
+		opened++
+		return open(name, cfg)
+	}
+	cfg.Shards["s1"] = hdmodel.MySQL{DSN: "app:secret@tcp(10.0.0.4:3306)/orders"}
+	if err := r.Update(cfg); !errors.Is(err, ErrRouterClosed) {
+		t.Errorf("关闭后 Update err = %v", err)
+	}
+	if opened != 0 {
+		t.Errorf("关闭后仍打开了 %d 个连接池", opened)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("重复 Close err = %v", err)
+	}
+}
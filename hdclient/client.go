@@ -0,0 +1,71 @@
+package hdclient
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/grayscalecloud/hertzcommon/monitor"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/grayscalecloud/hertzcommon/signature"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// Options 出站 HTTP 客户端配置
+type Options struct {
+	// Timeout 单次请求超时（含连接、写入与读取），默认 5 秒；ctx 的截止时间更早时以 ctx 为准
+	Timeout time.Duration
+	// Signer 服务间请求签名，为空时不签名
+	Signer *signature.Signer
+	// Registerer 注册 RED 指标的 Prometheus 注册表，默认 monitor.Reg；两者都为空时不记录指标
+	Registerer prometheus.Registerer
+	// Propagator 链路上下文传播器，默认 W3C Trace Context 与 Baggage
+	Propagator propagation.TextMapPropagator
+	// Baggage 出站前把 ctxx 字段写入 baggage 的桥接，为空时沿用请求 ctx 上的桥接（如服务端中间件设置的）
+	Baggage *ctxx.BaggageBridge
+	// ClientOptions 透传给 Hertz 客户端的配置，如连接池大小、拨号超时等
+	ClientOptions []config.ClientOption
+}
+
+// New 创建出站 HTTP 客户端
+//
+// 中间件依次为：RED 指标、超时、baggage 桥接、客户端 Span 与 W3C 链路头、ctxx 身份头、请求签名，
+// 下游服务可以看到与当前请求相同的租户/商户/用户/请求 ID/应用类型
+func New(opts *Options) (*client.Client, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	c, err := client.NewClient(opts.ClientOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("创建 HTTP 客户端失败: %w", err)
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	reg := opts.Registerer
+	if reg == nil && monitor.Reg != nil {
+		reg = monitor.Reg
+	}
+	if reg != nil {
+		mw, err := MetricsMiddleware(reg)
+		if err != nil {
+			return nil, err
+		}
+		c.Use(mw)
+	}
+	c.Use(TimeoutMiddleware(timeout))
+	if opts.Baggage != nil {
+		c.Use(BaggageMiddleware(opts.Baggage))
+	}
+	c.Use(TracingMiddleware(opts.Propagator), ContextMiddleware())
+	if opts.Signer != nil {
+		c.Use(opts.Signer.ClientMiddleware())
+	}
+	return c, nil
+}
@@ -0,0 +1,150 @@
+package hdclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/grayscalecloud/hertzcommon/monitor"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/grayscalecloud/hertzcommon/signature"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestClientPropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	secrets := signature.StaticSecrets{"order-svc": {"s1"}}
+	verifier := signature.NewVerifier(secrets, nil)
+	var (
+		mu        sync.Mutex
+		got       http.Header
+		verifyErr error
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 用 Hertz 请求重建签名校验
+		req := protocol.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+		for k, vs := range r.Header {
+			req.Header.Set(k, vs[0])
+		}
+		_, err := verifier.Verify(r.Context(), req)
+		mu.Lock()
+		got, verifyErr = r.Header.Clone(), err
+		mu.Unlock()
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	c, err := New(&Options{
+		Timeout:    100 * time.Millisecond,
+		Signer:     signature.NewSigner("order-svc", secrets),
+		Registerer: reg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "handler")
+	ctx = ctxx.WithTenantID(ctx, "t1")
+	ctx = ctxx.WithMerchantID(ctx, "m1")
+	ctx = ctxx.WithUserID(ctx, "u1")
+	ctx = ctxx.WithAppType(ctx, "pos")
+	ctx = ctxx.WithRequestID(ctx, "req-1")
+	ctx = metainfo.WithPersistentValue(ctx, "trace_tag", "canary")
+
+	req, resp := protocol.AcquireRequest(), protocol.AcquireResponse()
+	defer protocol.ReleaseRequest(req)
+	defer protocol.ReleaseResponse(resp)
+	req.SetRequestURI(srv.URL + "/orders?id=1")
+	req.SetMethod(http.MethodGet)
+	if err := c.Do(ctx, req, resp); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	mu.Lock()
+	headers, verified := got, verifyErr
+	mu.Unlock()
+	for header, want := range map[string]string{
+		ctxx.HeaderTenantID: "t1", ctxx.HeaderMerchantID: "m1", ctxx.HeaderUserID: "u1",
+		ctxx.HeaderAppType: "pos", ctxx.HeaderRequestID: "req-1", signature.HeaderCaller: "order-svc",
+		"Rpc-Persist-Trace-Tag": "canary",
+	} {
+		if v := headers.Get(header); v != want {
+			t.Errorf("%s = %q, want %q", header, v, want)
+		}
+	}
+	if verified != nil {
+		t.Errorf("下游签名校验失败: %v", verified)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].SpanKind() != trace.SpanKindClient {
+		t.Fatalf("spans = %d", len(spans))
+	}
+	client := spans[0]
+	if client.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("客户端 Span 应挂在当前 Span 下")
+	}
+	if tp := headers.Get("Traceparent"); !strings.Contains(tp, client.SpanContext().TraceID().String()) ||
+		!strings.Contains(tp, client.SpanContext().SpanID().String()) {
+		t.Errorf("traceparent = %q", tp)
+	}
+
+	peer := strings.TrimPrefix(srv.URL, "http://")
+	if n := testutil.ToFloat64(mustCounter(t, reg, peer, "202")); n != 1 {
+		t.Errorf("requests_total{202} = %v, want 1", n)
+	}
+
+	req.SetRequestURI(srv.URL + "/slow")
+	if err := c.Do(ctx, req, resp); err == nil {
+		t.Error("请求应超时")
+	}
+	if n := testutil.ToFloat64(mustCounter(t, reg, peer, "error")); n != 1 {
+		t.Errorf("requests_total{error} = %v, want 1", n)
+	}
+
+	expired, cancel := context.WithTimeout(ctx, -time.Second)
+	defer cancel()
+	req.SetRequestURI(srv.URL + "/orders")
+	if err := c.Do(expired, req, resp); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ctx 已过期 err = %v", err)
+	}
+
+	// 同一注册表上创建多个客户端复用指标
+	if _, err := New(&Options{Registerer: reg}); err != nil {
+		t.Errorf("重复创建客户端失败: %v", err)
+	}
+}
+
+func mustCounter(t *testing.T, reg *prometheus.Registry, peer, status string) prometheus.Collector {
+	t.Helper()
+	c, err := monitor.RegisterOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hertzcommon_client_requests_total",
+		Help: "出站 HTTP 请求数，status 为 HTTP 状态码或 error",
+	}, []string{"peer", "method", "status"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.WithLabelValues(peer, http.MethodGet, status)
+}
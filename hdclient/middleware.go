@@ -0,0 +1,172 @@
+package hdclient
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/grayscalecloud/hertzcommon/monitor"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/grayscalecloud/hertzcommon/hdclient"
+
+// ContextMiddleware 按 ctxx 字段的传递策略把身份信息、请求 ID 与自定义字段写入请求头，调用方显式设置的请求头不会被覆盖；
+// 其余 metainfo 持久值以 rpc-persist- 请求头继续向下游传递，本服务设置的临时值以 rpc-transit- 请求头只传一跳
+func ContextMiddleware() client.Middleware {
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			for k, v := range metainfo.GetAllPersistentValues(ctx) {
+				if !hasHeader(k) {
+					req.Header.Set(metainfo.HTTPPrefixPersistent+metainfo.CGIVariableToHTTPHeader(k), v)
+				}
+			}
+			// TransferForward 后只剩本服务设置的临时值
+			for k, v := range metainfo.GetAllValues(metainfo.TransferForward(ctx)) {
+				if !hasHeader(k) {
+					req.Header.Set(metainfo.HTTPPrefixTransient+metainfo.CGIVariableToHTTPHeader(k), v)
+				}
+			}
+			ctxx.InjectHeaders(ctx, func(key, value string) {
+				if len(req.Header.Peek(key)) == 0 {
+					req.Header.Set(key, value)
+				}
+			})
+			return next(ctx, req, resp)
+		}
+	}
+}
+
+// hasHeader 已注册且配置了请求头的 ctxx 字段通过自己的请求头传递
+func hasHeader(key string) bool {
+	k, ok := ctxx.LookupKey(key)
+	return ok && k.Options().Header != ""
+}
+
+// BaggageMiddleware 把 ctxx 中的映射键写入 baggage，由 TracingMiddleware 的传播器注入 baggage 请求头，需在其之前
+func BaggageMiddleware(b *ctxx.BaggageBridge) client.Middleware {
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			return next(b.Mirror(ctx), req, resp)
+		}
+	}
+}
+
+// TimeoutMiddleware 为请求设置超时，ctx 的截止时间更早时以 ctx 为准
+func TimeoutMiddleware(timeout time.Duration) client.Middleware {
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			d := timeout
+			if deadline, ok := ctx.Deadline(); ok {
+				if remaining := time.Until(deadline); remaining < d {
+					d = remaining
+				}
+			}
+			if d <= 0 {
+				return context.DeadlineExceeded
+			}
+			if existing := req.Options().RequestTimeout(); existing <= 0 || existing > d {
+				req.SetOptions(config.WithRequestTimeout(d))
+			}
+			return next(ctx, req, resp)
+		}
+	}
+}
+
+// TracingMiddleware 创建客户端 Span 并注入链路头，未启用 OTel 时 Span 为空操作，但仍会透传上游的链路上下文
+func TracingMiddleware(propagator propagation.TextMapPropagator) client.Middleware {
+	if propagator == nil {
+		propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+	tracer := otel.Tracer(tracerName)
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			ctx, span := tracer.Start(ctx, string(req.Method())+" "+string(req.URI().Path()),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.HTTPMethodKey.String(string(req.Method())),
+					semconv.HTTPURLKey.String(req.URI().String()),
+					semconv.NetPeerNameKey.String(string(req.Host())),
+				))
+			defer span.End()
+
+			propagator.Inject(ctx, &headerCarrier{header: &req.Header})
+			err := next(ctx, req, resp)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				return err
+			}
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode()))
+			if resp.StatusCode() >= 500 {
+				span.SetStatus(codes.Error, strconv.Itoa(resp.StatusCode()))
+			}
+			return nil
+		}
+	}
+}
+
+// MetricsMiddleware 记录出站请求的请求数、错误与耗时（RED），按目标主机、方法与状态分组
+func MetricsMiddleware(reg prometheus.Registerer) (client.Middleware, error) {
+	requests, err := monitor.RegisterOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hertzcommon_client_requests_total",
+		Help: "出站 HTTP 请求数，status 为 HTTP 状态码或 error",
+	}, []string{"peer", "method", "status"}))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := monitor.RegisterOrExisting(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hertzcommon_client_request_duration_seconds",
+		Help:    "出站 HTTP 请求耗时",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"peer", "method", "status"}))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			start := time.Now()
+			err := next(ctx, req, resp)
+			status := "error"
+			if err == nil {
+				status = strconv.Itoa(resp.StatusCode())
+			}
+			peer, method := string(req.Host()), string(req.Method())
+			requests.WithLabelValues(peer, method, status).Inc()
+			duration.WithLabelValues(peer, method, status).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}, nil
+}
+
+// headerCarrier 适配 Hertz 请求头的 TextMapCarrier
+type headerCarrier struct {
+	header *protocol.RequestHeader
+}
+
+func (h *headerCarrier) Get(key string) string {
+	return h.header.Get(key)
+}
+
+func (h *headerCarrier) Set(key, value string) {
+	h.header.Set(key, value)
+}
+
+func (h *headerCarrier) Keys() []string {
+	var keys []string
+	h.header.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}
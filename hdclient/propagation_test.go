@@ -0,0 +1,158 @@
+package hdclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/hdserver"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	testGrayKey = ctxx.MustRegisterKey("test_gray_tag", ctxx.KeyOptions{Header: "X-Test-Gray", Propagation: ctxx.PropagationPersistent})
+	testHopKey  = ctxx.MustRegisterKey("test_hop_note", ctxx.KeyOptions{Header: "X-Test-Hop", Propagation: ctxx.PropagationTransient})
+)
+
+// hopRecord 某一跳服务看到的上下文
+type hopRecord struct {
+	meta      map[string]string
+	isolation bool
+}
+
+func TestThreeHopPropagation(t *testing.T) {
+	c, err := New(&Options{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	records := map[string]hopRecord{}
+	record := func(name string, ctx context.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+		records[name] = hopRecord{meta: ctxx.GetAllMetaInfo(ctx), isolation: ctxx.IsTenantIsolationEnabled(ctx)}
+	}
+	call := func(ctx context.Context, url string) error {
+		req, resp := protocol.AcquireRequest(), protocol.AcquireResponse()
+		defer protocol.ReleaseRequest(req)
+		defer protocol.ReleaseResponse(resp)
+		req.SetRequestURI(url)
+		req.SetMethod(http.MethodGet)
+		return c.Do(ctx, req, resp)
+	}
+
+	addrA, addrB, addrC := freeAddr(t), freeAddr(t), freeAddr(t)
+	hops := []*server.Hertz{
+		newHop(addrC, func(ctx context.Context, c *app.RequestContext) {
+			record("C", ctx)
+		}),
+		newHop(addrB, func(ctx context.Context, c *app.RequestContext) {
+			record("B", ctx)
+			if err := call(ctx, "http://"+addrC+"/"); err != nil {
+				c.String(consts.StatusBadGateway, err.Error())
+			}
+		}),
+		newHop(addrA, func(ctx context.Context, c *app.RequestContext) {
+			record("A", ctx)
+			// 本地字段与本跳设置的临时字段
+			ctx = ctxx.WithTenantIsolation(ctx, false)
+			ctx = testHopKey.With(ctx, "from-a")
+			if err := call(ctx, "http://"+addrB+"/"); err != nil {
+				c.String(consts.StatusBadGateway, err.Error())
+			}
+		}),
+	}
+	for i, h := range hops {
+		h := h
+		go func() { _ = h.Run() }()
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			_ = h.Shutdown(ctx)
+		})
+		waitListening(t, []string{addrC, addrB, addrA}[i])
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+addrA+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(ctxx.HeaderTenantID, "t1")
+	req.Header.Set(ctxx.HeaderUserID, "u1")
+	req.Header.Set("X-Test-Gray", "canary")
+	req.Header.Set("X-Test-Hop", "from-client")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, hop := range []string{"A", "B", "C"} {
+		r, ok := records[hop]
+		if !ok {
+			t.Fatalf("%s 未收到请求", hop)
+		}
+		// 持久字段沿调用链逐跳传递
+		if r.meta[ctxx.TenantKey] != "t1" || r.meta[ctxx.UserKey] != "u1" || r.meta[testGrayKey.Name()] != "canary" {
+			t.Errorf("%s: 持久字段 = %v", hop, r.meta)
+		}
+		// 本地字段不会传给下游
+		if !r.isolation {
+			t.Errorf("%s: 租户隔离开关不应被上游关闭", hop)
+		}
+	}
+	// 临时字段只传一跳：上游传入的值不再继续向下游传递
+	for hop, want := range map[string]string{"A": "from-client", "B": "from-a", "C": ""} {
+		if got := records[hop].meta[testHopKey.Name()]; got != want {
+			t.Errorf("%s: %s = %q, want %q", hop, testHopKey.Name(), got, want)
+		}
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// newHop 创建信任本机来源的服务
+func newHop(addr string, handler app.HandlerFunc) *server.Hertz {
+	h := hdserver.NewHdServer(&hdmodel.Hertz{
+		Service: "hop",
+		Address: addr,
+		Context: hdmodel.ContextExtract{TrustedCIDRs: []string{"127.0.0.0/8"}},
+	}, &hdmodel.Monitor{})
+	h.GET("/", handler)
+	return h
+}
+
+func waitListening(t *testing.T, addr string) {
+	t.Helper()
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("服务未启动: %s", addr)
+}
@@ -1,7 +1,85 @@
 package hdmodel
 
+import "time"
+
 type MySQL struct {
+	// DSN 主库地址，格式见 github.com/go-sql-driver/mysql
+	DSN string `yaml:"dsn"`
+	// Replicas 只读副本的 DSN，为空时读请求也走主库
+	Replicas []string `yaml:"replicas"`
+	// MaxOpenConns 每个连接池的最大连接数，默认 50
+	MaxOpenConns int `yaml:"max_open_conns"`
+	// MaxIdleConns 每个连接池的最大空闲连接数，默认 10
+	MaxIdleConns int `yaml:"max_idle_conns"`
+	// ConnMaxLifetime 连接的最长存活时间，默认 30 分钟，应小于 MySQL 的 wait_timeout
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	// ConnMaxIdleTime 连接的最长空闲时间，默认 5 分钟
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// ConnectTimeout 建立连接的超时，默认 5 秒；DSN 中的 timeout 参数优先
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	// ReadTimeout 读超时，DSN 中的 readTimeout 参数优先
+	ReadTimeout time.Duration `yaml:"read_timeout"`
+	// WriteTimeout 写超时，DSN 中的 writeTimeout 参数优先
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// SlowThreshold 慢查询日志的阈值，默认 200 毫秒，小于 0 时不记录
+	SlowThreshold time.Duration `yaml:"slow_threshold"`
+}
+
+// ShardRouting 租户到数据库分片的路由表，通常放在配置中心的 dbshards 配置中
+type ShardRouting struct {
+	// Shards 分片名到连接配置
+	Shards map[string]MySQL `yaml:"shards"`
+	// Default 没有单独路由的租户使用的分片
+	Default string `yaml:"default"`
+	// Tenants 租户 ID 到分片的路由
+	Tenants map[string]TenantShard `yaml:"tenants"`
+}
+
+// TenantShard 一个租户的分片路由
+type TenantShard struct {
+	// Shard 租户所在的分片
+	Shard string `yaml:"shard"`
+	// DSN 租户专属实例的地址，设置后使用名为 tenant-<租户 ID> 的分片，忽略 Shard
 	DSN string `yaml:"dsn"`
+	// MigrateTo 迁移的目标分片，配合 Mode 使用
+	MigrateTo string `yaml:"migrate_to"`
+	// Mode 迁移模式：dual_write 读旧分片、同时写新旧分片；read_new 读新分片、同时写新旧分片
+	Mode string `yaml:"mode"`
+}
+
+// Storage 对象存储配置，通常放在配置中心的 storage 配置中，便于轮换密钥
+type Storage struct {
+	// Type 存储类型：local 或 s3
+	Type  string       `yaml:"type"`
+	Local LocalStorage `yaml:"local"`
+	S3    S3Storage    `yaml:"s3"`
+	// URLExpiry 签名地址的默认有效期，默认 15 分钟
+	URLExpiry time.Duration `yaml:"url_expiry"`
+	// PublicURL 公开访问的地址前缀（如 CDN），设置后 URL 返回不签名的公开地址
+	PublicURL string `yaml:"public_url"`
+}
+
+// LocalStorage 本地文件系统存储
+type LocalStorage struct {
+	// Root 文件根目录
+	Root string `yaml:"root"`
+	// BaseURL 签名地址的前缀，指向挂载了 storage.Local.Handler 的路由，如 https://api.example.com/files
+	BaseURL string `yaml:"base_url"`
+	// SignKey 签名地址的 HMAC 密钥
+	SignKey string `yaml:"sign_key"`
+}
+
+// S3Storage S3 兼容的对象存储（AWS S3、MinIO、OSS、COS 等）
+type S3Storage struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	// UseSSL 是否使用 HTTPS
+	UseSSL bool `yaml:"use_ssl"`
+	// PathStyle 使用 path-style 地址（endpoint/bucket/key），MinIO 通常需要开启
+	PathStyle bool `yaml:"path_style"`
 }
 
 type Redis struct {
@@ -22,7 +100,57 @@ type Hertz struct {
 	LogMaxSize      int    `yaml:"log_max_size"`
 	LogMaxBackups   int    `yaml:"log_max_backups"`
 	LogMaxAge       int    `yaml:"log_max_age"`
+	// DisableRequestIDLog 未启用 OTel 时默认包装当前的 hlog 日志器，在带 Context 的日志中加上 request_id 前缀；
+	// 设置为 true 时不包装
+	DisableRequestIDLog bool `yaml:"disable_request_id_log"`
+	TLS                 TLS  `yaml:"tls"`
+	// Context 从请求头提取 ctxx 元数据的规则
+	Context ContextExtract `yaml:"context"`
+}
+
+// ContextExtract 入站请求头到 ctxx 的提取规则
+//
+// 来自可信网关（trusted_cidrs 网段或 trusted_peers 中的 mTLS 客户端）的请求可以设置 gateway_keys 中的键，
+// 其他请求只能设置 public_keys 中的键，防止公网客户端伪造租户与用户
+type ContextExtract struct {
+	// Headers 覆盖默认的请求头名称，键为 ctxx 键名，如 tenant_id: X-Org-Id
+	Headers map[string]string `yaml:"headers"`
+	// TrustedCIDRs 可信网关的网段，按连接的对端地址判断，不读取 X-Forwarded-For
+	TrustedCIDRs []string `yaml:"trusted_cidrs"`
+	// TrustedPeers 可信网关的 mTLS 证书 CommonName 或 URI SAN
+	TrustedPeers []string `yaml:"trusted_peers"`
+	// GatewayKeys 可信网关可以设置的键，默认 tenant_id、merchant_id、user_id、app_type、request_id
+	GatewayKeys []string `yaml:"gateway_keys"`
+	// PublicKeys 其他请求可以设置的键，默认只有 request_id
+	PublicKeys []string `yaml:"public_keys"`
+	// Baggage 与 W3C Baggage 互通，供通过 OTel baggage 传递身份的非 Hertz 服务使用
+	Baggage BaggageBridge `yaml:"baggage"`
+}
+
+// BaggageBridge ctxx 与 W3C Baggage 的映射，入站 baggage 同样受 gateway_keys/public_keys 限制
+type BaggageBridge struct {
+	Enable bool `yaml:"enable"`
+	// Mapping ctxx 键 -> baggage 键，如 tenant_id: tenant.id，默认同名映射
+	Mapping map[string]string `yaml:"mapping"`
+	// Allow 允许从入站 baggage 读取的 ctxx 键，默认 mapping 中的全部键
+	Allow []string `yaml:"allow"`
 }
+
+// TLS 服务端 TLS 配置，证书文件变化后自动重新加载
+type TLS struct {
+	Enable   bool   `yaml:"enable"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// CAFile 校验客户端证书的 CA，开启 mTLS 时必填
+	CAFile string `yaml:"ca_file"`
+	// MinVersion 最低 TLS 版本：1.2（默认）或 1.3
+	MinVersion string `yaml:"min_version"`
+	// ClientAuth 客户端证书模式：none（默认）、request、require、verify_if_given、require_and_verify
+	ClientAuth string `yaml:"client_auth"`
+	// ReloadInterval 检查证书文件变化的间隔，默认 30s
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
 type Kitex struct {
 	Service         string `yaml:"service"`
 	Address         string `yaml:"address"`
@@ -1,19 +1,88 @@
 package hdmodel
 
+import "encoding/json"
+
+// MQ 消息类型，对应 mq.Envelope 的 type 字段
+const (
+	MqTypeUserImage       = "user_image"
+	MqTypeUserImageResult = "user_image_result"
+	MqTypeActivity        = "activity"
+)
+
+// UserImageMqDto 生成用户头像，version 2 起使用 snake_case 字段名。
+// 直接 json.Unmarshal 时仍兼容旧的字段名 UserID、TenantId，编码只输出新字段名
 type UserImageMqDto struct {
-	UserID   int64
-	TenantId string
+	UserID   int64  `json:"user_id"`
+	TenantId string `json:"tenant_id"`
 }
 
+func (d *UserImageMqDto) UnmarshalJSON(data []byte) error {
+	type plain UserImageMqDto
+	var v struct {
+		plain
+		LegacyUserID   *int64  `json:"UserID"`
+		LegacyTenantId *string `json:"TenantId"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*d = UserImageMqDto(v.plain)
+	legacy(&d.UserID, v.LegacyUserID)
+	legacy(&d.TenantId, v.LegacyTenantId)
+	return nil
+}
+
+// UserImageResultMqDto 头像生成结果。
+// 直接 json.Unmarshal 时仍兼容旧的字段名 TenantId，编码只输出新字段名
 type UserImageResultMqDto struct {
 	UserID    int64  `json:"user_id"`
 	AvatarUrl string `json:"avatar_url"`
 	Success   bool   `json:"success"`
 	Error     string `json:"error,omitempty"`
-	TenantId  string
+	TenantId  string `json:"tenant_id"`
+}
+
+func (d *UserImageResultMqDto) UnmarshalJSON(data []byte) error {
+	type plain UserImageResultMqDto
+	var v struct {
+		plain
+		LegacyTenantId *string `json:"TenantId"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*d = UserImageResultMqDto(v.plain)
+	legacy(&d.TenantId, v.LegacyTenantId)
+	return nil
 }
 
+// ActivityMqDto 活动变更。
+// 直接 json.Unmarshal 时仍兼容旧的字段名 ActivityId、TenantId，编码只输出新字段名
 type ActivityMqDto struct {
-	ActivityId string
-	TenantId   string
+	ActivityId string `json:"activity_id"`
+	TenantId   string `json:"tenant_id"`
+}
+
+func (d *ActivityMqDto) UnmarshalJSON(data []byte) error {
+	type plain ActivityMqDto
+	var v struct {
+		plain
+		LegacyActivityId *string `json:"ActivityId"`
+		LegacyTenantId   *string `json:"TenantId"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*d = ActivityMqDto(v.plain)
+	legacy(&d.ActivityId, v.LegacyActivityId)
+	legacy(&d.TenantId, v.LegacyTenantId)
+	return nil
+}
+
+// legacy 新字段名没有值时使用旧字段名的值
+func legacy[T comparable](field *T, old *T) {
+	var zero T
+	if old != nil && *field == zero {
+		*field = *old
+	}
 }
@@ -1,10 +1,39 @@
 package hdmodel
 
+// Paseto 密钥状态
+const (
+	// PasetoKeyActive 当前用于签名的密钥，密钥集中只能有一个
+	PasetoKeyActive = "active"
+	// PasetoKeyRetired 已退役的密钥，只用于校验/解密存量令牌
+	PasetoKeyRetired = "retired"
+)
+
+// PasetoConfig Paseto 公钥配置
+// 兼容单公钥写法（pub_key），也可以通过 keys 配置多把带 kid 的公钥用于轮换
 type PasetoConfig struct {
-	PubKey   string `mapstructure:"pub_key" json:"pub_key" yaml:"pub_key"`
-	Implicit string `mapstructure:"implicit" json:"implicit" yaml:"implicit"`
+	PubKey   string         `mapstructure:"pub_key" json:"pub_key" yaml:"pub_key"`
+	Implicit string         `mapstructure:"implicit" json:"implicit" yaml:"implicit"`
+	Keys     []PasetoPubKey `mapstructure:"keys" json:"keys" yaml:"keys"`
+}
+
+// PasetoPubKey 公钥集中的单把公钥
+type PasetoPubKey struct {
+	Kid    string `mapstructure:"kid" json:"kid" yaml:"kid"`
+	PubKey string `mapstructure:"pub_key" json:"pub_key" yaml:"pub_key"`
+	Status string `mapstructure:"status" json:"status" yaml:"status"`
 }
+
+// PasetoSecretConfig Paseto 私钥配置
+// 兼容单私钥写法（secret_key），也可以通过 keys 配置多把带 kid 的私钥，其中 status 为 active 的用于签名
 type PasetoSecretConfig struct {
+	SecretKey string            `mapstructure:"secret_key" json:"secret_key" yaml:"secret_key"`
+	Implicit  string            `mapstructure:"implicit" json:"implicit" yaml:"implicit"`
+	Keys      []PasetoSecretKey `mapstructure:"keys" json:"keys" yaml:"keys"`
+}
+
+// PasetoSecretKey 私钥集中的单把私钥
+type PasetoSecretKey struct {
+	Kid       string `mapstructure:"kid" json:"kid" yaml:"kid"`
 	SecretKey string `mapstructure:"secret_key" json:"secret_key" yaml:"secret_key"`
-	Implicit  string `mapstructure:"implicit" json:"implicit" yaml:"implicit"`
+	Status    string `mapstructure:"status" json:"status" yaml:"status"`
 }
@@ -0,0 +1,156 @@
+package hdserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/grayscalecloud/hertzcommon/apikey"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+)
+
+// createApiKeyRequest 创建 API Key 的请求体
+type createApiKeyRequest struct {
+	Name       string    `json:"name"`
+	TenantID   string    `json:"tenant_id"`
+	MerchantID string    `json:"merchant_id"`
+	Scopes     []string  `json:"scopes"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// createApiKeyResponse 创建 API Key 的响应，明文密钥只返回这一次
+type createApiKeyResponse struct {
+	*apikey.Key
+	Secret string `json:"secret"`
+}
+
+// RegisterApiKeyAdminRoutes 在路由组上注册 API Key 管理接口：
+//
+//	GET    /        列出密钥（不含摘要）
+//	POST   /        创建密钥，返回明文
+//	DELETE /:id     删除密钥
+//
+// 管理接口本身不做鉴权，调用方应在路由组上挂载 PASETO 鉴权与 authz.RequireRole 等中间件。
+// 平台管理员（ctxx.IsAdmin）可以管理所有租户的密钥；其他调用方只能管理上下文中租户的密钥，
+// 创建时 tenant_id 固定为该租户，上下文中没有租户时返回 403
+func RegisterApiKeyAdminRoutes(g *route.RouterGroup, store apikey.Store) {
+	g.GET("", func(ctx context.Context, c *app.RequestContext) {
+		tenant, ok := adminTenant(ctx, c)
+		if !ok {
+			return
+		}
+		keys, err := store.List(ctx)
+		if err != nil {
+			hlog.CtxErrorf(ctx, "列出 API Key 失败: %v", err)
+			AbortWithError(ctx, c, consts.StatusInternalServerError, "列出 API Key 失败")
+			return
+		}
+		if tenant != "" {
+			own := make([]*apikey.Key, 0, len(keys))
+			for _, k := range keys {
+				if k.TenantID == tenant {
+					own = append(own, k)
+				}
+			}
+			keys = own
+		}
+		c.JSON(consts.StatusOK, keys)
+	})
+
+	g.POST("", func(ctx context.Context, c *app.RequestContext) {
+		var req createApiKeyRequest
+		if err := c.BindJSON(&req); err != nil {
+			AbortWithError(ctx, c, consts.StatusBadRequest, "请求参数错误")
+			return
+		}
+		tenant, ok := adminTenant(ctx, c)
+		if !ok {
+			return
+		}
+		if tenant != "" {
+			if req.TenantID != "" && req.TenantID != tenant {
+				AbortWithError(ctx, c, consts.StatusForbidden, "不能为其他租户创建 API Key")
+				return
+			}
+			req.TenantID = tenant
+		}
+		if req.Name == "" || req.TenantID == "" {
+			AbortWithError(ctx, c, consts.StatusBadRequest, "name 与 tenant_id 不能为空")
+			return
+		}
+		secret, key, err := apikey.Generate(apikey.Key{
+			Name:       req.Name,
+			TenantID:   req.TenantID,
+			MerchantID: req.MerchantID,
+			Scopes:     req.Scopes,
+			ExpiresAt:  req.ExpiresAt,
+		})
+		if err == nil {
+			err = store.Create(ctx, key)
+		}
+		if err != nil {
+			hlog.CtxErrorf(ctx, "创建 API Key 失败: %v", err)
+			AbortWithError(ctx, c, consts.StatusInternalServerError, "创建 API Key 失败")
+			return
+		}
+		hlog.CtxInfof(ctx, "创建 API Key [id: %s, name: %s, tenant: %s]", key.ID, key.Name, key.TenantID)
+		c.JSON(consts.StatusCreated, &createApiKeyResponse{Key: key, Secret: secret})
+	})
+
+	g.DELETE("/:id", func(ctx context.Context, c *app.RequestContext) {
+		id := c.Param("id")
+		tenant, ok := adminTenant(ctx, c)
+		if !ok {
+			return
+		}
+		var err error
+		if tenant != "" {
+			err = ownKey(ctx, store, tenant, id)
+		}
+		if err == nil {
+			err = store.Delete(ctx, id)
+		}
+		switch {
+		case err == nil:
+			hlog.CtxInfof(ctx, "删除 API Key [id: %s]", id)
+			c.Status(consts.StatusNoContent)
+		case errors.Is(err, apikey.ErrKeyNotFound):
+			AbortWithError(ctx, c, consts.StatusNotFound, "API Key 不存在")
+		default:
+			hlog.CtxErrorf(ctx, "删除 API Key 失败: %v", err)
+			AbortWithError(ctx, c, consts.StatusInternalServerError, "删除 API Key 失败")
+		}
+	})
+}
+
+// adminTenant 返回调用方只能管理的租户，平台管理员返回空字符串；
+// 非平台管理员且上下文中没有租户时返回 403
+func adminTenant(ctx context.Context, c *app.RequestContext) (string, bool) {
+	if isAdmin := ctxx.IsAdmin(ctx); isAdmin != nil && *isAdmin {
+		return "", true
+	}
+	tenant := ctxx.GetTenantID(ctx)
+	if tenant == "" {
+		AbortWithError(ctx, c, consts.StatusForbidden, "没有可管理的租户")
+		return "", false
+	}
+	return tenant, true
+}
+
+// ownKey 检查密钥属于租户，其他租户的密钥按不存在处理
+func ownKey(ctx context.Context, store apikey.Store, tenant, id string) error {
+	keys, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if k.ID == id && k.TenantID == tenant {
+			return nil
+		}
+	}
+	return apikey.ErrKeyNotFound
+}
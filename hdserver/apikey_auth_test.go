@@ -0,0 +1,134 @@
+package hdserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/grayscalecloud/hertzcommon/apikey"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+)
+
+func TestApiKeyAuthMiddleware(t *testing.T) {
+	store := apikey.NewMemoryStore()
+	engine := route.NewEngine(config.NewOptions(nil))
+	// 平台管理员
+	RegisterApiKeyAdminRoutes(engine.Group("/admin/apikeys", func(ctx context.Context, c *app.RequestContext) {
+		c.Next(ctxx.WithIsAdmin(ctx, true))
+	}), store)
+	api := engine.Group("/api", NewApiKeyAuthMiddleware(store, nil))
+	api.GET("/me", func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(consts.StatusOK, map[string]interface{}{
+			"tenant":      ctxx.GetTenantID(ctx),
+			"merchant":    ctxx.GetMerchantID(ctx),
+			"user":        ctxx.GetUserID(ctx),
+			"app_type":    ctxx.GetAppType(ctx),
+			"permissions": ctxx.GetPermissions(ctx),
+			"key":         GetApiKey(c).Name,
+		})
+	})
+
+	body := `{"name":"erp","tenant_id":"t1","merchant_id":"m1","scopes":["order:read"]}`
+	w := ut.PerformRequest(engine, consts.MethodPost, "/admin/apikeys", &ut.Body{Body: bytes.NewBufferString(body), Len: len(body)},
+		ut.Header{Key: "Content-Type", Value: "application/json"})
+	if w.Code != consts.StatusCreated {
+		t.Fatalf("创建 status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"`
+		Hash   string `json:"hash"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.Secret == "" || created.Hash != "" {
+		t.Fatalf("创建响应 = %s, err = %v", w.Body.String(), err)
+	}
+
+	w = ut.PerformRequest(engine, consts.MethodGet, "/api/me", nil, ut.Header{Key: "X-Api-Key", Value: created.Secret})
+	if w.Code != consts.StatusOK {
+		t.Fatalf("鉴权 status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var got map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got["tenant"] != "t1" || got["merchant"] != "m1" || got["user"] != "apikey:"+created.ID ||
+		got["app_type"] != "api" || got["key"] != "erp" {
+		t.Errorf("ctxx = %v", got)
+	}
+	if perms, _ := got["permissions"].([]interface{}); len(perms) != 1 || perms[0] != "order:read" {
+		t.Errorf("permissions = %v", got["permissions"])
+	}
+
+	for _, h := range []ut.Header{{Key: "X-Other", Value: "x"}, {Key: "X-Api-Key", Value: "hk_invalid"}} {
+		if w := ut.PerformRequest(engine, consts.MethodGet, "/api/me", nil, h); w.Code != consts.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", h.Key, w.Code)
+		}
+	}
+
+	w = ut.PerformRequest(engine, consts.MethodGet, "/admin/apikeys", nil)
+	if w.Code != consts.StatusOK || !strings.Contains(w.Body.String(), created.ID) {
+		t.Errorf("列表 = %d %s", w.Code, w.Body.String())
+	}
+	if w := ut.PerformRequest(engine, consts.MethodDelete, "/admin/apikeys/"+created.ID, nil); w.Code != consts.StatusNoContent {
+		t.Errorf("删除 status = %d", w.Code)
+	}
+	if w := ut.PerformRequest(engine, consts.MethodGet, "/api/me", nil, ut.Header{Key: "X-Api-Key", Value: created.Secret}); w.Code != consts.StatusUnauthorized {
+		t.Errorf("删除后 status = %d, want 401", w.Code)
+	}
+}
+
+func TestApiKeyAdminTenantScope(t *testing.T) {
+	store := apikey.NewMemoryStore()
+	engine := route.NewEngine(config.NewOptions(nil))
+	// 租户管理员，租户来自登录身份
+	RegisterApiKeyAdminRoutes(engine.Group("/admin/apikeys", func(ctx context.Context, c *app.RequestContext) {
+		c.Next(ctxx.WithTenantID(ctx, string(c.GetHeader("X-Test-Tenant"))))
+	}), store)
+
+	create := func(tenant, body string) (int, string) {
+		w := ut.PerformRequest(engine, consts.MethodPost, "/admin/apikeys", &ut.Body{Body: bytes.NewBufferString(body), Len: len(body)},
+			ut.Header{Key: "Content-Type", Value: "application/json"}, ut.Header{Key: "X-Test-Tenant", Value: tenant})
+		var created struct {
+			ID       string `json:"id"`
+			TenantID string `json:"tenant_id"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &created)
+		if w.Code == consts.StatusCreated && created.TenantID != tenant {
+			t.Errorf("租户 %s 创建的密钥属于 %s", tenant, created.TenantID)
+		}
+		return w.Code, created.ID
+	}
+	code, k1 := create("t1", `{"name":"erp"}`)
+	if code != consts.StatusCreated {
+		t.Fatalf("t1 创建 status = %d", code)
+	}
+	code, k2 := create("t2", `{"name":"pos","tenant_id":"t2"}`)
+	if code != consts.StatusCreated {
+		t.Fatalf("t2 创建 status = %d", code)
+	}
+	if code, _ := create("t1", `{"name":"evil","tenant_id":"t2"}`); code != consts.StatusForbidden {
+		t.Errorf("为其他租户创建 status = %d, want 403", code)
+	}
+	if code, _ := create("", `{"name":"erp","tenant_id":"t1"}`); code != consts.StatusForbidden {
+		t.Errorf("没有租户时创建 status = %d, want 403", code)
+	}
+
+	w := ut.PerformRequest(engine, consts.MethodGet, "/admin/apikeys", nil, ut.Header{Key: "X-Test-Tenant", Value: "t1"})
+	if w.Code != consts.StatusOK || !strings.Contains(w.Body.String(), k1) || strings.Contains(w.Body.String(), k2) {
+		t.Errorf("t1 列表 = %d %s", w.Code, w.Body.String())
+	}
+	if w := ut.PerformRequest(engine, consts.MethodDelete, "/admin/apikeys/"+k2, nil, ut.Header{Key: "X-Test-Tenant", Value: "t1"}); w.Code != consts.StatusNotFound {
+		t.Errorf("删除其他租户的密钥 status = %d, want 404", w.Code)
+	}
+	if keys, _ := store.List(context.Background()); len(keys) != 2 {
+		t.Errorf("密钥数 = %d, want 2", len(keys))
+	}
+	if w := ut.PerformRequest(engine, consts.MethodDelete, "/admin/apikeys/"+k2, nil, ut.Header{Key: "X-Test-Tenant", Value: "t2"}); w.Code != consts.StatusNoContent {
+		t.Errorf("删除本租户的密钥 status = %d", w.Code)
+	}
+}
@@ -0,0 +1,190 @@
+package hdserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// 请求来源
+const (
+	SourceGateway = "gateway"
+	SourcePublic  = "public"
+
+	requestSourceKey = "hertzcommon.request_source"
+)
+
+var defaultPublicKeys = []string{ctxx.RequestKey}
+
+// headerRule 一个可提取的键
+type headerRule struct {
+	key    string
+	header string
+}
+
+// NewContextExtractMiddleware 创建从请求头提取租户/商户/用户/请求 ID/应用类型写入 ctxx 的中间件，
+// 通过 ctxx.RegisterKey 注册且配置了请求头的自定义字段同样会被提取（需在创建中间件前注册）。
+// 请求来源按连接对端地址与 mTLS 身份判断，来源不被允许设置的键会被忽略。
+// 开启 baggage 互通时中间件持有自己的桥接并挂到请求 ctx 上，处理请求时通过 ctxx 设置的映射键同步写入 baggage
+func NewContextExtractMiddleware(cfg *hdmodel.ContextExtract) (app.HandlerFunc, error) {
+	if cfg == nil {
+		cfg = &hdmodel.ContextExtract{}
+	}
+	headers := make(map[string]string)
+	var defaultGatewayKeys []string
+	for _, m := range ctxx.HeaderKeys() {
+		headers[m.Key] = m.Header
+		defaultGatewayKeys = append(defaultGatewayKeys, m.Key)
+	}
+	for k, h := range cfg.Headers {
+		if _, ok := headers[k]; !ok {
+			return nil, fmt.Errorf("不支持提取的 ctxx 键: %s", k)
+		}
+		headers[k] = h
+	}
+
+	gatewayKeys, publicKeys := cfg.GatewayKeys, cfg.PublicKeys
+	if gatewayKeys == nil {
+		gatewayKeys = defaultGatewayKeys
+	}
+	if publicKeys == nil {
+		publicKeys = defaultPublicKeys
+	}
+	gatewayRules, err := buildHeaderRules(headers, gatewayKeys)
+	if err != nil {
+		return nil, err
+	}
+	publicRules, err := buildHeaderRules(headers, publicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedNets := make([]*net.IPNet, 0, len(cfg.TrustedCIDRs))
+	for _, cidr := range cfg.TrustedCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("解析可信网段失败 [%s]: %w", cidr, err)
+		}
+		trustedNets = append(trustedNets, ipNet)
+	}
+	trustedPeers := make(map[string]struct{}, len(cfg.TrustedPeers))
+	for _, p := range cfg.TrustedPeers {
+		trustedPeers[p] = struct{}{}
+	}
+
+	gatewayAllowed, publicAllowed := keySet(gatewayRules), keySet(publicRules)
+	var bridge *ctxx.BaggageBridge
+	if cfg.Baggage.Enable {
+		bridge = ctxx.NewBaggageBridge(&ctxx.BaggageBridgeOptions{Mapping: cfg.Baggage.Mapping, Allow: cfg.Baggage.Allow})
+	}
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		source, rules, allowed := SourcePublic, publicRules, publicAllowed
+		if trustedSource(ctx, c, trustedNets, trustedPeers) {
+			source, rules, allowed = SourceGateway, gatewayRules, gatewayAllowed
+		}
+		// 先取出入站 baggage，请求头写入 ctxx 时会同步覆盖 baggage
+		var inbound baggage.Baggage
+		if bridge != nil {
+			inbound = inboundBaggage(ctx, c)
+			ctx = ctxx.WithBaggageBridge(ctx, bridge)
+		}
+		for _, r := range rules {
+			if v := string(c.Request.Header.Peek(r.header)); v != "" {
+				ctx = ctxx.SetInbound(ctx, r.key, v)
+			}
+		}
+		if bridge != nil {
+			ctx = bridge.Extract(ctx, inbound, func(key string) bool {
+				_, ok := allowed[key]
+				return ok
+			})
+		}
+		c.Set(requestSourceKey, source)
+		c.Next(ctx)
+	}, nil
+}
+
+// GetRequestSource 获取请求来源：gateway 或 public
+func GetRequestSource(c *app.RequestContext) string {
+	return c.GetString(requestSourceKey)
+}
+
+func buildHeaderRules(headers map[string]string, keys []string) ([]headerRule, error) {
+	rules := make([]headerRule, 0, len(keys))
+	for _, k := range keys {
+		h, ok := headers[k]
+		if !ok {
+			return nil, fmt.Errorf("不支持提取的 ctxx 键: %s", k)
+		}
+		rules = append(rules, headerRule{key: k, header: h})
+	}
+	return rules, nil
+}
+
+func keySet(rules []headerRule) map[string]struct{} {
+	set := make(map[string]struct{}, len(rules))
+	for _, r := range rules {
+		set[r.key] = struct{}{}
+	}
+	return set
+}
+
+// inboundBaggage 读取入站 baggage：启用 OTel 时已由链路中间件解析到 ctx，否则从 baggage 请求头解析
+func inboundBaggage(ctx context.Context, c *app.RequestContext) baggage.Baggage {
+	if bag := baggage.FromContext(ctx); bag.Len() > 0 {
+		return bag
+	}
+	header := string(c.Request.Header.Peek("baggage"))
+	if header == "" {
+		return baggage.Baggage{}
+	}
+	bag, err := baggage.Parse(header)
+	if err != nil {
+		hlog.CtxDebugf(ctx, "解析 baggage 请求头失败: %v", err)
+	}
+	return bag
+}
+
+// trustedSource 判断请求是否来自可信网关，mTLS 身份必须来自已校验的证书链
+func trustedSource(ctx context.Context, c *app.RequestContext, nets []*net.IPNet, peers map[string]struct{}) bool {
+	if len(peers) > 0 {
+		if peer := ctxx.GetPeerIdentity(ctx); peer != nil && peer.Verified {
+			if _, ok := peers[peer.CommonName]; ok {
+				return true
+			}
+			for _, u := range peer.URIs {
+				if _, ok := peers[u]; ok {
+					return true
+				}
+			}
+		}
+	}
+	if len(nets) == 0 {
+		return false
+	}
+	remote := c.RemoteAddr()
+	if remote == nil {
+		return false
+	}
+	addr, ok := remote.(*net.TCPAddr)
+	if !ok {
+		host, _, err := net.SplitHostPort(remote.String())
+		if err != nil {
+			return false
+		}
+		addr = &net.TCPAddr{IP: net.ParseIP(host)}
+	}
+	for _, n := range nets {
+		if n.Contains(addr.IP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package hdserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestContextExtractMiddleware(t *testing.T) {
+	headers := []ut.Header{
+		{Key: "X-Org-Id", Value: "t1"},
+		{Key: ctxx.HeaderMerchantID, Value: "m1"},
+		{Key: ctxx.HeaderUserID, Value: "u1"},
+		{Key: ctxx.HeaderAppType, Value: "pos"},
+		{Key: ctxx.HeaderRequestID, Value: "req-1"},
+	}
+	// ut 请求的对端地址为 0.0.0.0
+	tests := []struct {
+		name   string
+		cfg    hdmodel.ContextExtract
+		peer   *ctxx.PeerIdentity
+		source string
+		want   string
+	}{
+		{name: "公网请求只接受请求 ID", cfg: hdmodel.ContextExtract{TrustedCIDRs: []string{"10.0.0.0/8"}}, source: SourcePublic, want: "////req-1"},
+		{name: "可信网段", cfg: hdmodel.ContextExtract{TrustedCIDRs: []string{"0.0.0.0/32"}}, source: SourceGateway, want: "t1/m1/u1/pos/req-1"},
+		{name: "可信 mTLS 身份", cfg: hdmodel.ContextExtract{TrustedPeers: []string{"spiffe://cluster.local/sa/gateway"}},
+			peer: &ctxx.PeerIdentity{CommonName: "gw", URIs: []string{"spiffe://cluster.local/sa/gateway"}, Verified: true}, source: SourceGateway, want: "t1/m1/u1/pos/req-1"},
+		{name: "非可信 mTLS 身份", cfg: hdmodel.ContextExtract{TrustedPeers: []string{"gateway"}},
+			peer: &ctxx.PeerIdentity{CommonName: "order-svc", Verified: true}, source: SourcePublic, want: "////req-1"},
+		{name: "未校验的 mTLS 身份", cfg: hdmodel.ContextExtract{TrustedPeers: []string{"gateway"}},
+			peer: &ctxx.PeerIdentity{CommonName: "gateway"}, source: SourcePublic, want: "////req-1"},
+		{name: "自定义可设置的键", cfg: hdmodel.ContextExtract{PublicKeys: []string{ctxx.AppTypeKey}}, source: SourcePublic, want: "///pos/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Headers = map[string]string{ctxx.TenantKey: "X-Org-Id"}
+			mw, err := NewContextExtractMiddleware(&tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			engine := route.NewEngine(config.NewOptions(nil))
+			engine.Use(func(ctx context.Context, c *app.RequestContext) {
+				if tt.peer != nil {
+					ctx = ctxx.WithPeerIdentity(ctx, tt.peer)
+				}
+				c.Next(ctx)
+			}, mw)
+			engine.GET("/ctx", func(ctx context.Context, c *app.RequestContext) {
+				c.Header("X-Source", GetRequestSource(c))
+				c.String(consts.StatusOK, ctxx.GetTenantID(ctx)+"/"+ctxx.GetMerchantID(ctx)+"/"+ctxx.GetUserID(ctx)+"/"+
+					ctxx.GetAppType(ctx)+"/"+ctxx.GetRequestID(ctx))
+			})
+
+			w := ut.PerformRequest(engine, consts.MethodGet, "/ctx", nil, headers...)
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("ctxx = %q, want %q", got, tt.want)
+			}
+			if got := w.Header().Get("X-Source"); got != tt.source {
+				t.Errorf("source = %q, want %q", got, tt.source)
+			}
+		})
+	}
+
+	for _, cfg := range []hdmodel.ContextExtract{
+		{TrustedCIDRs: []string{"10.0.0.0"}},
+		{Headers: map[string]string{"unknown": "X-Unknown"}},
+		{GatewayKeys: []string{"unknown"}},
+	} {
+		if _, err := NewContextExtractMiddleware(&cfg); err == nil {
+			t.Errorf("配置 %+v 应返回错误", cfg)
+		}
+	}
+}
+
+func TestContextExtractBaggage(t *testing.T) {
+	mapping := map[string]string{ctxx.TenantKey: "tenant.id", ctxx.UserKey: "user.id", ctxx.RequestKey: "request.id"}
+
+	tests := []struct {
+		name    string
+		cidrs   []string
+		headers []ut.Header
+		want    string
+	}{
+		{name: "公网请求不接受 baggage 身份", cidrs: []string{"10.0.0.0/8"},
+			headers: []ut.Header{{Key: "baggage", Value: "tenant.id=t1,request.id=r1"}}, want: "//r1"},
+		{name: "可信网关", cidrs: []string{"0.0.0.0/32"},
+			headers: []ut.Header{{Key: "baggage", Value: "tenant.id=t1,user.id=u1"}}, want: "t1/u1/"},
+		{name: "请求头优先", cidrs: []string{"0.0.0.0/32"},
+			headers: []ut.Header{{Key: "baggage", Value: "tenant.id=t1"}, {Key: ctxx.HeaderTenantID, Value: "t9"}}, want: "t9//"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw, err := NewContextExtractMiddleware(&hdmodel.ContextExtract{
+				TrustedCIDRs: tt.cidrs,
+				Baggage:      hdmodel.BaggageBridge{Enable: true, Mapping: mapping},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			engine := route.NewEngine(config.NewOptions(nil))
+			engine.Use(mw)
+			engine.GET("/ctx", func(ctx context.Context, c *app.RequestContext) {
+				c.String(consts.StatusOK, ctxx.GetTenantID(ctx)+"/"+ctxx.GetUserID(ctx)+"/"+ctxx.GetRequestID(ctx))
+			})
+			w := ut.PerformRequest(engine, consts.MethodGet, "/ctx", nil, tt.headers...)
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("ctxx = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextExtractBaggagePerServer(t *testing.T) {
+	// 同一进程中的两个服务各自决定是否写入 baggage
+	newEngine := func(enable bool) *route.Engine {
+		mw, err := NewContextExtractMiddleware(&hdmodel.ContextExtract{
+			TrustedCIDRs: []string{"0.0.0.0/32"},
+			Baggage:      hdmodel.BaggageBridge{Enable: enable, Mapping: map[string]string{ctxx.TenantKey: "tenant.id"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine := route.NewEngine(config.NewOptions(nil))
+		engine.Use(mw)
+		engine.GET("/ctx", func(ctx context.Context, c *app.RequestContext) {
+			c.String(consts.StatusOK, baggage.FromContext(ctx).Member("tenant.id").Value())
+		})
+		return engine
+	}
+	bridged, plain := newEngine(true), newEngine(false)
+	header := ut.Header{Key: ctxx.HeaderTenantID, Value: "t1"}
+	if got := ut.PerformRequest(bridged, consts.MethodGet, "/ctx", nil, header).Body.String(); got != "t1" {
+		t.Errorf("开启桥接的服务 baggage = %q, want t1", got)
+	}
+	if got := ut.PerformRequest(plain, consts.MethodGet, "/ctx", nil, header).Body.String(); got != "" {
+		t.Errorf("未开启桥接的服务 baggage = %q, want 空", got)
+	}
+}
@@ -10,8 +10,10 @@ import (
 	"github.com/cloudwego/hertz/pkg/app/server"
 	"github.com/cloudwego/hertz/pkg/common/config"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/network/standard"
 	"github.com/grayscalecloud/hertzcommon/hdmodel"
 	"github.com/grayscalecloud/hertzcommon/monitor"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
 	hertzlogrus "github.com/hertz-contrib/obs-opentelemetry/logging/logrus"
 	hertzotelprovider "github.com/hertz-contrib/obs-opentelemetry/provider"
 	hertzoteltracing "github.com/hertz-contrib/obs-opentelemetry/tracing"
@@ -39,7 +41,26 @@ func NewHdServer(hzCfg *hdmodel.Hertz, monitorCfg *hdmodel.Monitor) *server.Hert
 		opts = append(opts, server.WithHandleMethodNotAllowed(true))
 	}
 
+	if err := validateTrustedPeers(hzCfg); err != nil {
+		panic(err)
+	}
+	var reloader *CertReloader
+	if hzCfg.TLS.Enable {
+		var err error
+		reloader, err = NewCertReloader(&hzCfg.TLS)
+		if err != nil {
+			panic(err)
+		}
+		// netpoll 不支持 TLS，启用 TLS 时使用标准库传输层
+		opts = append(opts, server.WithTLS(reloader.TLSConfig()), server.WithTransport(standard.NewTransporter))
+	}
+
 	h := server.New(opts...)
+	if reloader != nil {
+		h.OnShutdown = append(h.OnShutdown, func(ctx context.Context) {
+			reloader.Close()
+		})
+	}
 
 	registerMiddleware(h, cfg, hzCfg)
 
@@ -73,7 +94,7 @@ func registerMiddleware(h *server.Hertz, cfg *hertzoteltracing.Config, hzCfg *hd
 	// log
 	if cfg != nil {
 		h.Use(hertzoteltracing.ServerMiddleware(cfg))
-		logger := hertzlogrus.NewLogger()
+		logger := hertzlogrus.NewLogger(hertzlogrus.WithHook(RequestIDLogHook{}))
 		hlog.SetLogger(logger)
 		hlog.SetLevel(logLevel(hzCfg.LogLevel))
 
@@ -101,8 +122,29 @@ func registerMiddleware(h *server.Hertz, cfg *hertzoteltracing.Config, hzCfg *hd
 
 		// 设置多写入器
 		hlog.SetOutput(io.MultiWriter(writers...))
+	} else if !hzCfg.DisableRequestIDLog {
+		// 未启用 OTel 时同样在带 Context 的日志中输出 request_id：包装调用方设置的日志器而不是替换，已经包装过的不重复包装
+		if _, ok := hlog.DefaultLogger().(*contextLogger); !ok {
+			hlog.SetLogger(NewContextLogger(hlog.DefaultLogger()))
+		}
 	}
 
+	// mTLS 客户端证书身份
+	if hzCfg.TLS.Enable {
+		h.Use(ClientCertMiddleware())
+	}
+
+	// 从请求头提取租户/商户/用户/请求 ID/应用类型，需在 mTLS 身份之后；
+	// 开启 baggage 互通时 ctxx 设置的身份同步写入 baggage
+	extract, err := NewContextExtractMiddleware(&hzCfg.Context)
+	if err != nil {
+		panic(err)
+	}
+	h.Use(extract)
+
+	// 请求 ID：沿用合法的入站 ID 或生成新 ID，回写响应头
+	h.Use(NewRequestIDMiddleware(&RequestIDOptions{HeaderName: hzCfg.Context.Headers[ctxx.RequestKey]}))
+
 	// 将租户/商户/用户信息附加到当前请求 Span，放在最后，确保其他中间件已执行
 	h.Use(monitor.AttachTenantAttributes())
 }
@@ -0,0 +1,85 @@
+package hdserver
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+)
+
+// contextLogger 包装已有的 hlog 日志器，带 Context 的日志加上 request_id 前缀，其他方法直接交给被包装的日志器；
+// 多了一层调用，使用 hlog 默认日志器时带 Context 的日志输出的文件行号指向本文件
+type contextLogger struct {
+	hlog.FullLogger
+	level atomic.Int32
+}
+
+// NewContextLogger 包装 base，base 为空时包装当前的 hlog 日志器
+func NewContextLogger(base hlog.FullLogger) hlog.FullLogger {
+	if base == nil {
+		base = hlog.DefaultLogger()
+	}
+	l := &contextLogger{FullLogger: base}
+	l.level.Store(int32(hlog.LevelTrace))
+	return l
+}
+
+// SetLevel 设置日志级别并同步给被包装的日志器
+func (l *contextLogger) SetLevel(lv hlog.Level) {
+	l.level.Store(int32(lv))
+	l.FullLogger.SetLevel(lv)
+}
+
+func (l *contextLogger) enabled(lv hlog.Level) bool {
+	return hlog.Level(l.level.Load()) <= lv
+}
+
+// prefix 在格式串前加上 request_id，ID 中的 % 需要转义
+func prefix(ctx context.Context, format string) string {
+	if id := ctxx.GetRequestID(ctx); id != "" {
+		return ctxx.RequestKey + "=" + strings.ReplaceAll(id, "%", "%%") + " " + format
+	}
+	return format
+}
+
+func (l *contextLogger) CtxFatalf(ctx context.Context, format string, v ...interface{}) {
+	l.FullLogger.CtxFatalf(ctx, prefix(ctx, format), v...)
+}
+
+func (l *contextLogger) CtxErrorf(ctx context.Context, format string, v ...interface{}) {
+	if l.enabled(hlog.LevelError) {
+		l.FullLogger.CtxErrorf(ctx, prefix(ctx, format), v...)
+	}
+}
+
+func (l *contextLogger) CtxWarnf(ctx context.Context, format string, v ...interface{}) {
+	if l.enabled(hlog.LevelWarn) {
+		l.FullLogger.CtxWarnf(ctx, prefix(ctx, format), v...)
+	}
+}
+
+func (l *contextLogger) CtxNoticef(ctx context.Context, format string, v ...interface{}) {
+	if l.enabled(hlog.LevelNotice) {
+		l.FullLogger.CtxNoticef(ctx, prefix(ctx, format), v...)
+	}
+}
+
+func (l *contextLogger) CtxInfof(ctx context.Context, format string, v ...interface{}) {
+	if l.enabled(hlog.LevelInfo) {
+		l.FullLogger.CtxInfof(ctx, prefix(ctx, format), v...)
+	}
+}
+
+func (l *contextLogger) CtxDebugf(ctx context.Context, format string, v ...interface{}) {
+	if l.enabled(hlog.LevelDebug) {
+		l.FullLogger.CtxDebugf(ctx, prefix(ctx, format), v...)
+	}
+}
+
+func (l *contextLogger) CtxTracef(ctx context.Context, format string, v ...interface{}) {
+	if l.enabled(hlog.LevelTrace) {
+		l.FullLogger.CtxTracef(ctx, prefix(ctx, format), v...)
+	}
+}
@@ -0,0 +1,159 @@
+package hdserver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/grayscalecloud/hertzcommon/token"
+)
+
+const (
+	defaultAuthHeader = "Authorization"
+	defaultAuthCookie = "access_token"
+	bearerPrefix      = "Bearer "
+
+	// claimsContextKey 校验通过的令牌 Claims 在 RequestContext 中的键名
+	claimsContextKey = "hertzcommon.token_claims"
+)
+
+// PasetoAuthOptions PASETO 鉴权中间件配置
+type PasetoAuthOptions struct {
+	// HeaderName 读取令牌的请求头，默认 Authorization（支持 Bearer 前缀）
+	HeaderName string
+	// CookieName 请求头中没有令牌时读取的 Cookie，默认 access_token
+	CookieName string
+	// Audience 期望的 aud，为空时不校验
+	Audience string
+	// Issuer 期望的 iss，为空时不校验
+	Issuer string
+	// ClockLeeway 校验 exp/nbf 时允许的时钟偏差，默认 30 秒
+	ClockLeeway time.Duration
+	// SkipPaths 跳过鉴权的路径（精确匹配）
+	SkipPaths []string
+	// Revocation 令牌吊销检查，为空时不检查
+	Revocation token.RevocationChecker
+	// RevocationFailOpen 吊销检查出错时放行，默认拒绝并返回 503
+	RevocationFailOpen bool
+}
+
+// NewPasetoAuthMiddleware 创建 PASETO v4.public 鉴权中间件
+// 校验通过后把租户/商户/用户/应用类型/角色/管理员标记写入 ctxx，失败返回 401
+func NewPasetoAuthMiddleware(cfg *hdmodel.PasetoConfig, opts *PasetoAuthOptions) (app.HandlerFunc, error) {
+	if opts == nil {
+		opts = &PasetoAuthOptions{}
+	}
+	verifier, err := token.NewVerifier(cfg, &token.VerifierOptions{
+		Audience:    opts.Audience,
+		Issuer:      opts.Issuer,
+		ClockLeeway: opts.ClockLeeway,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewPasetoAuthMiddlewareWithVerifier(verifier, opts), nil
+}
+
+// NewPasetoAuthMiddlewareWithVerifier 使用已有的校验器创建鉴权中间件，
+// 配合 token.NewWatchedVerifier 可以在不重启的情况下轮换公钥；opts 中的 Audience/Issuer/ClockLeeway 由校验器决定
+func NewPasetoAuthMiddlewareWithVerifier(verifier *token.Verifier, opts *PasetoAuthOptions) app.HandlerFunc {
+	if opts == nil {
+		opts = &PasetoAuthOptions{}
+	}
+	headerName := opts.HeaderName
+	if headerName == "" {
+		headerName = defaultAuthHeader
+	}
+	cookieName := opts.CookieName
+	if cookieName == "" {
+		cookieName = defaultAuthCookie
+	}
+	skipPaths := make(map[string]struct{}, len(opts.SkipPaths))
+	for _, p := range opts.SkipPaths {
+		skipPaths[p] = struct{}{}
+	}
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		if _, ok := skipPaths[string(c.Path())]; ok {
+			c.Next(ctx)
+			return
+		}
+
+		raw := extractToken(c, headerName, cookieName)
+		if raw == "" {
+			AbortWithError(ctx, c, consts.StatusUnauthorized, "缺少访问令牌")
+			return
+		}
+
+		claims, err := verifier.Verify(raw)
+		if err != nil {
+			hlog.CtxWarnf(ctx, "PASETO 令牌校验失败: %v", err)
+			AbortWithError(ctx, c, consts.StatusUnauthorized, "访问令牌无效或已过期")
+			return
+		}
+
+		if opts.Revocation != nil {
+			revoked, err := opts.Revocation.IsRevoked(ctx, claims)
+			if err != nil {
+				hlog.CtxErrorf(ctx, "检查令牌吊销状态失败: %v", err)
+				if !opts.RevocationFailOpen {
+					AbortWithError(ctx, c, consts.StatusServiceUnavailable, "鉴权服务暂不可用")
+					return
+				}
+			}
+			if revoked {
+				AbortWithError(ctx, c, consts.StatusUnauthorized, "访问令牌已吊销")
+				return
+			}
+		}
+
+		c.Set(claimsContextKey, claims)
+		c.Next(withClaims(ctx, claims))
+	}
+}
+
+// GetTokenClaims 获取鉴权中间件校验通过的令牌 Claims
+func GetTokenClaims(c *app.RequestContext) *token.Claims {
+	if v, ok := c.Get(claimsContextKey); ok {
+		if claims, ok := v.(*token.Claims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// extractToken 依次从请求头和 Cookie 中读取令牌
+func extractToken(c *app.RequestContext, headerName, cookieName string) string {
+	if v := strings.TrimSpace(string(c.GetHeader(headerName))); v != "" {
+		if len(v) > len(bearerPrefix) && strings.EqualFold(v[:len(bearerPrefix)], bearerPrefix) {
+			return strings.TrimSpace(v[len(bearerPrefix):])
+		}
+		return v
+	}
+	return string(c.Cookie(cookieName))
+}
+
+// withClaims 把令牌中的身份信息写入 ctxx
+func withClaims(ctx context.Context, claims *token.Claims) context.Context {
+	if claims.TenantID != "" {
+		ctx = ctxx.WithTenantID(ctx, claims.TenantID)
+	}
+	if claims.MerchantID != "" {
+		ctx = ctxx.WithMerchantID(ctx, claims.MerchantID)
+	}
+	if claims.UserID != "" {
+		ctx = ctxx.WithUserID(ctx, claims.UserID)
+	}
+	if claims.AppType != "" {
+		ctx = ctxx.WithAppType(ctx, claims.AppType)
+	}
+	if len(claims.Roles) > 0 {
+		ctx = ctxx.WithRoles(ctx, claims.Roles)
+	}
+	return ctxx.WithIsAdmin(ctx, claims.IsAdmin)
+}
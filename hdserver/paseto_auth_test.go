@@ -0,0 +1,127 @@
+package hdserver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/grayscalecloud/hertzcommon/token"
+)
+
+func newAuthEngine(t *testing.T, cfg *hdmodel.PasetoConfig, opts *PasetoAuthOptions) *route.Engine {
+	mw, err := NewPasetoAuthMiddleware(cfg, opts)
+	if err != nil {
+		t.Fatalf("创建中间件失败: %v", err)
+	}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(mw)
+	engine.GET("/me", func(ctx context.Context, c *app.RequestContext) {
+		admin := ctxx.IsAdmin(ctx)
+		c.JSON(consts.StatusOK, map[string]interface{}{
+			"tenant":   ctxx.GetTenantID(ctx),
+			"merchant": ctxx.GetMerchantID(ctx),
+			"user":     ctxx.GetUserID(ctx),
+			"app_type": ctxx.GetAppType(ctx),
+			"admin":    admin != nil && *admin,
+		})
+	})
+	return engine
+}
+
+func signToken(sk paseto.V4AsymmetricSecretKey, implicit string, mutate func(tk *paseto.Token)) string {
+	tk := paseto.NewToken()
+	now := time.Now()
+	tk.SetIssuedAt(now)
+	tk.SetNotBefore(now)
+	tk.SetExpiration(now.Add(time.Hour))
+	tk.SetAudience("order-service")
+	tk.SetString(token.ClaimTenantID, "t1")
+	tk.SetString(token.ClaimMerchantID, "m1")
+	tk.SetString(token.ClaimUserID, "u1")
+	tk.SetString(token.ClaimAppType, "admin_web")
+	_ = tk.Set(token.ClaimIsAdmin, true)
+	if mutate != nil {
+		mutate(&tk)
+	}
+	return tk.V4Sign(sk, []byte(implicit))
+}
+
+func TestPasetoAuthMiddleware(t *testing.T) {
+	sk := paseto.NewV4AsymmetricSecretKey()
+	cfg := &hdmodel.PasetoConfig{PubKey: sk.Public().ExportHex(), Implicit: "hertzcommon"}
+	engine := newAuthEngine(t, cfg, &PasetoAuthOptions{Audience: "order-service"})
+
+	valid := signToken(sk, cfg.Implicit, nil)
+
+	tests := []struct {
+		name    string
+		headers []ut.Header
+		want    int
+	}{
+		{name: "bearer header", headers: []ut.Header{{Key: "Authorization", Value: "Bearer " + valid}}, want: consts.StatusOK},
+		{name: "cookie", headers: []ut.Header{{Key: "Cookie", Value: "access_token=" + valid}}, want: consts.StatusOK},
+		{name: "missing", want: consts.StatusUnauthorized},
+		{name: "expired", headers: []ut.Header{{Key: "Authorization", Value: "Bearer " + signToken(sk, cfg.Implicit, func(tk *paseto.Token) {
+			tk.SetExpiration(time.Now().Add(-time.Hour))
+		})}}, want: consts.StatusUnauthorized},
+		{name: "not yet valid", headers: []ut.Header{{Key: "Authorization", Value: "Bearer " + signToken(sk, cfg.Implicit, func(tk *paseto.Token) {
+			tk.SetNotBefore(time.Now().Add(time.Hour))
+		})}}, want: consts.StatusUnauthorized},
+		{name: "wrong audience", headers: []ut.Header{{Key: "Authorization", Value: "Bearer " + signToken(sk, cfg.Implicit, func(tk *paseto.Token) {
+			tk.SetAudience("other-service")
+		})}}, want: consts.StatusUnauthorized},
+		{name: "wrong implicit", headers: []ut.Header{{Key: "Authorization", Value: "Bearer " + signToken(sk, "other", nil)}}, want: consts.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ut.PerformRequest(engine, consts.MethodGet, "/me", nil, tt.headers...)
+			if got := w.Code; got != tt.want {
+				t.Fatalf("状态码 = %d, want %d, body = %s", got, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	w := ut.PerformRequest(engine, consts.MethodGet, "/me", nil, ut.Header{Key: "Authorization", Value: "Bearer " + valid})
+	want := `{"admin":true,"app_type":"admin_web","merchant":"m1","tenant":"t1","user":"u1"}`
+	if got := w.Body.String(); got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+type revokeAll struct{ err error }
+
+func (r revokeAll) IsRevoked(context.Context, *token.Claims) (bool, error) {
+	return r.err == nil, r.err
+}
+
+func TestPasetoAuthMiddlewareRevocation(t *testing.T) {
+	sk := paseto.NewV4AsymmetricSecretKey()
+	cfg := &hdmodel.PasetoConfig{PubKey: sk.Public().ExportHex(), Implicit: "hertzcommon"}
+	header := ut.Header{Key: "Authorization", Value: "Bearer " + signToken(sk, cfg.Implicit, nil)}
+
+	tests := []struct {
+		name string
+		opts *PasetoAuthOptions
+		want int
+	}{
+		{name: "revoked", opts: &PasetoAuthOptions{Revocation: revokeAll{}}, want: consts.StatusUnauthorized},
+		{name: "store down", opts: &PasetoAuthOptions{Revocation: revokeAll{err: context.DeadlineExceeded}}, want: consts.StatusServiceUnavailable},
+		{name: "store down fail open", opts: &PasetoAuthOptions{Revocation: revokeAll{err: context.DeadlineExceeded}, RevocationFailOpen: true}, want: consts.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ut.PerformRequest(newAuthEngine(t, cfg, tt.opts), consts.MethodGet, "/me", nil, header)
+			if w.Code != tt.want {
+				t.Errorf("状态码 = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
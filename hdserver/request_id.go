@@ -0,0 +1,106 @@
+package hdserver
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/google/uuid"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultRequestIDMaxLength = 64
+
+// RequestIDOptions 请求 ID 中间件配置
+type RequestIDOptions struct {
+	// HeaderName 读取与回写请求 ID 的请求头，默认 X-Request-Id
+	HeaderName string
+	// MaxLength 接受的入站请求 ID 最大长度，默认 64
+	MaxLength int
+	// Generator 生成请求 ID，默认 UUIDv7（按时间有序）
+	Generator func() string
+}
+
+// NewRequestIDMiddleware 创建请求 ID 中间件
+// 优先使用 ctxx 中已有的或请求头中的请求 ID，长度或字符不合法时重新生成；
+// 结果写入 ctxx 并回写到响应头，同时记录到当前 Span
+func NewRequestIDMiddleware(opts *RequestIDOptions) app.HandlerFunc {
+	if opts == nil {
+		opts = &RequestIDOptions{}
+	}
+	headerName := opts.HeaderName
+	if headerName == "" {
+		headerName = ctxx.HeaderRequestID
+	}
+	maxLength := opts.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultRequestIDMaxLength
+	}
+	generate := opts.Generator
+	if generate == nil {
+		generate = newRequestID
+	}
+
+	return func(ctx context.Context, c *app.RequestContext) {
+		id := ctxx.GetRequestID(ctx)
+		if id == "" {
+			id = string(c.Request.Header.Peek(headerName))
+		}
+		if !validRequestID(id, maxLength) {
+			if id != "" {
+				hlog.CtxDebugf(ctx, "入站请求 ID 不合法，重新生成 [length: %d]", len(id))
+			}
+			id = generate()
+		}
+
+		ctx = ctxx.WithRequestID(ctx, id)
+		c.Response.Header.Set(headerName, id)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+		c.Next(ctx)
+	}
+}
+
+// newRequestID 生成 UUIDv7，失败时退回随机 UUID
+func newRequestID() string {
+	if id, err := uuid.NewV7(); err == nil {
+		return id.String()
+	}
+	return uuid.NewString()
+}
+
+// validRequestID 只接受字母、数字与 -_.: 组成的请求 ID，避免日志注入
+func validRequestID(id string, maxLength int) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-', ch == '_', ch == '.', ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDLogHook 为带 Context 的 logrus 日志添加 request_id 字段，配合 hlog.CtxXxx 使用
+type RequestIDLogHook struct{}
+
+func (RequestIDLogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (RequestIDLogHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if id := ctxx.GetRequestID(entry.Context); id != "" {
+		entry.Data[ctxx.RequestKey] = id
+	}
+	return nil
+}
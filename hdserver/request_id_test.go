@@ -0,0 +1,86 @@
+package hdserver
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/google/uuid"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	hertzlogrus "github.com/hertz-contrib/obs-opentelemetry/logging/logrus"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(NewRequestIDMiddleware(nil))
+	engine.GET("/id", func(ctx context.Context, c *app.RequestContext) {
+		c.String(consts.StatusOK, ctxx.GetRequestID(ctx))
+	})
+
+	tests := []struct {
+		name    string
+		inbound string
+		keep    bool
+	}{
+		{name: "生成", inbound: ""},
+		{name: "沿用合法 ID", inbound: "gw-01HZX.abc:1", keep: true},
+		{name: "非法字符", inbound: "abc\" injected=1"},
+		{name: "超长", inbound: strings.Repeat("a", 65)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ut.PerformRequest(engine, consts.MethodGet, "/id", nil, ut.Header{Key: ctxx.HeaderRequestID, Value: tt.inbound})
+			id := w.Body.String()
+			if echoed := w.Header().Get(ctxx.HeaderRequestID); echoed != id {
+				t.Errorf("响应头 = %q, ctxx = %q", echoed, id)
+			}
+			if tt.keep {
+				if id != tt.inbound {
+					t.Errorf("request_id = %q, want %q", id, tt.inbound)
+				}
+				return
+			}
+			if u, err := uuid.Parse(id); err != nil || u.Version() != 7 {
+				t.Errorf("request_id = %q 不是 UUIDv7", id)
+			}
+		})
+	}
+}
+
+func TestRequestIDLogging(t *testing.T) {
+	ctx := ctxx.WithRequestID(context.Background(), "req-1")
+
+	var buf bytes.Buffer
+	base := logrus.New()
+	base.SetOutput(&buf)
+	base.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	l := NewContextLogger(hertzlogrus.NewLogger(hertzlogrus.WithLogger(base)))
+	l.SetLevel(hlog.LevelInfo)
+	l.CtxInfof(ctx, "处理订单 %s", "o1")
+	l.CtxInfof(ctxx.WithRequestID(ctx, "100%"), "比例 %d%%", 5)
+	l.CtxDebugf(ctx, "低于日志级别")
+	l.Infof("无上下文")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `msg="request_id=req-1 处理订单 o1"`) ||
+		!strings.Contains(lines[1], `msg="request_id=100% 比例 5%"`) || strings.Contains(lines[2], "request_id=") {
+		t.Errorf("日志输出 = %q", buf.String())
+	}
+
+	buf.Reset()
+	lr := logrus.New()
+	lr.SetOutput(&buf)
+	lr.SetFormatter(&logrus.JSONFormatter{})
+	lr.AddHook(RequestIDLogHook{})
+	lr.WithContext(ctx).Info("处理订单")
+	if !strings.Contains(buf.String(), `"request_id":"req-1"`) {
+		t.Errorf("logrus 输出 = %q", buf.String())
+	}
+}
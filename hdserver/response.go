@@ -0,0 +1,24 @@
+package hdserver
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+)
+
+// ErrorResponse 统一的错误响应体
+type ErrorResponse struct {
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// AbortWithError 终止请求并返回统一的错误响应体，供业务与其他中间件复用
+func AbortWithError(ctx context.Context, c *app.RequestContext, status int, message string) {
+	c.AbortWithStatusJSON(status, &ErrorResponse{
+		Code:      status,
+		Message:   message,
+		RequestID: ctxx.GetRequestID(ctx),
+	})
+}
@@ -0,0 +1,83 @@
+package hdserver
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/grayscalecloud/hertzcommon/signature"
+)
+
+func TestSignatureAuthMiddleware(t *testing.T) {
+	secrets := signature.StaticSecrets{"gateway": {"s1"}}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(NewSignatureAuthMiddleware(signature.NewVerifier(secrets, &signature.VerifierOptions{Audiences: []string{"example.com"}}), nil))
+	engine.POST("/orders", func(ctx context.Context, c *app.RequestContext) {
+		c.String(consts.StatusOK, GetSignatureCaller(c)+"/"+ctxx.GetTenantID(ctx))
+	})
+
+	body := `{"sku":"A1"}`
+	req := protocol.NewRequest(consts.MethodPost, "http://example.com/orders", nil)
+	req.SetBodyString(body)
+	if err := signature.NewSigner("gateway", secrets).Sign(ctxx.WithTenantID(context.Background(), "t1"), req); err != nil {
+		t.Fatal(err)
+	}
+	perform := func(override ...ut.Header) *ut.ResponseRecorder {
+		signed := protocol.RequestHeader{}
+		req.Header.CopyTo(&signed)
+		for _, h := range override {
+			signed.Set(h.Key, h.Value)
+		}
+		var headers []ut.Header
+		signed.VisitAll(func(k, v []byte) {
+			headers = append(headers, ut.Header{Key: string(k), Value: string(v)})
+		})
+		return ut.PerformRequest(engine, consts.MethodPost, "/orders", &ut.Body{Body: bytes.NewBufferString(body), Len: len(body)}, headers...)
+	}
+
+	if w := perform(ut.Header{Key: ctxx.HeaderTenantID, Value: "t2"}); w.Code != consts.StatusUnauthorized {
+		t.Errorf("伪造租户 status = %d, want 401", w.Code)
+	}
+	w := perform()
+	if w.Code != consts.StatusOK || w.Body.String() != "gateway/t1" {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := perform(); w.Code != consts.StatusUnauthorized {
+		t.Errorf("重放 status = %d, want 401", w.Code)
+	}
+	if w := ut.PerformRequest(engine, consts.MethodPost, "/orders", nil, ut.Header{Key: ctxx.HeaderTenantID, Value: "t1"}); w.Code != consts.StatusUnauthorized {
+		t.Errorf("未签名 status = %d, want 401", w.Code)
+	}
+}
+
+func TestSignatureAuthUnsignedHeaders(t *testing.T) {
+	secrets := signature.StaticSecrets{"gateway": {"s1"}}
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Use(NewSignatureAuthMiddleware(signature.NewVerifier(secrets, &signature.VerifierOptions{Audiences: []string{"example.com"}}), nil))
+	engine.GET("/orders", func(ctx context.Context, c *app.RequestContext) {
+		c.String(consts.StatusOK, ctxx.GetTenantID(ctx)+"/"+ctxx.GetRequestID(ctx))
+	})
+
+	req := protocol.NewRequest(consts.MethodGet, "http://example.com/orders", nil)
+	ctx := ctxx.WithRequestID(ctxx.WithTenantID(context.Background(), "t1"), "req-1")
+	if err := signature.NewSigner("gateway", secrets).Sign(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	// 请求 ID 不在签名内，篡改后签名仍然有效，但不能写入 ctxx
+	req.Header.Set(ctxx.HeaderRequestID, "forged")
+	var headers []ut.Header
+	req.Header.VisitAll(func(k, v []byte) {
+		headers = append(headers, ut.Header{Key: string(k), Value: string(v)})
+	})
+	w := ut.PerformRequest(engine, consts.MethodGet, "/orders", nil, headers...)
+	if w.Code != consts.StatusOK || w.Body.String() != "t1/" {
+		t.Errorf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,240 @@
+package hdserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/cloudwego/hertz/pkg/network"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+)
+
+const defaultCertReloadInterval = 30 * time.Second
+
+// CertReloader 从磁盘加载服务端证书与客户端 CA，并在文件变化后自动重新加载
+//
+// 证书通过 tls.Config.GetCertificate、客户端 CA 通过 GetConfigForClient 在每次握手时读取，
+// 替换证书文件后新建连接即使用新证书，已建立的连接不受影响
+type CertReloader struct {
+	cfg  hdmodel.TLS
+	base *tls.Config
+
+	cert    atomic.Pointer[tls.Certificate]
+	clients atomic.Pointer[x509.CertPool]
+	mtimes  map[string]time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewCertReloader 加载证书并开始轮询文件变化
+func NewCertReloader(cfg *hdmodel.TLS) (*CertReloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("TLS 证书与私钥路径不能为空")
+	}
+	minVersion, err := parseTLSVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth, err := parseClientAuth(cfg.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	if clientAuth >= tls.VerifyClientCertIfGiven && cfg.CAFile == "" {
+		return nil, fmt.Errorf("client_auth 为 %s 时必须配置 ca_file", cfg.ClientAuth)
+	}
+
+	r := &CertReloader{
+		cfg:    *cfg,
+		mtimes: make(map[string]time.Time),
+		stop:   make(chan struct{}),
+	}
+	r.base = &tls.Config{
+		MinVersion:     minVersion,
+		ClientAuth:     clientAuth,
+		GetCertificate: r.GetCertificate,
+	}
+	// 先记录修改时间再加载，加载之后的变化会在下次轮询时被发现
+	r.changed()
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	interval := cfg.ReloadInterval
+	if interval <= 0 {
+		interval = defaultCertReloadInterval
+	}
+	r.wg.Add(1)
+	go r.watch(interval)
+	return r, nil
+}
+
+// TLSConfig 返回用于 server.WithTLS 的配置
+func (r *CertReloader) TLSConfig() *tls.Config {
+	cfg := r.base.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := r.base.Clone()
+		c.ClientCAs = r.clients.Load()
+		return c, nil
+	}
+	return cfg
+}
+
+// GetCertificate 返回当前证书
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Reload 重新加载证书与客户端 CA，失败时保留原有证书
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("加载 TLS 证书失败: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.cfg.CAFile != "" {
+		pem, err := os.ReadFile(r.cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("读取客户端 CA 失败: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("解析客户端 CA 失败: %s", r.cfg.CAFile)
+		}
+	}
+	r.cert.Store(&cert)
+	r.clients.Store(pool)
+	return nil
+}
+
+// Close 停止轮询
+func (r *CertReloader) Close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	r.wg.Wait()
+}
+
+func (r *CertReloader) watch(interval time.Duration) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				hlog.Errorf("重新加载 TLS 证书失败，继续使用原证书: %v", err)
+				continue
+			}
+			hlog.Infof("TLS 证书已重新加载 [cert: %s]", r.cfg.CertFile)
+		}
+	}
+}
+
+// changed 检查证书文件的修改时间是否变化
+func (r *CertReloader) changed() bool {
+	changed := false
+	for _, file := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if mtime := info.ModTime(); !mtime.Equal(r.mtimes[file]) {
+			r.mtimes[file] = mtime
+			changed = true
+		}
+	}
+	return changed
+}
+
+// ClientCertMiddleware 把 mTLS 客户端证书身份写入 ctxx，非 TLS 或未提供证书的请求直接放行；
+// 只有通过客户端 CA 校验的证书才会写入，request、require 模式下未校验的证书任何人都能自签，不作为身份
+func ClientCertMiddleware() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		if conn, ok := c.GetConn().(network.ConnTLSer); ok {
+			state := conn.ConnectionState()
+			if len(state.VerifiedChains) > 0 && len(state.PeerCertificates) > 0 {
+				ctx = ctxx.WithPeerIdentity(ctx, peerIdentity(state.PeerCertificates[0]))
+			}
+		}
+		c.Next(ctx)
+	}
+}
+
+// validateTrustedPeers 配置了 trusted_peers 时要求客户端证书经过 CA 校验，否则自签证书即可冒充可信网关
+func validateTrustedPeers(hzCfg *hdmodel.Hertz) error {
+	if len(hzCfg.Context.TrustedPeers) == 0 {
+		return nil
+	}
+	clientAuth, err := parseClientAuth(hzCfg.TLS.ClientAuth)
+	if err != nil {
+		return err
+	}
+	if !hzCfg.TLS.Enable || clientAuth < tls.VerifyClientCertIfGiven {
+		return fmt.Errorf("配置了 trusted_peers 时 client_auth 必须为 verify_if_given 或 require_and_verify，当前为 %q", hzCfg.TLS.ClientAuth)
+	}
+	return nil
+}
+
+// peerIdentity 从已通过 CA 校验的证书生成身份
+func peerIdentity(cert *x509.Certificate) *ctxx.PeerIdentity {
+	sum := sha256.Sum256(cert.Raw)
+	peer := &ctxx.PeerIdentity{
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		DNSNames:     cert.DNSNames,
+		SerialNumber: cert.SerialNumber.String(),
+		Fingerprint:  hex.EncodeToString(sum[:]),
+		Verified:     true,
+	}
+	for _, u := range cert.URIs {
+		peer.URIs = append(peer.URIs, u.String())
+	}
+	return peer
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("不支持的 TLS 版本: %s", v)
+	}
+}
+
+func parseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "require":
+		return tls.RequireAnyClientCert, nil
+	case "verify_if_given":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require_and_verify":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("不支持的 client_auth: %s", mode)
+	}
+}
@@ -0,0 +1,269 @@
+package hdserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/network"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, cn string, serial int64, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn, Organization: []string{"hertzcommon"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	if !isCA {
+		tmpl.URIs = []*url.URL{{Scheme: "spiffe", Host: "cluster.local", Path: "/sa/" + cn}}
+	}
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	der, _ := x509.MarshalECPrivateKey(c.key)
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if keyFile != "" {
+		if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestHdServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", 1, nil, true)
+	ca.write(t, filepath.Join(dir, "ca.pem"), "")
+	newTestCert(t, "server", 2, ca, false).write(t, filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"))
+	client := newTestCert(t, "order-svc", 3, ca, false)
+
+	addr := freeAddr(t)
+	h := NewHdServer(&hdmodel.Hertz{
+		Service: "tls-test",
+		Address: addr,
+		TLS: hdmodel.TLS{
+			Enable:     true,
+			CertFile:   filepath.Join(dir, "server.pem"),
+			KeyFile:    filepath.Join(dir, "server.key"),
+			CAFile:     filepath.Join(dir, "ca.pem"),
+			ClientAuth: "require_and_verify",
+		},
+	}, &hdmodel.Monitor{})
+	h.GET("/whoami", func(ctx context.Context, c *app.RequestContext) {
+		peer := ctxx.GetPeerIdentity(ctx)
+		if peer == nil {
+			c.String(consts.StatusOK, "anonymous")
+			return
+		}
+		c.String(consts.StatusOK, fmt.Sprintf("%s %v", peer.CommonName, peer.URIs))
+	})
+	go func() { _ = h.Run() }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_ = h.Shutdown(ctx)
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certs ...tls.Certificate) (string, error) {
+		hc := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		var lastErr error
+		for i := 0; i < 50; i++ {
+			resp, err := hc.Get("https://" + addr + "/whoami")
+			if err == nil {
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				return string(body), nil
+			}
+			lastErr = err
+			var opErr *net.OpError
+			if !errors.As(err, &opErr) || opErr.Op != "dial" {
+				return "", err
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return "", lastErr
+	}
+
+	body, err := get(client.tlsCert())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "order-svc [spiffe://cluster.local/sa/order-svc]"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+	if _, err := get(); err == nil {
+		t.Error("未提供客户端证书时握手应失败")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	ca := newTestCert(t, "test-ca", 1, nil, true)
+	newTestCert(t, "server-v1", 10, ca, false).write(t, certFile, keyFile)
+
+	r, err := NewCertReloader(&hdmodel.TLS{CertFile: certFile, KeyFile: keyFile, ReloadInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	serial := func() int64 {
+		cert, _ := r.GetCertificate(nil)
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.SerialNumber.Int64()
+	}
+	if serial() != 10 {
+		t.Fatalf("serial = %d, want 10", serial())
+	}
+
+	// 写入损坏的证书，保留原证书
+	if err := os.WriteFile(certFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	bumpMtime(t, certFile, time.Second)
+	time.Sleep(100 * time.Millisecond)
+	if serial() != 10 {
+		t.Fatalf("加载失败后 serial = %d, want 10", serial())
+	}
+
+	newTestCert(t, "server-v2", 11, ca, false).write(t, certFile, keyFile)
+	bumpMtime(t, certFile, 2*time.Second)
+	bumpMtime(t, keyFile, 2*time.Second)
+	deadline := time.Now().Add(2 * time.Second)
+	for serial() != 11 {
+		if time.Now().After(deadline) {
+			t.Fatal("证书未重新加载")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := NewCertReloader(&hdmodel.TLS{CertFile: certFile, KeyFile: keyFile, ClientAuth: "require_and_verify"}); err == nil {
+		t.Error("开启 mTLS 但未配置 CA 时应返回错误")
+	}
+}
+
+// bumpMtime 推后文件修改时间，避免文件系统时间精度导致变化未被发现
+func bumpMtime(t *testing.T, file string, d time.Duration) {
+	t.Helper()
+	mtime := time.Now().Add(d)
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tlsConn 只提供 TLS 连接状态的连接
+type tlsConn struct {
+	network.Conn
+	state tls.ConnectionState
+}
+
+func (c *tlsConn) Handshake() error                     { return nil }
+func (c *tlsConn) ConnectionState() tls.ConnectionState { return c.state }
+
+func TestClientCertUnverified(t *testing.T) {
+	// request/require 模式下客户端可以出示任意自签证书
+	selfSigned := newTestCert(t, "trusted", 7, nil, false)
+	extract, err := NewContextExtractMiddleware(&hdmodel.ContextExtract{TrustedPeers: []string{"trusted"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(state tls.ConnectionState) (peer *ctxx.PeerIdentity, tenant, source string) {
+		c := app.NewContext(0)
+		c.SetConn(&tlsConn{state: state})
+		c.Request.Header.Set("X-Tenant-Id", "t1")
+		c.SetHandlers(app.HandlersChain{ClientCertMiddleware(), extract, func(ctx context.Context, c *app.RequestContext) {
+			peer, tenant, source = ctxx.GetPeerIdentity(ctx), ctxx.GetTenantID(ctx), GetRequestSource(c)
+		}})
+		c.Next(context.Background())
+		return
+	}
+	certs := []*x509.Certificate{selfSigned.cert}
+	if peer, tenant, source := serve(tls.ConnectionState{PeerCertificates: certs}); peer != nil || tenant != "" || source != SourcePublic {
+		t.Errorf("未校验的证书不应被信任: peer = %+v, tenant = %q, source = %s", peer, tenant, source)
+	}
+	// 对照：同一证书通过 CA 校验时可信
+	verified := tls.ConnectionState{PeerCertificates: certs, VerifiedChains: [][]*x509.Certificate{certs}}
+	if peer, tenant, source := serve(verified); peer == nil || tenant != "t1" || source != SourceGateway {
+		t.Errorf("校验过的证书应被信任: peer = %+v, tenant = %q, source = %s", peer, tenant, source)
+	}
+
+	for _, mode := range []string{"", "request", "require"} {
+		err := validateTrustedPeers(&hdmodel.Hertz{
+			TLS:     hdmodel.TLS{Enable: true, ClientAuth: mode},
+			Context: hdmodel.ContextExtract{TrustedPeers: []string{"trusted"}},
+		})
+		if err == nil {
+			t.Errorf("client_auth = %q 时配置 trusted_peers 应返回错误", mode)
+		}
+	}
+	if err := validateTrustedPeers(&hdmodel.Hertz{
+		TLS:     hdmodel.TLS{Enable: true, ClientAuth: "verify_if_given"},
+		Context: hdmodel.ContextExtract{TrustedPeers: []string{"trusted"}},
+	}); err != nil {
+		t.Error(err)
+	}
+}
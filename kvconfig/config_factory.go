@@ -5,7 +5,9 @@ import (
 	"os"
 	"strings"
 
+	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"gopkg.in/yaml.v2"
 )
 
 // ConfigType 配置类型
@@ -16,6 +18,18 @@ const (
 	ConfigTypeNacos  ConfigType = "nacos"
 )
 
+// 约定的配置 dataId
+const (
+	PasetoPubDataId    = "pasetopub"
+	PasetoSecretDataId = "pasetosecret"
+	AuthzDataId        = "authz"
+	ApiKeysDataId      = "apikeys"
+	SignKeysDataId     = "signkeys"
+	MqFairDataId       = "mqfair"
+	StorageDataId      = "storage"
+	DBShardsDataId     = "dbshards"
+)
+
 type ConfigFactoryOptions struct {
 	ServerAddr  string
 	NamespaceId string
@@ -239,7 +253,7 @@ func (f *ConfigFactory) GetPasetoPubConfig(group string) (*hdmodel.PasetoConfig,
 }
 
 // GetPasetoSecretConfig 获取 Paseto 密钥配置（兼容接口）
-func (f *ConfigFactory) GetPasetoSecretConfig(group string) (*hdmodel.PasetoConfig, error) {
+func (f *ConfigFactory) GetPasetoSecretConfig(group string) (*hdmodel.PasetoSecretConfig, error) {
 	switch f.configType {
 	case ConfigTypeNacos:
 		if f.nacosClient == nil {
@@ -256,6 +270,81 @@ func (f *ConfigFactory) GetPasetoSecretConfig(group string) (*hdmodel.PasetoConf
 	}
 }
 
+// PublishConfig 发布配置（兼容接口）
+func (f *ConfigFactory) PublishConfig(dataId, group, content string) error {
+	switch f.configType {
+	case ConfigTypeNacos:
+		if f.nacosClient == nil {
+			return fmt.Errorf("nacos 客户端未初始化")
+		}
+		return f.nacosClient.PublishConfig(dataId, group, content)
+	case ConfigTypeConsul:
+		if f.consulClient == nil {
+			return fmt.Errorf("consul 客户端未初始化")
+		}
+		return f.consulClient.PublishConfig(dataId, group, content)
+	default:
+		return fmt.Errorf("不支持的配置类型: %s", f.configType)
+	}
+}
+
+// ListenConfig 监听配置变化（兼容接口）
+func (f *ConfigFactory) ListenConfig(dataId, group string, callback func(string)) error {
+	switch f.configType {
+	case ConfigTypeNacos:
+		if f.nacosClient == nil {
+			return fmt.Errorf("nacos 客户端未初始化")
+		}
+		return f.nacosClient.ListenConfig(dataId, group, callback)
+	case ConfigTypeConsul:
+		if f.consulClient == nil {
+			return fmt.Errorf("consul 客户端未初始化")
+		}
+		return f.consulClient.ListenConfig(dataId, group, callback)
+	default:
+		return fmt.Errorf("不支持的配置类型: %s", f.configType)
+	}
+}
+
+// WatchPasetoPubConfig 监听 Paseto 公钥配置变化，解析失败或配置被删除时不回调
+func (f *ConfigFactory) WatchPasetoPubConfig(group string, callback func(*hdmodel.PasetoConfig)) error {
+	return WatchYamlConfig(f, PasetoPubDataId, group, callback)
+}
+
+// WatchPasetoSecretConfig 监听 Paseto 私钥配置变化，解析失败或配置被删除时不回调
+func (f *ConfigFactory) WatchPasetoSecretConfig(group string, callback func(*hdmodel.PasetoSecretConfig)) error {
+	return WatchYamlConfig(f, PasetoSecretDataId, group, callback)
+}
+
+// GetYamlConfig 读取 YAML 配置并解析为 T
+func GetYamlConfig[T any](f *ConfigFactory, dataId, group string) (*T, error) {
+	content, err := f.GetKvConfig(dataId, group)
+	if err != nil {
+		return nil, err
+	}
+	conf := new(T)
+	if err := yaml.Unmarshal([]byte(content), conf); err != nil {
+		return nil, fmt.Errorf("解析配置失败 [dataId: %s, group: %s]: %w", dataId, group, err)
+	}
+	return conf, nil
+}
+
+// WatchYamlConfig 监听 YAML 配置变化并解析为 T，解析失败或配置被删除时不回调
+func WatchYamlConfig[T any](f *ConfigFactory, dataId, group string, callback func(*T)) error {
+	return f.ListenConfig(dataId, group, func(content string) {
+		if content == "" {
+			hlog.Warnf("配置为空，忽略本次变更 [dataId: %s, group: %s]", dataId, group)
+			return
+		}
+		conf := new(T)
+		if err := yaml.Unmarshal([]byte(content), conf); err != nil {
+			hlog.Errorf("解析配置失败 [dataId: %s, group: %s]: %v", dataId, group, err)
+			return
+		}
+		callback(conf)
+	})
+}
+
 // GetNacosClient 获取 Nacos 客户端（用于高级操作）
 func (f *ConfigFactory) GetNacosClient() *NacosConfigClient {
 	return f.nacosClient
@@ -337,6 +426,6 @@ func GetPasetoPubConfigGlobal(group string) (*hdmodel.PasetoConfig, error) {
 	return GetGlobalConfigFactory().GetPasetoPubConfig(group)
 }
 
-func GetPasetoSecretConfigGlobal(group string) (*hdmodel.PasetoConfig, error) {
+func GetPasetoSecretConfigGlobal(group string) (*hdmodel.PasetoSecretConfig, error) {
 	return GetGlobalConfigFactory().GetPasetoSecretConfig(group)
 }
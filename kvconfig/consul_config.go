@@ -251,7 +251,7 @@ func (c *ConsulConfigClient) GetCommonConfig(group string) (*CommonConfig, error
 
 // GetPasetoPubConfig 获取 Paseto 公钥配置
 func (c *ConsulConfigClient) GetPasetoPubConfig(group string) (*hdmodel.PasetoConfig, error) {
-	content, err := c.GetConfig("pasetopub", group)
+	content, err := c.GetConfig(PasetoPubDataId, group)
 	if err != nil {
 		return nil, err
 	}
@@ -266,13 +266,13 @@ func (c *ConsulConfigClient) GetPasetoPubConfig(group string) (*hdmodel.PasetoCo
 }
 
 // GetPasetoSecretConfig 获取 Paseto 密钥配置
-func (c *ConsulConfigClient) GetPasetoSecretConfig(group string) (*hdmodel.PasetoConfig, error) {
-	content, err := c.GetConfig("pasetosecret", group)
+func (c *ConsulConfigClient) GetPasetoSecretConfig(group string) (*hdmodel.PasetoSecretConfig, error) {
+	content, err := c.GetConfig(PasetoSecretDataId, group)
 	if err != nil {
 		return nil, err
 	}
 
-	conf := new(hdmodel.PasetoConfig)
+	conf := new(hdmodel.PasetoSecretConfig)
 	err = yaml.Unmarshal([]byte(content), &conf)
 	if err != nil {
 		return nil, fmt.Errorf("解析配置失败: %w", err)
@@ -385,7 +385,7 @@ func GetPasetoPubConfig(registryAddr string) (*hdmodel.PasetoConfig, error) {
 
 	return conf, nil
 }
-func GetPasetoSecretConfig(registryAddr string) (*hdmodel.PasetoConfig, error) {
+func GetPasetoSecretConfig(registryAddr string) (*hdmodel.PasetoSecretConfig, error) {
 	client, err := api.NewClient(&api.Config{Address: registryAddr})
 	if err != nil {
 		fmt.Println("Error creating Consul client:", err)
@@ -397,7 +397,7 @@ func GetPasetoSecretConfig(registryAddr string) (*hdmodel.PasetoConfig, error) {
 		fmt.Println("Error getting config:", err)
 		return nil, err
 	}
-	conf := new(hdmodel.PasetoConfig)
+	conf := new(hdmodel.PasetoSecretConfig)
 	err = yaml.Unmarshal(content.Value, &conf)
 	if err != nil {
 		hlog.Error("parse yaml error - %v", err)
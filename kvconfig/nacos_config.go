@@ -137,7 +137,7 @@ func (c *NacosConfigClient) GetKvConfigRaw(dataId, group string) (string, error)
 
 // GetPasetoPubConfig 获取 Paseto 公钥配置
 func (c *NacosConfigClient) GetPasetoPubConfig(group string) (*hdmodel.PasetoConfig, error) {
-	content, err := c.GetConfig("pasetopub", group)
+	content, err := c.GetConfig(PasetoPubDataId, group)
 	if err != nil {
 		return nil, err
 	}
@@ -153,13 +153,13 @@ func (c *NacosConfigClient) GetPasetoPubConfig(group string) (*hdmodel.PasetoCon
 }
 
 // GetPasetoSecretConfig 获取 Paseto 密钥配置
-func (c *NacosConfigClient) GetPasetoSecretConfig(group string) (*hdmodel.PasetoConfig, error) {
-	content, err := c.GetConfig("pasetosecret", group)
+func (c *NacosConfigClient) GetPasetoSecretConfig(group string) (*hdmodel.PasetoSecretConfig, error) {
+	content, err := c.GetConfig(PasetoSecretDataId, group)
 	if err != nil {
 		return nil, err
 	}
 
-	conf := new(hdmodel.PasetoConfig)
+	conf := new(hdmodel.PasetoSecretConfig)
 	err = yaml.Unmarshal([]byte(content), &conf)
 	if err != nil {
 		hlog.Error("解析 Paseto 密钥配置失败: %v", err)
@@ -16,6 +16,7 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -35,6 +36,21 @@ import (
 
 var Reg *prometheus.Registry
 
+// RegisterOrExisting 注册指标，已注册过同名指标时返回已有的，
+// 同一进程多次创建组件时可以共用一组指标
+func RegisterOrExisting[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
 func initMetric(serverName string, cfg *hdmodel.Monitor) route.CtxCallback {
 	if cfg.Prometheus.Enable {
 		hlog.Info("开启Prometheus监控")
@@ -38,6 +38,8 @@ func AttachTenantAttributes() app.HandlerFunc {
         }
 
         span.SetAttributes(attribute.String("user.id", uid))
+        // 已注册的 ctxx 字段（含自定义字段）
+        span.SetAttributes(ctxx.SpanAttributes(ctx)...)
     }
 }
 
@@ -37,6 +37,8 @@ func (p *tenantIDProcessor) OnStart(ctx context.Context, s trace.ReadWriteSpan)
 		s.SetAttributes(attribute.String("merchant.id.status", "没有商户信息"))
 	}
 	s.SetAttributes(attribute.String("user.id", uid))
+	// 已注册的 ctxx 字段（含自定义字段）
+	s.SetAttributes(ctxx.SpanAttributes(ctx)...)
 }
 
 func (p *tenantIDProcessor) Shutdown(ctx context.Context) error   { return p.next.Shutdown(ctx) }
@@ -0,0 +1,57 @@
+package mq
+
+import (
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+)
+
+// DefaultRegistry 预先注册了 hdmodel 中 MQ DTO 的消息类型注册表。
+//
+// v1 为引入信封之前的格式：UserImageMqDto 与 ActivityMqDto 没有 json 标签，字段名为 Go 字段名，
+// UserImageResultMqDto 的 TenantId 没有标签；v2 统一为 snake_case
+var DefaultRegistry = newDefaultRegistry()
+
+// v1 的消息结构，仅用于生成 JSON Schema
+type (
+	userImageMqDtoV1 struct {
+		UserID   int64
+		TenantId string
+	}
+	userImageResultMqDtoV1 struct {
+		UserID    int64  `json:"user_id"`
+		AvatarUrl string `json:"avatar_url"`
+		Success   bool   `json:"success"`
+		Error     string `json:"error,omitempty"`
+		TenantId  string
+	}
+	activityMqDtoV1 struct {
+		ActivityId string
+		TenantId   string
+	}
+)
+
+func newDefaultRegistry() *Registry {
+	r := NewRegistry()
+	must := func(err error) {
+		if err != nil {
+			panic(err)
+		}
+	}
+	must(r.Register(hdmodel.MqTypeUserImage, 1, userImageMqDtoV1{}))
+	must(r.Register(hdmodel.MqTypeUserImage, 2, hdmodel.UserImageMqDto{}))
+	must(r.RegisterUpcaster(hdmodel.MqTypeUserImage, 1, RenameFields(map[string]string{
+		"UserID": "user_id", "TenantId": "tenant_id",
+	})))
+
+	must(r.Register(hdmodel.MqTypeUserImageResult, 1, userImageResultMqDtoV1{}))
+	must(r.Register(hdmodel.MqTypeUserImageResult, 2, hdmodel.UserImageResultMqDto{}))
+	must(r.RegisterUpcaster(hdmodel.MqTypeUserImageResult, 1, RenameFields(map[string]string{
+		"TenantId": "tenant_id",
+	})))
+
+	must(r.Register(hdmodel.MqTypeActivity, 1, activityMqDtoV1{}))
+	must(r.Register(hdmodel.MqTypeActivity, 2, hdmodel.ActivityMqDto{}))
+	must(r.RegisterUpcaster(hdmodel.MqTypeActivity, 1, RenameFields(map[string]string{
+		"ActivityId": "activity_id", "TenantId": "tenant_id",
+	})))
+	return r
+}
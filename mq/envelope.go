@@ -0,0 +1,316 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// 消息类型与版本的消息头，便于不解析消息体就能路由与过滤
+const (
+	HeaderMessageType    = "x-msg-type"
+	HeaderMessageVersion = "x-msg-version"
+)
+
+// Envelope 标准消息信封，data 为按 type/version 注册的 DTO
+type Envelope struct {
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	ID         string    `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	TenantID   string    `json:"tenant_id,omitempty"`
+	// Trace 产生事件时的 W3C traceparent
+	Trace string          `json:"trace,omitempty"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Upcaster 把 from 版本的 data 升级为 from+1 版本
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+type typeVersion struct {
+	typ     string
+	version int
+}
+
+// Registry 消息类型注册表，维护 type/version 到 Go 结构体的映射与相邻版本之间的升级函数
+type Registry struct {
+	mu        sync.RWMutex
+	types     map[typeVersion]reflect.Type
+	latest    map[string]int
+	byGoType  map[reflect.Type]typeVersion
+	upcasters map[typeVersion]Upcaster
+}
+
+// NewRegistry 创建空的消息类型注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		types:     make(map[typeVersion]reflect.Type),
+		latest:    make(map[string]int),
+		byGoType:  make(map[reflect.Type]typeVersion),
+		upcasters: make(map[typeVersion]Upcaster),
+	}
+}
+
+// Register 注册消息类型的一个版本，sample 为该版本的结构体（值或指针均可）。
+// 最高的版本为当前版本，Wrap 按当前版本发送，Unwrap 把旧版本升级到当前版本
+func (r *Registry) Register(typ string, version int, sample any) error {
+	if typ == "" || version < 1 {
+		return fmt.Errorf("注册消息类型失败: type 不能为空且 version 从 1 开始 [%s v%d]", typ, version)
+	}
+	t := reflect.TypeOf(sample)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("注册消息类型失败 [%s v%d]: 需要结构体, 实际为 %v", typ, version, t)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := typeVersion{typ, version}
+	if _, ok := r.types[key]; ok {
+		return fmt.Errorf("注册消息类型失败: %s v%d 已注册", typ, version)
+	}
+	r.types[key] = t
+	if version > r.latest[typ] {
+		r.latest[typ] = version
+	}
+	if prev, ok := r.byGoType[t]; !ok || version > prev.version {
+		r.byGoType[t] = key
+	}
+	return nil
+}
+
+// RegisterUpcaster 注册 from 版本升级到 from+1 版本的函数
+func (r *Registry) RegisterUpcaster(typ string, from int, up Upcaster) error {
+	if up == nil {
+		return fmt.Errorf("注册升级函数失败 [%s v%d]: 升级函数为空", typ, from)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := typeVersion{typ, from}
+	if _, ok := r.upcasters[key]; ok {
+		return fmt.Errorf("注册升级函数失败: %s v%d 已注册", typ, from)
+	}
+	r.upcasters[key] = up
+	return nil
+}
+
+// Latest 返回消息类型的当前版本，未注册时返回 0
+func (r *Registry) Latest(typ string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.latest[typ]
+}
+
+// Wrap 用 v 的当前版本创建信封，租户取自 ctx 的 ctxx 字段，trace 取自 ctx 的链路上下文
+func (r *Registry) Wrap(ctx context.Context, v any) (*Envelope, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	r.mu.RLock()
+	key, ok := r.byGoType[t]
+	latest := r.latest[key.typ]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("创建消息信封失败: %v 未注册", t)
+	}
+	if key.version != latest {
+		return nil, fmt.Errorf("创建消息信封失败: %v 是 %s 的旧版本 v%d，当前版本为 v%d", t, key.typ, key.version, latest)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("序列化消息失败 [%s]: %w", key.typ, err)
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return &Envelope{
+		Type:       key.typ,
+		Version:    key.version,
+		ID:         uuid.NewString(),
+		OccurredAt: time.Now().UTC(),
+		TenantID:   ctxx.GetTenantID(ctx),
+		Trace:      carrier.Get("traceparent"),
+		Data:       data,
+	}, nil
+}
+
+// Upcast 依次调用升级函数把信封升级到当前版本，已是当前版本时原样返回
+func (r *Registry) Upcast(env *Envelope) (*Envelope, error) {
+	r.mu.RLock()
+	latest := r.latest[env.Type]
+	r.mu.RUnlock()
+	if latest == 0 {
+		return nil, fmt.Errorf("未注册的消息类型: %s", env.Type)
+	}
+	if env.Version > latest {
+		return nil, fmt.Errorf("消息版本高于当前版本 [%s]: v%d > v%d", env.Type, env.Version, latest)
+	}
+	out := *env
+	for out.Version < latest {
+		r.mu.RLock()
+		up, ok := r.upcasters[typeVersion{out.Type, out.Version}]
+		r.mu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("缺少升级函数 [%s]: v%d -> v%d", out.Type, out.Version, out.Version+1)
+		}
+		data, err := up(out.Data)
+		if err != nil {
+			return nil, fmt.Errorf("升级消息失败 [%s]: v%d -> v%d: %w", out.Type, out.Version, out.Version+1, err)
+		}
+		out.Data = data
+		out.Version++
+	}
+	return &out, nil
+}
+
+// Unwrap 把信封升级到当前版本后解析 data，返回当前版本结构体的指针
+func (r *Registry) Unwrap(env *Envelope) (any, error) {
+	env, err := r.Upcast(env)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	t, ok := r.types[typeVersion{env.Type, env.Version}]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未注册的消息版本: %s v%d", env.Type, env.Version)
+	}
+	v := reflect.New(t).Interface()
+	if err := json.Unmarshal(env.Data, v); err != nil {
+		return nil, fmt.Errorf("解析消息失败 [%s v%d]: %w", env.Type, env.Version, err)
+	}
+	return v, nil
+}
+
+// Decode 把信封升级到当前版本后解析 data 到 out，out 的类型必须是该消息类型当前版本的结构体指针
+func (r *Registry) Decode(env *Envelope, out any) error {
+	v, err := r.Unwrap(env)
+	if err != nil {
+		return err
+	}
+	dst := reflect.ValueOf(out)
+	if dst.Kind() != reflect.Pointer || dst.IsNil() || dst.Type() != reflect.TypeOf(v) {
+		return fmt.Errorf("解析消息失败 [%s v%d]: 需要 %T, 实际为 %T", env.Type, env.Version, v, out)
+	}
+	dst.Elem().Set(reflect.ValueOf(v).Elem())
+	return nil
+}
+
+// NewMessage 把 v 包装为信封消息，消息头写入类型与版本
+func (r *Registry) NewMessage(ctx context.Context, key string, v any) (*Message, error) {
+	env, err := r.Wrap(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("序列化消息信封失败 [%s]: %w", env.Type, err)
+	}
+	return &Message{
+		Key:  key,
+		Body: body,
+		Headers: map[string]string{
+			HeaderMessageType:    env.Type,
+			HeaderMessageVersion: strconv.Itoa(env.Version),
+		},
+	}, nil
+}
+
+// Parse 解析消息体为信封并升级到当前版本。
+// 消息体不是信封（引入信封之前直接发送的 DTO）时按 legacyType 的 v1 处理，legacyType 为空时返回错误
+func (r *Registry) Parse(body []byte, legacyType string) (*Envelope, error) {
+	var env Envelope
+	if isEnvelope(body) {
+		if err := json.Unmarshal(body, &env); err != nil {
+			return nil, fmt.Errorf("解析消息信封失败: %w", err)
+		}
+	} else {
+		if legacyType == "" {
+			return nil, fmt.Errorf("解析消息信封失败: 消息体不是信封")
+		}
+		env = Envelope{Type: legacyType, Version: 1, Data: json.RawMessage(bytes.Clone(body))}
+	}
+	return r.Upcast(&env)
+}
+
+// isEnvelope 判断消息体是否为信封：包含 type、version 与 data 字段
+func isEnvelope(body []byte) bool {
+	var probe map[string]json.RawMessage
+	if json.Unmarshal(body, &probe) != nil {
+		return false
+	}
+	for _, k := range []string{"type", "version", "data"} {
+		if _, ok := probe[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Schema 生成消息类型某个版本 data 的 JSON Schema
+func (r *Registry) Schema(typ string, version int) ([]byte, error) {
+	r.mu.RLock()
+	t, ok := r.types[typeVersion{typ, version}]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未注册的消息版本: %s v%d", typ, version)
+	}
+	s := jsonSchema(t, nil)
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["$id"] = fmt.Sprintf("%s.v%d", typ, version)
+	s["title"] = fmt.Sprintf("%s v%d", typ, version)
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// TypeVersion 已注册的消息类型与版本
+type TypeVersion struct {
+	Type    string
+	Version int
+}
+
+// Types 按类型与版本排序返回全部已注册的版本
+func (r *Registry) Types() []TypeVersion {
+	r.mu.RLock()
+	out := make([]TypeVersion, 0, len(r.types))
+	for k := range r.types {
+		out = append(out, TypeVersion{Type: k.typ, Version: k.version})
+	}
+	r.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Type != out[j].Type {
+			return out[i].Type < out[j].Type
+		}
+		return out[i].Version < out[j].Version
+	})
+	return out
+}
+
+// RenameFields 返回按 renames（旧字段名 -> 新字段名）重命名顶层字段的升级函数
+func RenameFields(renames map[string]string) Upcaster {
+	return func(data json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		for from, to := range renames {
+			if v, ok := fields[from]; ok {
+				delete(fields, from)
+				if _, exists := fields[to]; !exists {
+					fields[to] = v
+				}
+			}
+		}
+		return json.Marshal(fields)
+	}
+}
@@ -0,0 +1,220 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	tp, _ := setupTracer(t)
+	ctx, span := tp.Tracer("test").Start(context.Background(), "upload")
+	defer span.End()
+	ctx = ctxx.WithTenantID(ctx, "t1")
+
+	msg, err := DefaultRegistry.NewMessage(ctx, "42", &hdmodel.UserImageMqDto{UserID: 42, TenantId: "t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Headers[HeaderMessageType] != hdmodel.MqTypeUserImage || msg.Headers[HeaderMessageVersion] != "2" {
+		t.Errorf("headers = %v", msg.Headers)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(msg.Body, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"type", "version", "id", "occurred_at", "tenant_id", "trace", "data"} {
+		if _, ok := raw[k]; !ok {
+			t.Errorf("信封缺少 %s: %s", k, msg.Body)
+		}
+	}
+	if data := raw["data"].(map[string]any); data["user_id"] != float64(42) || data["tenant_id"] != "t1" {
+		t.Errorf("data = %v", data)
+	}
+
+	env, err := DefaultRegistry.Parse(msg.Body, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.TenantID != "t1" || !strings.Contains(env.Trace, span.SpanContext().TraceID().String()) {
+		t.Errorf("env = %+v", env)
+	}
+	var dto hdmodel.UserImageMqDto
+	if err := DefaultRegistry.Decode(env, &dto); err != nil {
+		t.Fatal(err)
+	}
+	if dto != (hdmodel.UserImageMqDto{UserID: 42, TenantId: "t1"}) {
+		t.Errorf("dto = %+v", dto)
+	}
+	var wrong hdmodel.ActivityMqDto
+	if err := DefaultRegistry.Decode(env, &wrong); err == nil {
+		t.Error("类型不匹配时应返回错误")
+	}
+}
+
+func TestUpcastLegacyMessages(t *testing.T) {
+	tests := []struct {
+		typ  string
+		body string
+		want any
+	}{
+		{hdmodel.MqTypeUserImage, `{"UserID":7,"TenantId":"t1"}`, &hdmodel.UserImageMqDto{UserID: 7, TenantId: "t1"}},
+		{hdmodel.MqTypeUserImageResult, `{"user_id":7,"avatar_url":"a.png","success":true,"TenantId":"t1"}`,
+			&hdmodel.UserImageResultMqDto{UserID: 7, AvatarUrl: "a.png", Success: true, TenantId: "t1"}},
+		{hdmodel.MqTypeActivity, `{"ActivityId":"a1","TenantId":"t1"}`, &hdmodel.ActivityMqDto{ActivityId: "a1", TenantId: "t1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			// 引入信封之前的消息体
+			env, err := DefaultRegistry.Parse([]byte(tt.body), tt.typ)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if env.Version != 2 {
+				t.Errorf("version = %d, want 2", env.Version)
+			}
+			got, err := DefaultRegistry.Unwrap(env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+
+			// v1 信封
+			v1, _ := json.Marshal(Envelope{Type: tt.typ, Version: 1, ID: "x", Data: json.RawMessage(tt.body)})
+			env, err = DefaultRegistry.Parse(v1, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := DefaultRegistry.Unwrap(env); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("v1 信封 got %+v, want %+v", got, tt.want)
+			}
+
+			// 不经过注册表直接解码旧的消息体
+			direct := reflect.New(reflect.TypeOf(tt.want).Elem()).Interface()
+			if err := json.Unmarshal([]byte(tt.body), direct); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(direct, tt.want) {
+				t.Errorf("直接解码 got %+v, want %+v", direct, tt.want)
+			}
+		})
+	}
+
+	if _, err := DefaultRegistry.Parse([]byte(`{"UserID":1}`), ""); err == nil {
+		t.Error("非信封且未指定类型时应返回错误")
+	}
+	if _, err := DefaultRegistry.Parse([]byte(`{"type":"user_image","version":3,"data":{}}`), ""); err == nil {
+		t.Error("高于当前版本时应返回错误")
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	r := NewRegistry()
+	type orderV1 struct{ ID string }
+	type orderV2 struct {
+		ID string `json:"id"`
+	}
+	type orderV3 struct {
+		OrderID string `json:"order_id"`
+	}
+	if err := r.Register("order", 1, orderV1{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("order", 1, orderV2{}); err == nil {
+		t.Error("重复注册版本应返回错误")
+	}
+	if err := r.Register("order", 2, "x"); err == nil {
+		t.Error("非结构体应返回错误")
+	}
+	if err := r.Register("order", 2, &orderV2{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("order", 3, orderV3{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RegisterUpcaster("order", 2, RenameFields(map[string]string{"id": "order_id"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Wrap(context.Background(), orderV1{}); err == nil {
+		t.Error("旧版本结构体不能创建信封")
+	}
+	if _, err := r.Wrap(context.Background(), struct{}{}); err == nil {
+		t.Error("未注册的结构体不能创建信封")
+	}
+	// 缺少 v1 -> v2 的升级函数
+	if _, err := r.Upcast(&Envelope{Type: "order", Version: 1, Data: json.RawMessage(`{"ID":"o1"}`)}); err == nil {
+		t.Error("缺少升级函数时应返回错误")
+	}
+	env, err := r.Upcast(&Envelope{Type: "order", Version: 2, Data: json.RawMessage(`{"id":"o1"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Version != 3 || string(env.Data) != `{"order_id":"o1"}` {
+		t.Errorf("env = %d %s", env.Version, env.Data)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	for _, tv := range DefaultRegistry.Types() {
+		if _, err := DefaultRegistry.Schema(tv.Type, tv.Version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(DefaultRegistry.Types()); got != 6 {
+		t.Errorf("types = %d, want 6", got)
+	}
+
+	b, err := DefaultRegistry.Schema(hdmodel.MqTypeUserImageResult, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s struct {
+		ID         string                    `json:"$id"`
+		Type       string                    `json:"type"`
+		Properties map[string]map[string]any `json:"properties"`
+		Required   []string                  `json:"required"`
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID != "user_image_result.v2" || s.Type != "object" {
+		t.Errorf("schema = %s", b)
+	}
+	want := map[string]string{"user_id": "integer", "avatar_url": "string", "success": "boolean", "error": "string", "tenant_id": "string"}
+	for k, typ := range want {
+		if s.Properties[k]["type"] != typ {
+			t.Errorf("%s.type = %v, want %s", k, s.Properties[k]["type"], typ)
+		}
+	}
+	if !reflect.DeepEqual(s.Required, []string{"user_id", "avatar_url", "success", "tenant_id"}) {
+		t.Errorf("required = %v", s.Required)
+	}
+
+	type nested struct {
+		Tags  []string          `json:"tags"`
+		Attrs map[string]int    `json:"attrs,omitempty"`
+		Next  *nested           `json:"next"`
+		Raw   json.RawMessage   `json:"raw"`
+		Span  trace.SpanContext `json:"-"`
+	}
+	ns := jsonSchema(reflect.TypeOf(nested{}), nil)
+	props := ns["properties"].(map[string]any)
+	if props["tags"].(map[string]any)["type"] != "array" || props["attrs"].(map[string]any)["type"] != "object" {
+		t.Errorf("nested = %v", ns)
+	}
+	if _, ok := props["Span"]; ok {
+		t.Error(`json:"-" 的字段不应出现在 schema 中`)
+	}
+	if !reflect.DeepEqual(ns["required"], []string{"tags", "raw"}) {
+		t.Errorf("required = %v", ns["required"])
+	}
+}
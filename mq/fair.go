@@ -0,0 +1,516 @@
+package mq
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/kvconfig"
+	"github.com/grayscalecloud/hertzcommon/monitor"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const unknownTenant = "unknown"
+
+// FairPolicy 租户公平调度策略，从配置中心的 mqfair 配置加载
+//
+//	default_weight: 1
+//	default_max_concurrency: 2
+//	max_queued: 256
+//	tenant_max_queued: 64
+//	tenants:
+//	  t-vip: {weight: 4, max_concurrency: 8, max_queued: 128}
+//	  t-noisy: {weight: 1, max_concurrency: 1}
+type FairPolicy struct {
+	// DefaultWeight 未单独配置的租户的权重，默认 1
+	DefaultWeight int `yaml:"default_weight" json:"default_weight"`
+	// DefaultMaxConcurrency 未单独配置的租户同时处理的最大消息数，0 表示只受消费者 Concurrency 限制
+	DefaultMaxConcurrency int `yaml:"default_max_concurrency" json:"default_max_concurrency"`
+	// MaxQueued 每个消费者在内存中为各租户排队的消息总数上限，达到上限后暂停拉取，默认 256
+	MaxQueued int `yaml:"max_queued" json:"max_queued"`
+	// TenantMaxQueued 未单独配置的租户在内存中排队的消息数上限，默认 MaxQueued 的四分之一；
+	// 超出的消息不确认，留在消息队列中稍后重新投递，不阻塞其他租户的拉取
+	TenantMaxQueued int `yaml:"tenant_max_queued" json:"tenant_max_queued"`
+	// Tenants 租户 ID -> 调度配置
+	Tenants map[string]TenantLimit `yaml:"tenants" json:"tenants"`
+}
+
+// TenantLimit 单个租户的调度配置，字段为 0 时使用默认值
+type TenantLimit struct {
+	// Weight 权重，每轮调度最多连续处理 Weight 条该租户的消息
+	Weight int `yaml:"weight" json:"weight"`
+	// MaxConcurrency 同时处理的最大消息数
+	MaxConcurrency int `yaml:"max_concurrency" json:"max_concurrency"`
+	// MaxQueued 在内存中排队的消息数上限
+	MaxQueued int `yaml:"max_queued" json:"max_queued"`
+}
+
+func (p *FairPolicy) limit(tenant string) (weight, maxConcurrency int) {
+	weight, maxConcurrency = p.DefaultWeight, p.DefaultMaxConcurrency
+	if l, ok := p.Tenants[tenant]; ok {
+		if l.Weight > 0 {
+			weight = l.Weight
+		}
+		if l.MaxConcurrency > 0 {
+			maxConcurrency = l.MaxConcurrency
+		}
+	}
+	if weight <= 0 {
+		weight = 1
+	}
+	return weight, maxConcurrency
+}
+
+func (p *FairPolicy) maxQueued(tenant string) int {
+	if l, ok := p.Tenants[tenant]; ok && l.MaxQueued > 0 {
+		return l.MaxQueued
+	}
+	return p.TenantMaxQueued
+}
+
+func (p *FairPolicy) normalize() *FairPolicy {
+	out := FairPolicy{}
+	if p != nil {
+		out = *p
+	}
+	if out.DefaultWeight <= 0 {
+		out.DefaultWeight = 1
+	}
+	if out.MaxQueued <= 0 {
+		out.MaxQueued = 256
+	}
+	if out.TenantMaxQueued <= 0 {
+		out.TenantMaxQueued = max(out.MaxQueued/4, 1)
+	}
+	return &out
+}
+
+// FairOptions 租户公平调度配置
+type FairOptions struct {
+	// Name 指标中的 queue 标签，用于区分多个消费者，默认 default
+	Name string
+	// TenantOf 从消息中取租户 ID，默认读取 ctxx 租户消息头，没有时读取信封的 tenant_id
+	TenantOf func(msg *Message) string
+	// Registerer 注册指标的 Prometheus 注册表，默认 monitor.Reg；两者都为空时不记录指标
+	Registerer prometheus.Registerer
+}
+
+// FairScheduler 按租户划分子队列，以加权差额轮询（DRR）在租户之间分配消费者的处理协程，
+// 并限制每个租户同时处理的消息数，避免单个租户的消息洪峰饿死其他租户。
+// 设置到 ConsumerOptions.Fair 后生效，策略可通过 Update 热更新
+type FairScheduler struct {
+	policy   atomic.Pointer[FairPolicy]
+	name     string
+	tenantOf func(msg *Message) string
+	metrics  *fairMetrics
+
+	mu     sync.Mutex
+	queues map[*fairQueue]struct{}
+}
+
+// NewFairScheduler 创建租户公平调度器，policy 为空时所有租户权重相同且不限制并发
+func NewFairScheduler(policy *FairPolicy, opts *FairOptions) (*FairScheduler, error) {
+	s := &FairScheduler{queues: make(map[*fairQueue]struct{})}
+	if opts == nil {
+		opts = &FairOptions{}
+	}
+	s.name = opts.Name
+	if s.name == "" {
+		s.name = "default"
+	}
+	s.tenantOf = opts.TenantOf
+	if s.tenantOf == nil {
+		s.tenantOf = TenantOf
+	}
+	reg := opts.Registerer
+	if reg == nil && monitor.Reg != nil {
+		reg = monitor.Reg
+	}
+	if reg != nil {
+		m, err := newFairMetrics(reg)
+		if err != nil {
+			return nil, err
+		}
+		s.metrics = m
+	}
+	s.policy.Store(policy.normalize())
+	return s, nil
+}
+
+// NewWatchedFairScheduler 从配置中心读取 mqfair 策略创建调度器，并在配置变化时热更新
+func NewWatchedFairScheduler(f *kvconfig.ConfigFactory, group string, opts *FairOptions) (*FairScheduler, error) {
+	p, err := kvconfig.GetYamlConfig[FairPolicy](f, kvconfig.MqFairDataId, group)
+	if err != nil {
+		return nil, fmt.Errorf("读取租户调度策略失败: %w", err)
+	}
+	s, err := NewFairScheduler(p, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = kvconfig.WatchYamlConfig(f, kvconfig.MqFairDataId, group, func(p *FairPolicy) {
+		s.Update(p)
+		hlog.Infof("租户调度策略已更新 [group: %s]", group)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("监听租户调度策略失败: %w", err)
+	}
+	return s, nil
+}
+
+// Update 替换调度策略，正在运行的消费者立即按新策略调度
+func (s *FairScheduler) Update(p *FairPolicy) {
+	s.policy.Store(p.normalize())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for q := range s.queues {
+		q.wake()
+	}
+}
+
+// TenantOf 默认的租户提取：ctxx 租户消息头，没有时读取信封的 tenant_id
+func TenantOf(msg *Message) string {
+	if t := msg.Headers[ctxx.HeaderTenantID]; t != "" {
+		return t
+	}
+	var env struct {
+		TenantID string `json:"tenant_id"`
+	}
+	if json.Unmarshal(msg.Body, &env) == nil {
+		return env.TenantID
+	}
+	return ""
+}
+
+// run 启动 n 个协程按租户公平地处理 msgs 中的消息，msgs 关闭且排队的消息处理完后返回；
+// 租户排队数达到上限时把消息交给 requeue，由消息队列稍后重新投递
+func (s *FairScheduler) run(n int, msgs <-chan *Message, fn func(msg *Message), requeue func(msg *Message)) {
+	q := newFairQueue(s)
+	s.mu.Lock()
+	s.queues[q] = struct{}{}
+	s.mu.Unlock()
+	s.metrics.watch(q)
+	defer func() {
+		s.metrics.unwatch(q)
+		s.mu.Lock()
+		delete(s.queues, q)
+		s.mu.Unlock()
+	}()
+
+	go func() {
+		for msg := range msgs {
+			if !q.push(msg) {
+				requeue(msg)
+			}
+		}
+		q.close()
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				t, msg := q.pop()
+				if msg == nil {
+					return
+				}
+				fn(msg)
+				q.done(t)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+type fairItem struct {
+	msg      *Message
+	enqueued time.Time
+}
+
+type tenantQueue struct {
+	id       string
+	items    []fairItem
+	inflight int
+	deficit  int
+}
+
+// fairQueue 一次 Consume 内的租户子队列
+type fairQueue struct {
+	s      *FairScheduler
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queued int
+	closed bool
+	// active 有排队或处理中消息的租户，按轮询顺序排列
+	active  []*tenantQueue
+	tenants map[string]*tenantQueue
+	cursor  int
+}
+
+func newFairQueue(s *FairScheduler) *fairQueue {
+	q := &fairQueue{s: s, tenants: make(map[string]*tenantQueue)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *fairQueue) wake() {
+	q.mu.Lock()
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+// push 把消息放入租户子队列，排队总数达到 MaxQueued 时阻塞，从而暂停从消息队列拉取；
+// 租户排队数达到上限时返回 false，不阻塞其他租户的消息
+func (q *fairQueue) push(msg *Message) bool {
+	id := q.s.tenantOf(msg)
+	if id == "" {
+		id = unknownTenant
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	p := q.s.policy.Load()
+	for q.queued >= p.MaxQueued {
+		q.cond.Wait()
+		p = q.s.policy.Load()
+	}
+	t, ok := q.tenants[id]
+	if ok && len(t.items) >= p.maxQueued(id) {
+		q.s.metrics.deferred(q.s.name, id)
+		return false
+	}
+	if !ok {
+		t = &tenantQueue{id: id}
+		q.tenants[id] = t
+		q.active = append(q.active, t)
+	}
+	t.items = append(t.items, fairItem{msg: msg, enqueued: time.Now()})
+	q.queued++
+	q.s.metrics.queued(q.s.name, id, len(t.items))
+	q.cond.Broadcast()
+	return true
+}
+
+func (q *fairQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+// pop 按 DRR 取下一条可以处理的消息，全部处理完且 msgs 已关闭时返回 nil
+func (q *fairQueue) pop() (*tenantQueue, *Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if t := q.next(q.s.policy.Load()); t != nil {
+			item := t.items[0]
+			t.items[0] = fairItem{}
+			t.items = t.items[1:]
+			t.inflight++
+			q.queued--
+			q.s.metrics.dequeued(q.s.name, t, item.enqueued)
+			q.cond.Broadcast()
+			return t, item.msg
+		}
+		if q.closed && q.queued == 0 {
+			return nil, nil
+		}
+		q.cond.Wait()
+	}
+}
+
+// next 从游标处开始轮询，选出有排队消息、未达到并发上限且差额足够的租户。
+// 游标每移到一个租户就为其增加 weight 的差额，差额用完后移到下一个租户
+func (q *fairQueue) next(p *FairPolicy) *tenantQueue {
+	n := len(q.active)
+	if n == 0 {
+		return nil
+	}
+	if q.cursor >= n {
+		q.cursor = 0
+	}
+	for i := 0; i <= 2*n; i++ {
+		t := q.active[q.cursor]
+		weight, maxConcurrency := p.limit(t.id)
+		if len(t.items) > 0 && (maxConcurrency <= 0 || t.inflight < maxConcurrency) {
+			if t.deficit <= 0 {
+				t.deficit += weight
+			}
+			if t.deficit > weight {
+				t.deficit = weight
+			}
+			t.deficit--
+			if t.deficit <= 0 {
+				q.cursor = (q.cursor + 1) % n
+			}
+			return t
+		}
+		// 没有消息或达到并发上限，轮到下一个租户并放弃剩余差额
+		t.deficit = 0
+		q.cursor = (q.cursor + 1) % n
+	}
+	return nil
+}
+
+// done 消息处理完成，租户没有排队与处理中的消息时移出轮询列表
+func (q *fairQueue) done(t *tenantQueue) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t.inflight--
+	if t.inflight == 0 && len(t.items) == 0 {
+		delete(q.tenants, t.id)
+		q.s.metrics.idle(q.s.name, t.id)
+		for i, at := range q.active {
+			if at == t {
+				q.active = append(q.active[:i], q.active[i+1:]...)
+				if q.cursor > i {
+					q.cursor--
+				}
+				break
+			}
+		}
+	}
+	q.cond.Broadcast()
+}
+
+type fairMetrics struct {
+	queuedGauge *prometheus.GaugeVec
+	lag         *lagCollector
+	wait        *prometheus.HistogramVec
+	deferrals   *prometheus.CounterVec
+}
+
+func newFairMetrics(reg prometheus.Registerer) (*fairMetrics, error) {
+	m := &fairMetrics{}
+	var err error
+	if m.queuedGauge, err = monitor.RegisterOrExisting(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hertzcommon_mq_tenant_queued",
+		Help: "租户子队列中等待处理的消息数",
+	}, []string{"queue", "tenant"})); err != nil {
+		return nil, err
+	}
+	if m.lag, err = monitor.RegisterOrExisting(reg, newLagCollector()); err != nil {
+		return nil, err
+	}
+	if m.wait, err = monitor.RegisterOrExisting(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hertzcommon_mq_tenant_wait_seconds",
+		Help:    "消息在租户子队列中的等待时间",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"queue", "tenant"})); err != nil {
+		return nil, err
+	}
+	if m.deferrals, err = monitor.RegisterOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hertzcommon_mq_tenant_deferred_total",
+		Help: "租户排队数达到上限后留给消息队列重新投递的消息数",
+	}, []string{"queue", "tenant"})); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *fairMetrics) queued(queue, tenant string, n int) {
+	if m == nil {
+		return
+	}
+	m.queuedGauge.WithLabelValues(queue, tenant).Set(float64(n))
+}
+
+func (m *fairMetrics) dequeued(queue string, t *tenantQueue, enqueued time.Time) {
+	if m == nil {
+		return
+	}
+	now := time.Now()
+	m.wait.WithLabelValues(queue, t.id).Observe(now.Sub(enqueued).Seconds())
+	m.queuedGauge.WithLabelValues(queue, t.id).Set(float64(len(t.items)))
+}
+
+func (m *fairMetrics) deferred(queue, tenant string) {
+	if m != nil {
+		m.deferrals.WithLabelValues(queue, tenant).Inc()
+	}
+}
+
+// idle 租户没有排队与处理中的消息，删除其排队数序列
+func (m *fairMetrics) idle(queue, tenant string) {
+	if m != nil {
+		m.queuedGauge.DeleteLabelValues(queue, tenant)
+	}
+}
+
+func (m *fairMetrics) watch(q *fairQueue) {
+	if m != nil {
+		m.lag.add(q)
+	}
+}
+
+func (m *fairMetrics) unwatch(q *fairQueue) {
+	if m != nil {
+		m.lag.remove(q)
+	}
+}
+
+// lagCollector 在采集时计算各租户子队列中最早一条消息已等待的时间，
+// 没有消息出队的租户（如达到并发上限）也能反映积压；同名的多个消费者取最大值
+type lagCollector struct {
+	desc   *prometheus.Desc
+	mu     sync.Mutex
+	queues map[*fairQueue]struct{}
+}
+
+func newLagCollector() *lagCollector {
+	return &lagCollector{
+		desc: prometheus.NewDesc("hertzcommon_mq_tenant_lag_seconds",
+			"租户子队列中最早一条消息已等待的时间，采集时计算", []string{"queue", "tenant"}, nil),
+		queues: make(map[*fairQueue]struct{}),
+	}
+}
+
+func (c *lagCollector) add(q *fairQueue) {
+	c.mu.Lock()
+	c.queues[q] = struct{}{}
+	c.mu.Unlock()
+}
+
+func (c *lagCollector) remove(q *fairQueue) {
+	c.mu.Lock()
+	delete(c.queues, q)
+	c.mu.Unlock()
+}
+
+func (c *lagCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *lagCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	queues := make([]*fairQueue, 0, len(c.queues))
+	for q := range c.queues {
+		queues = append(queues, q)
+	}
+	c.mu.Unlock()
+
+	now := time.Now()
+	lags := make(map[[2]string]float64)
+	for _, q := range queues {
+		q.mu.Lock()
+		for id, t := range q.tenants {
+			key := [2]string{q.s.name, id}
+			lag := 0.0
+			if len(t.items) > 0 {
+				lag = now.Sub(t.items[0].enqueued).Seconds()
+			}
+			if old, ok := lags[key]; !ok || lag > old {
+				lags[key] = lag
+			}
+		}
+		q.mu.Unlock()
+	}
+	for key, lag := range lags {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, lag, key[0], key[1])
+	}
+}
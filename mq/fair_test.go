@@ -0,0 +1,261 @@
+package mq
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func tenantMsg(tenant string) *Message {
+	return &Message{Key: tenant, Headers: map[string]string{ctxx.HeaderTenantID: tenant}}
+}
+
+// popOrder 依次出队 n 条消息并立即完成处理，返回租户顺序
+func popOrder(q *fairQueue, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		t, msg := q.pop()
+		b.WriteString(msg.Key)
+		q.done(t)
+	}
+	return b.String()
+}
+
+func TestFairQueueWeights(t *testing.T) {
+	s, err := NewFairScheduler(&FairPolicy{Tenants: map[string]TenantLimit{"A": {Weight: 3}}}, &FairOptions{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := newFairQueue(s)
+	for i := 0; i < 10; i++ {
+		q.push(tenantMsg("A"))
+	}
+	for i := 0; i < 10; i++ {
+		q.push(tenantMsg("B"))
+	}
+	q.push(tenantMsg("C"))
+
+	if got := popOrder(q, 11); got != "AAABCAAABAA" {
+		t.Errorf("调度顺序 = %s, want AAABCAAABAA", got)
+	}
+
+	// 热更新后 B 的权重更高
+	s.Update(&FairPolicy{Tenants: map[string]TenantLimit{"B": {Weight: 2}}})
+	if got := popOrder(q, 6); got != "ABBABB" {
+		t.Errorf("更新后调度顺序 = %s, want ABBABB", got)
+	}
+}
+
+func TestFairQueueConcurrencyCap(t *testing.T) {
+	s, err := NewFairScheduler(&FairPolicy{DefaultMaxConcurrency: 2, Tenants: map[string]TenantLimit{"A": {MaxConcurrency: 1}}}, &FairOptions{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := newFairQueue(s)
+	for i := 0; i < 3; i++ {
+		q.push(tenantMsg("A"))
+		q.push(tenantMsg("B"))
+	}
+
+	ta, _ := q.pop()
+	tb1, _ := q.pop()
+	tb2, _ := q.pop()
+	if ta.id != "A" || tb1.id != "B" || tb2.id != "B" {
+		t.Fatalf("出队 = %s %s %s", ta.id, tb1.id, tb2.id)
+	}
+	// A 与 B 都达到并发上限
+	q.mu.Lock()
+	next := q.next(s.policy.Load())
+	q.mu.Unlock()
+	if next != nil {
+		t.Fatalf("达到并发上限时不应出队, got %s", next.id)
+	}
+	q.done(tb1)
+	if got, _ := q.pop(); got.id != "B" {
+		t.Errorf("B 完成一条后应继续出队 B, got %s", got.id)
+	}
+	q.done(ta)
+	if got, _ := q.pop(); got.id != "A" {
+		t.Errorf("A 完成后应出队 A, got %s", got.id)
+	}
+}
+
+func TestFairConsumerNoisyTenant(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s, err := NewFairScheduler(&FairPolicy{DefaultMaxConcurrency: 1, MaxQueued: 500}, &FairOptions{Name: "activity", Registerer: reg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewMemoryBroker()
+	defer b.Close()
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		if err := b.Publish(ctxx.WithTenantID(ctx, "noisy"), "activity", &Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 只在信封中携带租户的消息
+	for i := 0; i < 5; i++ {
+		msg, err := DefaultRegistry.NewMessage(ctxx.WithTenantID(ctx, "quiet"), "", &hdmodel.ActivityMqDto{ActivityId: "a1", TenantId: "quiet"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		delete(msg.Headers, ctxx.HeaderTenantID)
+		if err := b.Publish(ctx, "activity", msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var order []string
+	inflight := map[string]int{}
+	maxInflight := map[string]int{}
+	c := b.Consumer(&ConsumerOptions{Concurrency: 4, Fair: s})
+	runUntil(t, c, "activity", func(ctx context.Context, msg *Message) error {
+		tenant := TenantOf(msg)
+		mu.Lock()
+		inflight[tenant]++
+		if inflight[tenant] > maxInflight[tenant] {
+			maxInflight[tenant] = inflight[tenant]
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		inflight[tenant]--
+		order = append(order, tenant)
+		mu.Unlock()
+		return nil
+	}, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(order) == 105
+	})
+
+	last := 0
+	for i, tenant := range order {
+		if tenant == "quiet" {
+			last = i
+		}
+	}
+	// 不公平调度时 quiet 要排在 100 条 noisy 之后
+	if last > 30 {
+		t.Errorf("quiet 的最后一条消息排在第 %d 位: %v", last, order)
+	}
+	if maxInflight["noisy"] != 1 || maxInflight["quiet"] != 1 {
+		t.Errorf("并发上限未生效: %v", maxInflight)
+	}
+	if n := testutil.CollectAndCount(reg, "hertzcommon_mq_tenant_wait_seconds"); n != 2 {
+		t.Errorf("wait 指标序列数 = %d, want 2", n)
+	}
+	if got := testutil.ToFloat64(s.metrics.queuedGauge.WithLabelValues("activity", "noisy")); got != 0 {
+		t.Errorf("noisy queued = %v, want 0", got)
+	}
+}
+
+func TestFairLagWithoutDequeue(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s, err := NewFairScheduler(&FairPolicy{Tenants: map[string]TenantLimit{"A": {MaxConcurrency: 1}}}, &FairOptions{Name: "orders", Registerer: reg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := newFairQueue(s)
+	s.metrics.watch(q)
+	defer s.metrics.unwatch(q)
+
+	q.push(tenantMsg("A"))
+	q.push(tenantMsg("A"))
+	first, _ := q.pop()
+	// A 达到并发上限，剩下的消息不会出队，积压时间仍随采集增长
+	time.Sleep(20 * time.Millisecond)
+	lag := func() float64 {
+		mfs, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mf := range mfs {
+			if mf.GetName() == "hertzcommon_mq_tenant_lag_seconds" {
+				return mf.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+		return -1
+	}
+	if got := lag(); got < 0.02 {
+		t.Errorf("lag = %v, want >= 0.02", got)
+	}
+
+	q.done(first)
+	second, _ := q.pop()
+	if got := lag(); got != 0 {
+		t.Errorf("没有排队消息时 lag = %v, want 0", got)
+	}
+	q.done(second)
+	if n := testutil.CollectAndCount(reg, "hertzcommon_mq_tenant_lag_seconds"); n != 0 {
+		t.Errorf("租户处理完后 lag 序列数 = %d, want 0", n)
+	}
+}
+
+func TestFairConsumerTenantQueueCap(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	// 排队总数远小于 noisy 的积压，noisy 超出租户上限的消息退回消息队列，不阻塞 quiet 的拉取
+	s, err := NewFairScheduler(&FairPolicy{DefaultMaxConcurrency: 1, MaxQueued: 20}, &FairOptions{Name: "activity", Registerer: reg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewMemoryBroker()
+	defer b.Close()
+	ctx := context.Background()
+	for i := 0; i < 200; i++ {
+		if err := b.Publish(ctxx.WithTenantID(ctx, "noisy"), "activity", &Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if err := b.Publish(ctxx.WithTenantID(ctx, "quiet"), "activity", &Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var order []string
+	seen := map[string]bool{}
+	c := b.Consumer(&ConsumerOptions{Concurrency: 4, Fair: s})
+	runUntil(t, c, "activity", func(ctx context.Context, msg *Message) error {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		if seen[msg.ID] {
+			t.Errorf("消息 %s 重复处理", msg.ID)
+		}
+		seen[msg.ID] = true
+		order = append(order, TenantOf(msg))
+		return nil
+	}, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(order) == 205
+	})
+
+	first := -1
+	for i, tenant := range order {
+		if tenant == "quiet" {
+			first = i
+			break
+		}
+	}
+	if first < 0 || first > 20 {
+		t.Errorf("quiet 的第一条消息排在第 %d 位", first)
+	}
+	if got := testutil.ToFloat64(s.metrics.deferrals.WithLabelValues("activity", "noisy")); got == 0 {
+		t.Error("noisy 超出上限的消息应退回消息队列")
+	}
+	if n := testutil.CollectAndCount(reg, "hertzcommon_mq_tenant_queued"); n != 0 {
+		t.Errorf("处理完后 queued 序列数 = %d, want 0", n)
+	}
+}
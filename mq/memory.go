@@ -0,0 +1,159 @@
+package mq
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// memoryRequeueDelay 公平调度退回的消息重新投递前的等待时间
+const memoryRequeueDelay = 10 * time.Millisecond
+
+// MemoryBroker 进程内消息队列，用于测试与本地开发。
+// 每个 topic 保存全部消息，每个消费组从头开始各自消费一遍
+type MemoryBroker struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	topics map[string]*memoryTopic
+	seq    int64
+	closed *closeSignal
+}
+
+type memoryTopic struct {
+	log     []*Message
+	offsets map[string]int
+	// redeliver 各消费组等待重新投递的消息，优先于 log 中的新消息
+	redeliver map[string][]*Message
+}
+
+// NewMemoryBroker 创建进程内消息队列
+func NewMemoryBroker() *MemoryBroker {
+	b := &MemoryBroker{topics: make(map[string]*memoryTopic), closed: newCloseSignal()}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Publish 实现 Producer
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, msg *Message) error {
+	return publish(ctx, "memory", topic, nil, msg, func(ctx context.Context) error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.seq++
+		msg.ID = strconv.FormatInt(b.seq, 10)
+		t := b.topic(topic)
+		t.log = append(t.log, cloneMessage(msg))
+		b.cond.Broadcast()
+		return nil
+	})
+}
+
+// Consumer 创建消费者
+func (b *MemoryBroker) Consumer(opts *ConsumerOptions) Consumer {
+	return &memoryConsumer{broker: b, opts: opts.withDefaults(), closed: newCloseSignal()}
+}
+
+// Messages 返回 topic 中的全部消息，便于测试断言
+func (b *MemoryBroker) Messages(topic string) []*Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topic(topic)
+	out := make([]*Message, 0, len(t.log))
+	for _, msg := range t.log {
+		out = append(out, cloneMessage(msg))
+	}
+	return out
+}
+
+// Close 关闭消息队列，所有消费者的 Consume 随之返回
+func (b *MemoryBroker) Close() error {
+	b.closed.close()
+	b.mu.Lock()
+	b.cond.Broadcast()
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *MemoryBroker) topic(name string) *memoryTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memoryTopic{offsets: make(map[string]int), redeliver: make(map[string][]*Message)}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// next 阻塞获取消费组的下一条消息，ctx 结束时返回 nil
+func (b *MemoryBroker) next(ctx context.Context, topic, group string) *Message {
+	stop := context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		b.cond.Broadcast()
+		b.mu.Unlock()
+	})
+	defer stop()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ctx.Err() == nil {
+		t := b.topic(topic)
+		if pending := t.redeliver[group]; len(pending) > 0 {
+			t.redeliver[group] = pending[1:]
+			return pending[0]
+		}
+		if off := t.offsets[group]; off < len(t.log) {
+			t.offsets[group] = off + 1
+			return cloneMessage(t.log[off])
+		}
+		b.cond.Wait()
+	}
+	return nil
+}
+
+// requeue 延迟 memoryRequeueDelay 后把消息重新投递给消费组
+func (b *MemoryBroker) requeue(topic, group string, msg *Message) {
+	time.AfterFunc(memoryRequeueDelay, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		t := b.topic(topic)
+		t.redeliver[group] = append(t.redeliver[group], msg)
+		b.cond.Broadcast()
+	})
+}
+
+type memoryConsumer struct {
+	broker *MemoryBroker
+	opts   *ConsumerOptions
+	closed *closeSignal
+}
+
+func (c *memoryConsumer) Consume(ctx context.Context, topic string, h Handler) error {
+	ctx, cancel := c.closed.bind(ctx)
+	defer cancel()
+	ctx, cancelBroker := c.broker.closed.bind(ctx)
+	defer cancelBroker()
+
+	msgs := make(chan *Message)
+	go func() {
+		defer close(msgs)
+		for {
+			msg := c.broker.next(ctx, topic, c.opts.Group)
+			if msg == nil {
+				return
+			}
+			msgs <- msg
+		}
+	}()
+	// 已取出的消息在退出前处理完
+	handleCtx := context.WithoutCancel(ctx)
+	dispatch(c.opts, msgs, func(msg *Message) {
+		deliver(ctx, handleCtx, "memory", c.opts, msg, h)
+	}, func(msg *Message) {
+		c.broker.requeue(topic, c.opts.Group, msg)
+	})
+	return nil
+}
+
+func (c *memoryConsumer) Close() error {
+	c.closed.close()
+	return nil
+}
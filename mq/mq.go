@@ -0,0 +1,233 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/grayscalecloud/hertzcommon/mq"
+
+// Message 消息
+type Message struct {
+	// ID 由消息队列分配，发送时无需设置
+	ID    string
+	Topic string
+	// Key 业务键，如订单号，便于排查
+	Key string
+	// Headers 消息头，发送时会写入 ctxx 字段与 W3C 链路上下文
+	Headers map[string]string
+	Body    []byte
+}
+
+// Handler 消息处理函数，ctx 中已恢复生产者的 ctxx 字段与链路上下文
+type Handler func(ctx context.Context, msg *Message) error
+
+// Producer 消息生产者
+type Producer interface {
+	// Publish 发送消息到 topic，msg.Headers 中会写入当前 ctx 的 ctxx 字段与链路上下文
+	Publish(ctx context.Context, topic string, msg *Message) error
+	Close() error
+}
+
+// Consumer 消息消费者
+type Consumer interface {
+	// Consume 订阅 topic 并阻塞处理消息，直到 ctx 取消或 Close 后返回
+	Consume(ctx context.Context, topic string, h Handler) error
+	Close() error
+}
+
+// ConsumerOptions 消费者配置
+type ConsumerOptions struct {
+	// Group 消费组，同组的消费者分摊消息，不同组各自收到全部消息
+	Group string
+	// Concurrency 并发处理的协程数，默认 1
+	Concurrency int
+	// Propagator 链路上下文传播器，默认 W3C Trace Context 与 Baggage
+	Propagator propagation.TextMapPropagator
+	// Retry 处理失败时的重试策略，为空时不重试；返回 Permanent 包装的错误时不再重试
+	Retry *RetryPolicy
+	// DeadLetter 重试耗尽或永久失败的消息发送到的生产者，为空时记录日志后丢弃
+	DeadLetter Producer
+	// DeadLetterSuffix 死信 topic 后缀，默认 ".dlq"
+	DeadLetterSuffix string
+	// Fair 按租户公平调度处理协程，为空时按拉取顺序处理。租户排队数达到上限的消息不确认：
+	// MemoryBroker 稍后重新投递，Redis Streams 留在待确认列表中，需要定时调用 ClaimIdle 重新处理
+	Fair *FairScheduler
+}
+
+func (o *ConsumerOptions) withDefaults() *ConsumerOptions {
+	opts := ConsumerOptions{}
+	if o != nil {
+		opts = *o
+	}
+	if opts.Group == "" {
+		opts.Group = "default"
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Propagator == nil {
+		opts.Propagator = defaultPropagator()
+	}
+	return &opts
+}
+
+func defaultPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// Inject 把 ctx 中的 ctxx 字段（按传递策略）与链路上下文写入消息头，供自定义的 Producer 实现调用
+func Inject(ctx context.Context, propagator propagation.TextMapPropagator, msg *Message) {
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]string)
+	}
+	ctxx.InjectHeaders(ctx, func(key, value string) {
+		if _, ok := msg.Headers[key]; !ok {
+			msg.Headers[key] = value
+		}
+	})
+	if propagator == nil {
+		propagator = defaultPropagator()
+	}
+	propagator.Inject(ctx, propagation.MapCarrier(msg.Headers))
+}
+
+// Extract 从消息头恢复 ctxx 字段与链路上下文，供自定义的 Consumer 实现调用
+func Extract(ctx context.Context, propagator propagation.TextMapPropagator, msg *Message) context.Context {
+	if propagator == nil {
+		propagator = defaultPropagator()
+	}
+	ctx = propagator.Extract(ctx, propagation.MapCarrier(msg.Headers))
+	return ctxx.ExtractHeaders(ctx, func(key string) string {
+		return msg.Headers[key]
+	})
+}
+
+// publish 创建生产者 Span 并写入消息头后调用 send 发送
+func publish(ctx context.Context, system, topic string, propagator propagation.TextMapPropagator, msg *Message,
+	send func(ctx context.Context) error) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", system),
+			attribute.String("messaging.destination.name", topic),
+			attribute.String("messaging.operation", "publish"),
+		))
+	defer span.End()
+
+	msg.Topic = topic
+	Inject(ctx, propagator, msg)
+	if err := send(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("发送消息失败 [topic: %s]: %w", topic, err)
+	}
+	span.SetAttributes(attribute.String("messaging.message.id", msg.ID))
+	return nil
+}
+
+// process 恢复上下文并在消费者 Span 中调用处理函数，panic 会被转换为错误
+func process(ctx context.Context, system string, opts *ConsumerOptions, msg *Message, h Handler) (err error) {
+	ctx = Extract(ctx, opts.Propagator, msg)
+	ctx, span := otel.Tracer(tracerName).Start(ctx, msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", system),
+			attribute.String("messaging.destination.name", msg.Topic),
+			attribute.String("messaging.operation", "process"),
+			attribute.String("messaging.consumer.group.name", opts.Group),
+			attribute.String("messaging.message.id", msg.ID),
+		),
+		trace.WithAttributes(ctxx.SpanAttributes(ctx)...))
+	defer span.End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("处理消息 panic: %v", r)
+			hlog.CtxErrorf(ctx, "处理消息 panic [topic: %s, id: %s]: %v\n%s", msg.Topic, msg.ID, r, debug.Stack())
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}()
+	return h(ctx, msg)
+}
+
+// dispatch 按消费者配置处理 msgs 中的消息，配置了 Fair 时按租户公平调度，
+// 租户排队数达到上限的消息交给 requeue 重新投递
+func dispatch(opts *ConsumerOptions, msgs <-chan *Message, fn func(msg *Message), requeue func(msg *Message)) {
+	if opts.Fair != nil {
+		opts.Fair.run(opts.Concurrency, msgs, fn, requeue)
+		return
+	}
+	runWorkers(opts.Concurrency, msgs, fn)
+}
+
+// runWorkers 启动 n 个协程处理 msgs 中的消息，msgs 关闭后等待全部处理完成
+func runWorkers(n int, msgs <-chan *Message, fn func(msg *Message)) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for msg := range msgs {
+				fn(msg)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// cloneMessage 复制消息，避免生产者与消费者共享 Headers 与 Body
+func cloneMessage(msg *Message) *Message {
+	out := *msg
+	out.Headers = make(map[string]string, len(msg.Headers))
+	for k, v := range msg.Headers {
+		out.Headers[k] = v
+	}
+	out.Body = append([]byte(nil), msg.Body...)
+	return &out
+}
+
+// closeSignal Close 后取消所有正在进行的 Consume
+type closeSignal struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func newCloseSignal() *closeSignal {
+	return &closeSignal{ch: make(chan struct{})}
+}
+
+func (s *closeSignal) close() {
+	s.once.Do(func() { close(s.ch) })
+}
+
+// bind 返回在 Close 或 ctx 取消时结束的 context
+func (s *closeSignal) bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-s.ch:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// logFailure 记录处理失败的消息
+func logFailure(ctx context.Context, group string, msg *Message, err error) {
+	hlog.CtxErrorf(ctx, "处理消息失败 [topic: %s, group: %s, id: %s, key: %s]: %v", msg.Topic, group, msg.ID, msg.Key, err)
+}
@@ -0,0 +1,207 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/grayscalecloud/hertzcommon/pkg/redisx"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// received 处理函数看到的上下文
+type received struct {
+	body     string
+	tenantID string
+	userID   string
+	traceID  trace.TraceID
+}
+
+func setupTracer(t *testing.T) (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return tp, recorder
+}
+
+// consumeN 启动消费者，收到 n 条消息后停止
+func consumeN(t *testing.T, c Consumer, topic string, n int, h Handler) []received {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var out []received
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Consume(ctx, topic, func(ctx context.Context, msg *Message) error {
+			err := h(ctx, msg)
+			mu.Lock()
+			out = append(out, received{
+				body:     string(msg.Body),
+				tenantID: ctxx.GetTenantID(ctx),
+				userID:   ctxx.GetUserID(ctx),
+				traceID:  trace.SpanContextFromContext(ctx).TraceID(),
+			})
+			if len(out) == n {
+				cancel()
+			}
+			mu.Unlock()
+			return err
+		})
+	}()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		t.Fatalf("只收到 %d 条消息, want %d", len(out), n)
+	}
+	return out
+}
+
+func testRoundTrip(t *testing.T, p Producer, newConsumer func(group string) Consumer) {
+	tp, recorder := setupTracer(t)
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "handler")
+	ctx = ctxx.WithTenantID(ctx, "t1")
+	ctx = ctxx.WithUserID(ctx, "u1")
+	for _, body := range []string{"a", "b"} {
+		if err := p.Publish(ctx, "avatar", &Message{Key: "user-1", Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	span.End()
+
+	// 不同消费组各自收到全部消息
+	for _, group := range []string{"g1", "g2"} {
+		got := consumeN(t, newConsumer(group), "avatar", 2, func(ctx context.Context, msg *Message) error { return nil })
+		for _, r := range got {
+			if r.tenantID != "t1" || r.userID != "u1" {
+				t.Errorf("%s: ctxx 未恢复: %+v", group, r)
+			}
+			if r.traceID != span.SpanContext().TraceID() {
+				t.Errorf("%s: 链路未延续: %s", group, r.traceID)
+			}
+		}
+		if len(got) != 2 {
+			t.Errorf("%s: 收到 %d 条", group, len(got))
+		}
+	}
+
+	var producers, consumers int
+	for _, s := range recorder.Ended() {
+		switch s.SpanKind() {
+		case trace.SpanKindProducer:
+			producers++
+		case trace.SpanKindConsumer:
+			consumers++
+			if s.Parent().TraceID() != span.SpanContext().TraceID() {
+				t.Error("消费者 Span 应属于生产者的链路")
+			}
+		}
+	}
+	if producers != 2 || consumers != 4 {
+		t.Errorf("producer spans = %d, consumer spans = %d", producers, consumers)
+	}
+}
+
+func TestMemoryBroker(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	testRoundTrip(t, b, func(group string) Consumer {
+		return b.Consumer(&ConsumerOptions{Group: group})
+	})
+}
+
+func TestRedisStreams(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redisx.NewClient(&hdmodel.Redis{Address: mr.Addr()})
+	defer client.Close()
+
+	testRoundTrip(t, NewRedisProducer(client, nil), func(group string) Consumer {
+		return NewRedisConsumer(client, &RedisConsumerOptions{
+			ConsumerOptions: ConsumerOptions{Group: group},
+			Block:           50 * time.Millisecond,
+		})
+	})
+
+	// 处理完成的消息已确认
+	pending, err := client.XPending(context.Background(), defaultRedisPrefix+"avatar", "g1").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("待确认消息 = %d, want 0", pending.Count)
+	}
+}
+
+func TestConsumerConcurrencyAndPanic(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	ctx := context.Background()
+	for i := 0; i < 8; i++ {
+		if err := b.Publish(ctx, "jobs", &Message{Body: []byte("job")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var running, peak int32
+	var calls int32
+	// panic 的那条消息不会进入 consumeN 的记录，其余照常处理
+	got := consumeN(t, b.Consumer(&ConsumerOptions{Concurrency: 4}), "jobs", 7, func(ctx context.Context, msg *Message) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			panic("boom")
+		case 2:
+			return errors.New("处理失败")
+		}
+		return nil
+	})
+	if n := atomic.LoadInt32(&calls); n != 8 || len(got) != 7 {
+		t.Errorf("calls = %d, recorded = %d", n, len(got))
+	}
+	if peak < 2 {
+		t.Errorf("并发峰值 = %d, 应并发处理", peak)
+	}
+}
+
+func TestConsumerClose(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	c := b.Consumer(nil)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Consume(context.Background(), "idle", func(ctx context.Context, msg *Message) error { return nil })
+	}()
+	time.Sleep(20 * time.Millisecond)
+	c.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Consume err = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close 后 Consume 未返回")
+	}
+}
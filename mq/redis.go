@@ -0,0 +1,265 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	defaultRedisPrefix = "hc:mq:"
+
+	fieldKey     = "key"
+	fieldBody    = "body"
+	headerPrefix = "h:"
+)
+
+// RedisOptions Redis Streams 生产者配置
+type RedisOptions struct {
+	// Prefix stream 键前缀，默认 "hc:mq:"，stream 键为 Prefix + topic
+	Prefix string
+	// MaxLen stream 的近似最大长度，超出后裁剪最早的消息，0 表示不限制
+	MaxLen int64
+	// Propagator 链路上下文传播器，默认 W3C Trace Context 与 Baggage
+	Propagator propagation.TextMapPropagator
+}
+
+// RedisProducer 基于 Redis Streams 的生产者
+type RedisProducer struct {
+	client redis.UniversalClient
+	opts   RedisOptions
+}
+
+// NewRedisProducer 创建 Redis Streams 生产者，client 由调用方负责关闭
+func NewRedisProducer(client redis.UniversalClient, opts *RedisOptions) *RedisProducer {
+	p := &RedisProducer{client: client}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Prefix == "" {
+		p.opts.Prefix = defaultRedisPrefix
+	}
+	return p
+}
+
+// Publish 实现 Producer
+func (p *RedisProducer) Publish(ctx context.Context, topic string, msg *Message) error {
+	return publish(ctx, "redis", topic, p.opts.Propagator, msg, func(ctx context.Context) error {
+		values := make(map[string]interface{}, len(msg.Headers)+2)
+		values[fieldKey] = msg.Key
+		values[fieldBody] = msg.Body
+		for k, v := range msg.Headers {
+			values[headerPrefix+k] = v
+		}
+		id, err := p.client.XAdd(ctx, &redis.XAddArgs{
+			Stream: p.opts.Prefix + topic,
+			MaxLen: p.opts.MaxLen,
+			Approx: p.opts.MaxLen > 0,
+			Values: values,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		msg.ID = id
+		return nil
+	})
+}
+
+// Close 实现 Producer
+func (p *RedisProducer) Close() error {
+	return nil
+}
+
+// RedisConsumerOptions Redis Streams 消费者配置
+type RedisConsumerOptions struct {
+	ConsumerOptions
+	// Prefix stream 键前缀，默认 "hc:mq:"，需与生产者一致
+	Prefix string
+	// Consumer 消费组内的消费者名称，默认 主机名-进程号
+	Consumer string
+	// Block 每次拉取的最长阻塞时间，默认 2 秒
+	Block time.Duration
+	// BatchSize 每次拉取的最大条数，默认 10
+	BatchSize int64
+}
+
+// RedisConsumer 基于 Redis Streams 消费组的消费者，消息处理完成后 XACK
+type RedisConsumer struct {
+	client redis.UniversalClient
+	opts   RedisConsumerOptions
+	closed *closeSignal
+}
+
+// NewRedisConsumer 创建 Redis Streams 消费者，client 由调用方负责关闭
+func NewRedisConsumer(client redis.UniversalClient, opts *RedisConsumerOptions) *RedisConsumer {
+	c := &RedisConsumer{client: client, closed: newCloseSignal()}
+	if opts != nil {
+		c.opts = *opts
+	}
+	c.opts.ConsumerOptions = *c.opts.ConsumerOptions.withDefaults()
+	if c.opts.Prefix == "" {
+		c.opts.Prefix = defaultRedisPrefix
+	}
+	if c.opts.Consumer == "" {
+		host, _ := os.Hostname()
+		c.opts.Consumer = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if c.opts.Block <= 0 {
+		c.opts.Block = 2 * time.Second
+	}
+	if c.opts.BatchSize <= 0 {
+		c.opts.BatchSize = 10
+	}
+	return c
+}
+
+// Consume 实现 Consumer，消费组不存在时自动创建并从最早的消息开始消费
+func (c *RedisConsumer) Consume(ctx context.Context, topic string, h Handler) error {
+	stream, group := c.opts.Prefix+topic, c.opts.Group
+	err := c.client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("创建消费组失败 [stream: %s, group: %s]: %w", stream, group, err)
+	}
+
+	ctx, cancel := c.closed.bind(ctx)
+	defer cancel()
+
+	msgs := make(chan *Message)
+	go func() {
+		defer close(msgs)
+		for ctx.Err() == nil {
+			res, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    group,
+				Consumer: c.opts.Consumer,
+				Streams:  []string{stream, ">"},
+				Count:    c.opts.BatchSize,
+				Block:    c.opts.Block,
+			}).Result()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				hlog.CtxWarnf(ctx, "拉取消息失败 [stream: %s, group: %s]: %v", stream, group, err)
+				select {
+				case <-time.After(time.Second):
+				case <-ctx.Done():
+				}
+				continue
+			}
+			for _, s := range res {
+				for _, m := range s.Messages {
+					select {
+					case msgs <- decodeRedisMessage(topic, m):
+					case <-ctx.Done():
+						// 未处理的消息留在待确认列表中
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	// 已取出的消息在退出前处理完并确认
+	handleCtx := context.WithoutCancel(ctx)
+	dispatch(&c.opts.ConsumerOptions, msgs, func(msg *Message) {
+		if !deliver(ctx, handleCtx, "redis", &c.opts.ConsumerOptions, msg, h) {
+			// 留在待确认列表中，由 ClaimIdle 重新投递
+			return
+		}
+		if err := c.client.XAck(handleCtx, stream, group, msg.ID).Err(); err != nil {
+			hlog.CtxWarnf(handleCtx, "确认消息失败 [stream: %s, id: %s]: %v", stream, msg.ID, err)
+		}
+	}, func(msg *Message) {
+		// 租户排队已满，不确认，留在待确认列表中由 ClaimIdle 重新投递
+		hlog.CtxDebugf(handleCtx, "租户排队已满，稍后重新投递 [stream: %s, id: %s]", stream, msg.ID)
+	})
+	return nil
+}
+
+// ClaimIdle 把消费组中空闲超过 minIdle 的待确认消息（如消费者崩溃、死信发送失败）转移给当前消费者并重新处理，
+// 返回处理的条数；可由定时任务周期调用
+func (c *RedisConsumer) ClaimIdle(ctx context.Context, topic string, minIdle time.Duration, h Handler) (int, error) {
+	stream, group := c.opts.Prefix+topic, c.opts.Group
+	claimed, _, err := c.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: c.opts.Consumer,
+		MinIdle:  minIdle,
+		Start:    "0",
+		Count:    c.opts.BatchSize,
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("转移待确认消息失败 [stream: %s, group: %s]: %w", stream, group, err)
+	}
+	n := 0
+	for _, m := range claimed {
+		msg := decodeRedisMessage(topic, m)
+		if !deliver(ctx, ctx, "redis", &c.opts.ConsumerOptions, msg, h) {
+			continue
+		}
+		if err := c.client.XAck(ctx, stream, group, msg.ID).Err(); err != nil {
+			return n, fmt.Errorf("确认消息失败 [stream: %s, id: %s]: %w", stream, msg.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// RangeRedis 按写入顺序读取 stream 中最多 count 条消息（不经过消费组），用于查看与重放死信
+func RangeRedis(ctx context.Context, client redis.UniversalClient, prefix, topic string, count int64) ([]*Message, error) {
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+	res, err := client.XRangeN(ctx, prefix+topic, "-", "+", count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取消息失败 [stream: %s]: %w", prefix+topic, err)
+	}
+	out := make([]*Message, 0, len(res))
+	for _, m := range res {
+		out = append(out, decodeRedisMessage(topic, m))
+	}
+	return out, nil
+}
+
+// DeleteRedis 从 stream 中删除消息
+func DeleteRedis(ctx context.Context, client redis.UniversalClient, prefix, topic string, ids ...string) error {
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+	if err := client.XDel(ctx, prefix+topic, ids...).Err(); err != nil {
+		return fmt.Errorf("删除消息失败 [stream: %s]: %w", prefix+topic, err)
+	}
+	return nil
+}
+
+// Close 实现 Consumer，正在进行的 Consume 处理完已取出的消息后返回
+func (c *RedisConsumer) Close() error {
+	c.closed.close()
+	return nil
+}
+
+func decodeRedisMessage(topic string, m redis.XMessage) *Message {
+	msg := &Message{ID: m.ID, Topic: topic, Headers: make(map[string]string)}
+	for k, v := range m.Values {
+		s, _ := v.(string)
+		switch {
+		case k == fieldKey:
+			msg.Key = s
+		case k == fieldBody:
+			msg.Body = []byte(s)
+		case strings.HasPrefix(k, headerPrefix):
+			msg.Headers[k[len(headerPrefix):]] = s
+		}
+	}
+	return msg
+}
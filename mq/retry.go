@@ -0,0 +1,168 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 死信消息携带的失败元数据；HeaderDLQGroup 为处理失败的消费组，Replay 后保留在重放的消息上，其他消费组跳过该消息
+const (
+	HeaderDLQError         = "x-dlq-error"
+	HeaderDLQAttempts      = "x-dlq-attempts"
+	HeaderDLQTraceID       = "x-dlq-trace-id"
+	HeaderDLQOriginalTopic = "x-dlq-original-topic"
+	HeaderDLQOriginalID    = "x-dlq-original-id"
+	HeaderDLQGroup         = "x-dlq-group"
+	HeaderDLQFailedAt      = "x-dlq-failed-at"
+
+	// DefaultDeadLetterSuffix 默认死信 topic 后缀
+	DefaultDeadLetterSuffix = ".dlq"
+)
+
+// RetryPolicy 消费失败的重试策略，退避时间按指数增长：InitialBackoff * Multiplier^(n-1)，不超过 MaxBackoff
+type RetryPolicy struct {
+	// MaxAttempts 最多处理次数（含第一次），默认 3
+	MaxAttempts int
+	// InitialBackoff 第一次重试前的等待时间，默认 100 毫秒
+	InitialBackoff time.Duration
+	// MaxBackoff 最长等待时间，默认 30 秒
+	MaxBackoff time.Duration
+	// Multiplier 退避倍数，默认 2
+	Multiplier float64
+}
+
+func (p *RetryPolicy) withDefaults() RetryPolicy {
+	policy := RetryPolicy{MaxAttempts: 1}
+	if p == nil {
+		return policy
+	}
+	policy = *p
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = 100 * time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 30 * time.Second
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+	return policy
+}
+
+// backoff 第 attempt 次失败后的等待时间
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if d > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
+// permanentError 不应重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记错误不可重试（如消息格式错误），消息会直接进入死信队列
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断错误是否被标记为不可重试
+func IsPermanent(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// deliver 按重试策略处理消息，重试耗尽或永久失败时发送到死信队列。
+// 返回 false 表示消息既未成功处理也未进入死信队列（如退出时放弃重试、死信发送失败），不应确认
+func deliver(ctx, handleCtx context.Context, system string, opts *ConsumerOptions, msg *Message, h Handler) bool {
+	// 重放给其他消费组的死信，本组已经处理过
+	if group := msg.Headers[HeaderDLQGroup]; group != "" && group != opts.Group {
+		return true
+	}
+	policy := opts.Retry.withDefaults()
+	for attempt := 1; ; attempt++ {
+		err := process(handleCtx, system, opts, msg, h)
+		if err == nil {
+			return true
+		}
+		logFailure(handleCtx, opts.Group, msg, err)
+		if IsPermanent(err) || attempt >= policy.MaxAttempts {
+			return deadLetter(handleCtx, opts, msg, err, attempt)
+		}
+		select {
+		case <-time.After(policy.backoff(attempt)):
+		case <-ctx.Done():
+			hlog.CtxWarnf(handleCtx, "退出时放弃重试 [topic: %s, id: %s, attempts: %d]", msg.Topic, msg.ID, attempt)
+			return false
+		}
+	}
+}
+
+// deadLetter 把失败的消息连同失败元数据发送到死信 topic，未配置死信队列时丢弃
+func deadLetter(ctx context.Context, opts *ConsumerOptions, msg *Message, cause error, attempts int) bool {
+	if opts.DeadLetter == nil {
+		hlog.CtxErrorf(ctx, "丢弃处理失败的消息 [topic: %s, id: %s, attempts: %d]: %v", msg.Topic, msg.ID, attempts, cause)
+		return true
+	}
+	suffix := opts.DeadLetterSuffix
+	if suffix == "" {
+		suffix = DefaultDeadLetterSuffix
+	}
+
+	dlq := cloneMessage(msg)
+	dlq.ID = ""
+	dlq.Headers[HeaderDLQError] = cause.Error()
+	dlq.Headers[HeaderDLQAttempts] = strconv.Itoa(attempts)
+	dlq.Headers[HeaderDLQOriginalTopic] = msg.Topic
+	dlq.Headers[HeaderDLQOriginalID] = msg.ID
+	dlq.Headers[HeaderDLQGroup] = opts.Group
+	dlq.Headers[HeaderDLQFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+	ctx = Extract(ctx, opts.Propagator, msg)
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		dlq.Headers[HeaderDLQTraceID] = sc.TraceID().String()
+	}
+
+	if err := opts.DeadLetter.Publish(ctx, msg.Topic+suffix, dlq); err != nil {
+		hlog.CtxErrorf(ctx, "发送死信失败 [topic: %s, id: %s]: %v", msg.Topic, msg.ID, err)
+		return false
+	}
+	hlog.CtxWarnf(ctx, "消息已进入死信队列 [topic: %s, id: %s, dlq_id: %s, attempts: %d]", msg.Topic, msg.ID, dlq.ID, attempts)
+	return true
+}
+
+// Replay 把死信消息去掉失败元数据后重新发送到原 topic，沿用原消息的 ctxx 字段与链路。
+// 重放的消息保留 HeaderDLQGroup，只有处理失败的消费组会处理，订阅同一 topic 的其他消费组跳过
+func Replay(ctx context.Context, p Producer, dlq *Message) (*Message, error) {
+	topic := dlq.Headers[HeaderDLQOriginalTopic]
+	if topic == "" {
+		return nil, fmt.Errorf("重放死信失败 [id: %s]: 缺少原 topic", dlq.ID)
+	}
+	msg := cloneMessage(dlq)
+	msg.ID = ""
+	for _, k := range []string{HeaderDLQError, HeaderDLQAttempts, HeaderDLQTraceID, HeaderDLQOriginalTopic,
+		HeaderDLQOriginalID, HeaderDLQFailedAt} {
+		delete(msg.Headers, k)
+	}
+	if err := p.Publish(Extract(ctx, nil, dlq), topic, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
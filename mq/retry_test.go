@@ -0,0 +1,241 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/grayscalecloud/hertzcommon/pkg/redisx"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := (&RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}).withDefaults()
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if got := (*RetryPolicy)(nil).withDefaults().MaxAttempts; got != 1 {
+		t.Errorf("未配置重试时 MaxAttempts = %d, want 1", got)
+	}
+}
+
+// runUntil 后台消费，直到 cond 满足或超时
+func runUntil(t *testing.T, c Consumer, topic string, h Handler, cond func() bool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = c.Consume(ctx, topic, h)
+	}()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			cancel()
+			<-done
+			t.Fatal("等待超时")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestRetryThenSucceed(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	if err := b.Publish(context.Background(), "activity", &Message{Body: []byte("a1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int32
+	var succeeded atomic.Bool
+	c := b.Consumer(&ConsumerOptions{
+		Retry:      &RetryPolicy{MaxAttempts: 3, InitialBackoff: 5 * time.Millisecond},
+		DeadLetter: b,
+	})
+	runUntil(t, c, "activity", func(ctx context.Context, msg *Message) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("下游暂时不可用")
+		}
+		succeeded.Store(true)
+		return nil
+	}, succeeded.Load)
+
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+	if dlq := b.Messages("activity.dlq"); len(dlq) != 0 {
+		t.Errorf("成功处理的消息不应进入死信队列: %d", len(dlq))
+	}
+}
+
+func TestDeadLetterAndReplay(t *testing.T) {
+	tp, _ := setupTracer(t)
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "upload")
+	ctx = ctxx.WithTenantID(ctx, "t1")
+	for _, body := range []string{"retry-me", "poison"} {
+		if err := b.Publish(ctx, "user_image", &Message{Key: body, Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	span.End()
+
+	var calls = map[string]*int32{"retry-me": new(int32), "poison": new(int32)}
+	start := time.Now()
+	c := b.Consumer(&ConsumerOptions{
+		Retry:      &RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond},
+		DeadLetter: b,
+	})
+	runUntil(t, c, "user_image", func(ctx context.Context, msg *Message) error {
+		atomic.AddInt32(calls[msg.Key], 1)
+		if msg.Key == "poison" {
+			return Permanent(errors.New("消息格式错误"))
+		}
+		return errors.New("图片服务不可用")
+	}, func() bool { return len(b.Messages("user_image.dlq")) == 2 })
+
+	if n := atomic.LoadInt32(calls["retry-me"]); n != 3 {
+		t.Errorf("retry-me 处理 %d 次, want 3", n)
+	}
+	if n := atomic.LoadInt32(calls["poison"]); n != 1 {
+		t.Errorf("poison 处理 %d 次, want 1", n)
+	}
+	// 两次退避：10ms + 20ms
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("重试未退避: %v", elapsed)
+	}
+
+	byKey := map[string]*Message{}
+	for _, m := range b.Messages("user_image.dlq") {
+		byKey[m.Key] = m
+	}
+	dlq := byKey["retry-me"]
+	for k, want := range map[string]string{
+		HeaderDLQAttempts:      "3",
+		HeaderDLQError:         "图片服务不可用",
+		HeaderDLQOriginalTopic: "user_image",
+		HeaderDLQOriginalID:    "1",
+		HeaderDLQGroup:         "default",
+		HeaderDLQTraceID:       span.SpanContext().TraceID().String(),
+		ctxx.HeaderTenantID:    "t1",
+	} {
+		if got := dlq.Headers[k]; got != want {
+			t.Errorf("死信 %s = %q, want %q", k, got, want)
+		}
+	}
+	if got := byKey["poison"].Headers[HeaderDLQAttempts]; got != "1" {
+		t.Errorf("poison attempts = %q, want 1", got)
+	}
+
+	replayed, err := Replay(context.Background(), b, dlq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := b.Messages("user_image")
+	last := msgs[len(msgs)-1]
+	if last.ID != replayed.ID || string(last.Body) != "retry-me" {
+		t.Fatalf("重放的消息 = %+v", last)
+	}
+	for k := range last.Headers {
+		if strings.HasPrefix(k, "x-dlq-") && k != HeaderDLQGroup {
+			t.Errorf("重放的消息不应携带 %s", k)
+		}
+	}
+	if last.Headers[HeaderDLQGroup] != "default" {
+		t.Errorf("重放的消息应指定消费组: %q", last.Headers[HeaderDLQGroup])
+	}
+	if last.Headers[ctxx.HeaderTenantID] != "t1" {
+		t.Error("重放的消息应保留租户")
+	}
+	if !strings.Contains(last.Headers["traceparent"], span.SpanContext().TraceID().String()) {
+		t.Error("重放的消息应延续原链路")
+	}
+
+	if _, err := Replay(context.Background(), b, &Message{ID: "x", Headers: map[string]string{}}); err == nil {
+		t.Error("缺少原 topic 时应返回错误")
+	}
+}
+
+func TestReplayOnlyFailedGroup(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+	dlq := &Message{Key: "o1", Headers: map[string]string{HeaderDLQOriginalTopic: "orders", HeaderDLQGroup: "billing"}}
+	if _, err := Replay(context.Background(), b, dlq); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Publish(context.Background(), "orders", &Message{Key: "o2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for group, want := range map[string]string{"billing": "o1,o2", "shipping": "o2"} {
+		var mu sync.Mutex
+		var got []string
+		c := b.Consumer(&ConsumerOptions{Group: group})
+		runUntil(t, c, "orders", func(ctx context.Context, msg *Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, msg.Key)
+			return nil
+		}, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(got) > 0 && got[len(got)-1] == "o2"
+		})
+		if strings.Join(got, ",") != want {
+			t.Errorf("%s 处理 = %v, want %s", group, got, want)
+		}
+	}
+}
+
+func TestRedisClaimIdle(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redisx.NewClient(&hdmodel.Redis{Address: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	if err := NewRedisProducer(client, nil).Publish(ctx, "activity", &Message{Body: []byte("a1")}); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟消费者取出消息后崩溃，消息留在待确认列表中
+	stream := defaultRedisPrefix + "activity"
+	if err := client.XGroupCreateMkStream(ctx, stream, "default", "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "default", Consumer: "crashed", Streams: []string{stream, ">"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewRedisConsumer(client, &RedisConsumerOptions{Consumer: "alive"})
+	var got string
+	n, err := c.ClaimIdle(ctx, "activity", 0, func(ctx context.Context, msg *Message) error {
+		got = string(msg.Body)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || got != "a1" {
+		t.Errorf("n = %d, body = %q", n, got)
+	}
+	pending, err := client.XPending(ctx, stream, "default").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("待确认消息 = %d, want 0", pending.Count)
+	}
+}
@@ -0,0 +1,169 @@
+// Package outbox 事务性发件箱：消息与业务数据在同一个 MySQL 事务中写入发件箱表，
+// 由 Relay 按写入顺序发送到消息队列，避免“数据库提交成功但消息发送失败”导致的事件丢失
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/grayscalecloud/hertzcommon/db"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/mq"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const defaultTable = "hc_outbox"
+
+// HeaderOutboxID 发件箱记录 ID，Relay 发送时写入消息头，消费者可据此去重
+const HeaderOutboxID = "x-outbox-id"
+
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// Options 发件箱配置
+type Options struct {
+	// Table 发件箱表名，默认 hc_outbox
+	Table string
+	// Propagator 链路上下文传播器，默认 W3C Trace Context 与 Baggage
+	Propagator propagation.TextMapPropagator
+}
+
+// Execer 可执行写入语句的对象，*sql.DB、*sql.Tx、*sql.Conn 均满足
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Store 发件箱表
+type Store struct {
+	db    *sql.DB
+	opts  Options
+	pools *db.DB
+}
+
+// NewStore 基于已有的连接池创建发件箱，db 由调用方负责关闭
+func NewStore(db *sql.DB, opts *Options) (*Store, error) {
+	s := &Store{db: db}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Table == "" {
+		s.opts.Table = defaultTable
+	}
+	if !tableNamePattern.MatchString(s.opts.Table) {
+		return nil, fmt.Errorf("发件箱表名不合法: %q", s.opts.Table)
+	}
+	return s, nil
+}
+
+// Open 根据 hdmodel.MySQL 通过 db.Open 打开连接池并创建发件箱，关闭 Store 时一并关闭连接池
+func Open(ctx context.Context, cfg *hdmodel.MySQL, opts *Options) (*Store, error) {
+	pools, err := db.Open(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := pools.Primary().PingContext(pingCtx); err != nil {
+		_ = pools.Close()
+		return nil, fmt.Errorf("连接 MySQL 失败: %w", err)
+	}
+	s, err := NewStore(pools.Primary(), opts)
+	if err != nil {
+		_ = pools.Close()
+		return nil, err
+	}
+	s.pools = pools
+	return s, nil
+}
+
+// DB 返回发件箱使用的连接池
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Close 关闭连接池
+func (s *Store) Close() error {
+	if s.pools != nil {
+		return s.pools.Close()
+	}
+	return s.db.Close()
+}
+
+// CreateTable 创建发件箱表（已存在时跳过），也可以把 DDL 放到迁移脚本中执行
+func (s *Store) CreateTable(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, s.ddl()); err != nil {
+		return fmt.Errorf("创建发件箱表失败 [%s]: %w", s.opts.Table, err)
+	}
+	return nil
+}
+
+func (s *Store) ddl() string {
+	return "CREATE TABLE IF NOT EXISTS `" + s.opts.Table + "` (" +
+		"`id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT," +
+		"`topic` VARCHAR(255) NOT NULL," +
+		"`msg_key` VARCHAR(255) NOT NULL DEFAULT ''," +
+		"`headers` TEXT NOT NULL," +
+		"`body` MEDIUMBLOB NOT NULL," +
+		"`created_at` DATETIME(6) NOT NULL," +
+		"`sent_at` DATETIME(6) NULL," +
+		"`attempts` INT NOT NULL DEFAULT 0," +
+		"`last_error` VARCHAR(1024) NOT NULL DEFAULT ''," +
+		"PRIMARY KEY (`id`)," +
+		"KEY `idx_sent_at` (`sent_at`)" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+}
+
+// Add 在事务 tx 中写入一条待发送的消息，消息头会写入当前 ctx 的 ctxx 字段（租户、用户等）与链路上下文，
+// 事务提交后由 Relay 发送；事务回滚时消息一并丢弃
+func (s *Store) Add(ctx context.Context, tx Execer, topic string, msg *mq.Message) error {
+	mq.Inject(ctx, s.opts.Propagator, msg)
+	headers, err := json.Marshal(msg.Headers)
+	if err != nil {
+		return fmt.Errorf("序列化消息头失败: %w", err)
+	}
+	body := msg.Body
+	if body == nil {
+		body = []byte{}
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO `"+s.opts.Table+"` (`topic`, `msg_key`, `headers`, `body`, `created_at`) VALUES (?, ?, ?, ?, UTC_TIMESTAMP(6))",
+		topic, msg.Key, string(headers), body)
+	if err != nil {
+		return fmt.Errorf("写入发件箱失败 [topic: %s]: %w", topic, err)
+	}
+	return nil
+}
+
+// AddJSON 把 v 序列化为 JSON 后写入发件箱，用于发送 hdmodel 中的 MQ DTO
+func (s *Store) AddJSON(ctx context.Context, tx Execer, topic, key string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败 [topic: %s]: %w", topic, err)
+	}
+	return s.Add(ctx, tx, topic, &mq.Message{Key: key, Body: body})
+}
+
+// WithTx 在事务中执行 fn，fn 返回错误或 panic 时回滚，否则提交
+func (s *Store) WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	return nil
+}
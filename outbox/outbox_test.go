@@ -0,0 +1,349 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/mq"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var testSpan = trace.NewSpanContext(trace.SpanContextConfig{
+	TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+	SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+	TraceFlags: trace.FlagsSampled,
+})
+
+func newMockStore(t *testing.T) (*Store, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	s, err := NewStore(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, mock
+}
+
+// headersArg 校验写入发件箱的消息头
+type headersArg func(h map[string]string) bool
+
+func (m headersArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var h map[string]string
+	return json.Unmarshal([]byte(s), &h) == nil && m(h)
+}
+
+func headersJSON(t *testing.T, h map[string]string) string {
+	t.Helper()
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestNewStoreRejectsBadTable(t *testing.T) {
+	if _, err := NewStore(nil, &Options{Table: "outbox; DROP TABLE users"}); err == nil {
+		t.Error("非法表名应返回错误")
+	}
+}
+
+func TestAddInTransaction(t *testing.T) {
+	s, mock := newMockStore(t)
+	ctx := trace.ContextWithSpanContext(context.Background(), testSpan)
+	ctx = ctxx.WithTenantID(ctx, "t1")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO `hc_outbox`").
+		WithArgs("activity", "o1", headersArg(func(h map[string]string) bool {
+			return h[ctxx.HeaderTenantID] == "t1" && strings.Contains(h["traceparent"], testSpan.TraceID().String())
+		}), []byte(`{"id":"o1"}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := s.WithTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE orders SET status = 1"); err != nil {
+			return err
+		}
+		return s.AddJSON(ctx, tx, "activity", "o1", map[string]string{"id": "o1"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithTxRollback(t *testing.T) {
+	s, mock := newMockStore(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `hc_outbox`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	want := errors.New("库存不足")
+	err := s.WithTx(context.Background(), func(tx *sql.Tx) error {
+		if err := s.Add(context.Background(), tx, "activity", &mq.Message{Body: []byte("x")}); err != nil {
+			return err
+		}
+		return want
+	})
+	if !errors.Is(err, want) {
+		t.Fatalf("err = %v, want %v", err, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// failingProducer 发送指定 Key 的消息时失败
+type failingProducer struct {
+	mq.Producer
+	failKey string
+}
+
+func (p *failingProducer) Publish(ctx context.Context, topic string, msg *mq.Message) error {
+	if msg.Key == p.failKey {
+		return errors.New("broker 不可用")
+	}
+	return p.Producer.Publish(ctx, topic, msg)
+}
+
+func outboxRows(t *testing.T, keys ...string) *sqlmock.Rows {
+	rows := sqlmock.NewRows(outboxColumns)
+	for i, k := range keys {
+		rows.AddRow(int64(i+1), "activity", k, testHeaders(t), []byte(k), 0)
+	}
+	return rows
+}
+
+var outboxColumns = []string{"id", "topic", "msg_key", "headers", "body", "attempts"}
+
+func testHeaders(t *testing.T) string {
+	return headersJSON(t, map[string]string{
+		ctxx.HeaderTenantID: "t1",
+		"traceparent":       "00-" + testSpan.TraceID().String() + "-" + testSpan.SpanID().String() + "-01",
+	})
+}
+
+func TestRelayBatchInOrder(t *testing.T) {
+	s, mock := newMockStore(t)
+	broker := mq.NewMemoryBroker()
+	defer broker.Close()
+	r, err := NewRelay(s, broker, &RelayOptions{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery("SELECT .* FROM `hc_outbox` WHERE `sent_at` IS NULL AND `attempts` < \\? ORDER BY `id`").
+		WithArgs(10, 100).WillReturnRows(outboxRows(t, "a", "b", "c"))
+	mock.ExpectExec("UPDATE `hc_outbox` SET `sent_at` = UTC_TIMESTAMP\\(6\\) WHERE `id` IN \\(\\?, \\?, \\?\\)").
+		WithArgs(1, 2, 3).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	ctx := context.Background()
+	conn, err := s.DB().Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	n, err := r.relayBatch(ctx, conn)
+	if err != nil || n != 3 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	msgs := broker.Messages("activity")
+	if len(msgs) != 3 {
+		t.Fatalf("发送了 %d 条消息, want 3", len(msgs))
+	}
+	for i, m := range msgs {
+		if want := string(rune('a' + i)); m.Key != want {
+			t.Errorf("第 %d 条消息 = %s, want %s", i, m.Key, want)
+		}
+		if m.Headers[HeaderOutboxID] != string(rune('1'+i)) {
+			t.Errorf("%s = %q", HeaderOutboxID, m.Headers[HeaderOutboxID])
+		}
+		if m.Headers[ctxx.HeaderTenantID] != "t1" {
+			t.Errorf("租户 = %q, want t1", m.Headers[ctxx.HeaderTenantID])
+		}
+		if !strings.Contains(m.Headers["traceparent"], testSpan.TraceID().String()) {
+			t.Errorf("应延续写入发件箱时的链路: %s", m.Headers["traceparent"])
+		}
+	}
+	if got := testutil.ToFloat64(r.metrics.published.WithLabelValues("hc_outbox", "activity", "ok")); got != 3 {
+		t.Errorf("published ok = %v, want 3", got)
+	}
+}
+
+func TestRelayBatchStopsOnFailure(t *testing.T) {
+	s, mock := newMockStore(t)
+	broker := mq.NewMemoryBroker()
+	defer broker.Close()
+	r, err := NewRelay(s, &failingProducer{Producer: broker, failKey: "b"}, &RelayOptions{Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery("SELECT .* FROM `hc_outbox`").WillReturnRows(outboxRows(t, "a", "b", "c"))
+	mock.ExpectExec("UPDATE `hc_outbox` SET `attempts` = \\?, `last_error` = \\? WHERE `id` = \\?").
+		WithArgs(1, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `hc_outbox` SET `sent_at` = UTC_TIMESTAMP\\(6\\) WHERE `id` IN \\(\\?\\)").
+		WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := context.Background()
+	conn, err := s.DB().Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	n, err := r.relayBatch(ctx, conn)
+	if err == nil || n != 1 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	// 失败之后的消息不能先发出
+	if msgs := broker.Messages("activity"); len(msgs) != 1 || msgs[0].Key != "a" {
+		t.Errorf("发送的消息 = %+v", msgs)
+	}
+}
+
+func TestRelayBatchParksFailedRecords(t *testing.T) {
+	s, mock := newMockStore(t)
+	broker := mq.NewMemoryBroker()
+	defer broker.Close()
+	r, err := NewRelay(s, &failingProducer{Producer: broker, failKey: "b"}, &RelayOptions{
+		MaxAttempts: 3,
+		Registerer:  prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a 的消息头损坏，不可重试；b 已失败 2 次，这次达到上限；两条都被搁置，c 照常发送
+	rows := sqlmock.NewRows(outboxColumns).
+		AddRow(int64(1), "activity", "a", "{bad", []byte("a"), 0).
+		AddRow(int64(2), "activity", "b", testHeaders(t), []byte("b"), 2).
+		AddRow(int64(3), "activity", "c", testHeaders(t), []byte("c"), 0)
+	mock.ExpectQuery("SELECT .* FROM `hc_outbox`").WithArgs(3, 100).WillReturnRows(rows)
+	mock.ExpectExec("UPDATE `hc_outbox` SET `attempts` = \\?, `last_error` = \\? WHERE `id` = \\?").
+		WithArgs(3, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `hc_outbox` SET `attempts` = \\?, `last_error` = \\? WHERE `id` = \\?").
+		WithArgs(3, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `hc_outbox` SET `sent_at` = UTC_TIMESTAMP\\(6\\) WHERE `id` IN \\(\\?\\)").
+		WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := context.Background()
+	conn, err := s.DB().Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	n, err := r.relayBatch(ctx, conn)
+	if err != nil || n != 1 {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if msgs := broker.Messages("activity"); len(msgs) != 1 || msgs[0].Key != "c" {
+		t.Errorf("发送的消息 = %+v", msgs)
+	}
+	if got := testutil.ToFloat64(r.metrics.published.WithLabelValues("hc_outbox", "activity", "failed")); got != 2 {
+		t.Errorf("published failed = %v, want 2", got)
+	}
+}
+
+func TestRelayRunLeadership(t *testing.T) {
+	s, mock := newMockStore(t)
+	reg := prometheus.NewRegistry()
+	r, err := NewRelay(s, mq.NewMemoryBroker(), &RelayOptions{Registerer: reg, Interval: time.Hour, LockRetry: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他实例持有锁
+	mock.ExpectQuery("SELECT GET_LOCK").WithArgs("hc:outbox:hc_outbox").
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(0))
+	conn, err := r.acquire(context.Background())
+	if conn != nil || err != nil {
+		t.Fatalf("未抢到锁时 conn = %v, err = %v", conn, err)
+	}
+
+	mock.ExpectQuery("SELECT GET_LOCK").WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+	mock.ExpectQuery("SELECT .* FROM `hc_outbox` WHERE `sent_at` IS NULL").WillReturnRows(outboxRows(t))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\), TIMESTAMPDIFF").WithArgs(10, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"pending", "lag", "head_attempts"}).AddRow(2, 1500000, 4))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM `hc_outbox` WHERE `sent_at` IS NULL AND `attempts` >= \\?").WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"failed"}).AddRow(1))
+	mock.ExpectExec("DELETE FROM `hc_outbox` WHERE `sent_at` IS NOT NULL").
+		WithArgs(int64(7 * 24 * 3600)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for mock.ExpectationsWereMet() != nil {
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal(mock.ExpectationsWereMet())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := testutil.ToFloat64(r.metrics.leader.WithLabelValues("hc_outbox")); got != 1 {
+		t.Errorf("leader = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(r.metrics.pending.WithLabelValues("hc_outbox")); got != 2 {
+		t.Errorf("pending = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(r.metrics.lag.WithLabelValues("hc_outbox")); got != 1.5 {
+		t.Errorf("lag = %v, want 1.5", got)
+	}
+	if got := testutil.ToFloat64(r.metrics.headAttempts.WithLabelValues("hc_outbox")); got != 4 {
+		t.Errorf("head attempts = %v, want 4", got)
+	}
+	if got := testutil.ToFloat64(r.metrics.failed.WithLabelValues("hc_outbox")); got != 1 {
+		t.Errorf("failed = %v, want 1", got)
+	}
+
+	mock.ExpectExec("SELECT RELEASE_LOCK").WithArgs("hc:outbox:hc_outbox").WillReturnResult(sqlmock.NewResult(0, 0))
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.ToFloat64(r.metrics.leader.WithLabelValues("hc_outbox")); got != 0 {
+		t.Errorf("退出后 leader = %v, want 0", got)
+	}
+}
+
+func TestOpenInvalidDSN(t *testing.T) {
+	if _, err := Open(context.Background(), &hdmodel.MySQL{DSN: "://bad"}, nil); err == nil {
+		t.Error("非法 DSN 应返回错误")
+	}
+}
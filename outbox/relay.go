@@ -0,0 +1,399 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/monitor"
+	"github.com/grayscalecloud/hertzcommon/mq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RelayOptions 发件箱投递配置
+type RelayOptions struct {
+	// BatchSize 每次读取的最大条数，默认 100
+	BatchSize int
+	// Interval 没有待发送消息时的轮询间隔，默认 1 秒
+	Interval time.Duration
+	// MaxAttempts 单条消息最多发送的次数，默认 10；达到后消息被搁置为失败状态（attempts >= MaxAttempts），
+	// 不再阻塞后面的消息。消息头无法解析等不可重试的错误直接搁置。负数表示不限次数，失败的消息会一直阻塞后续消息。
+	// 失败的消息保留在表中，修复后把 attempts 置 0 即可重新发送
+	MaxAttempts int
+	// LockName 选主使用的 MySQL 命名锁（GET_LOCK），默认 hc:outbox:<表名>；同一张表只有持有锁的 Relay 发送消息
+	LockName string
+	// LockRetry 未抢到锁时重试的间隔，默认 5 秒
+	LockRetry time.Duration
+	// Retention 已发送消息的保留时间，超过后删除，默认 7 天
+	Retention time.Duration
+	// CleanupInterval 清理已发送消息的间隔，默认 1 小时
+	CleanupInterval time.Duration
+	// Registerer 注册指标的 Prometheus 注册表，默认 monitor.Reg；两者都为空时不记录指标
+	Registerer prometheus.Registerer
+}
+
+// Relay 把发件箱中的消息按写入顺序发送到消息队列，发送成功后标记为已发送并定期清理。
+//
+// 多个实例可以同时运行 Relay，通过 MySQL GET_LOCK 选出一个实例投递，持锁的连接断开后由其他实例接管。
+// 投递语义为至少一次：发送成功但标记失败时消息会被重复发送，消费者可按 HeaderOutboxID 去重
+type Relay struct {
+	store    *Store
+	producer mq.Producer
+	opts     RelayOptions
+	metrics  *relayMetrics
+}
+
+// NewRelay 创建发件箱投递
+func NewRelay(store *Store, producer mq.Producer, opts *RelayOptions) (*Relay, error) {
+	r := &Relay{store: store, producer: producer}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.BatchSize <= 0 {
+		r.opts.BatchSize = 100
+	}
+	if r.opts.Interval <= 0 {
+		r.opts.Interval = time.Second
+	}
+	if r.opts.MaxAttempts == 0 {
+		r.opts.MaxAttempts = 10
+	} else if r.opts.MaxAttempts < 0 {
+		r.opts.MaxAttempts = math.MaxInt32
+	}
+	if r.opts.LockName == "" {
+		r.opts.LockName = "hc:outbox:" + store.opts.Table
+	}
+	if r.opts.LockRetry <= 0 {
+		r.opts.LockRetry = 5 * time.Second
+	}
+	if r.opts.Retention <= 0 {
+		r.opts.Retention = 7 * 24 * time.Hour
+	}
+	if r.opts.CleanupInterval <= 0 {
+		r.opts.CleanupInterval = time.Hour
+	}
+	reg := r.opts.Registerer
+	if reg == nil && monitor.Reg != nil {
+		reg = monitor.Reg
+	}
+	if reg != nil {
+		m, err := newRelayMetrics(reg)
+		if err != nil {
+			return nil, err
+		}
+		r.metrics = m
+	}
+	return r, nil
+}
+
+// Run 阻塞运行，抢到锁后投递消息，直到 ctx 取消后释放锁并返回
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		conn, err := r.acquire(ctx)
+		if err != nil && ctx.Err() == nil {
+			hlog.CtxWarnf(ctx, "发件箱抢锁失败 [lock: %s]: %v", r.opts.LockName, err)
+		}
+		if conn != nil {
+			hlog.CtxInfof(ctx, "发件箱开始投递 [table: %s]", r.store.opts.Table)
+			r.lead(ctx, conn)
+			r.release(conn)
+			hlog.CtxInfof(ctx, "发件箱停止投递 [table: %s]", r.store.opts.Table)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.opts.LockRetry):
+		}
+	}
+}
+
+// acquire 在独占的连接上尝试获取命名锁，锁与连接的会话绑定，未抢到时返回 nil
+func (r *Relay) acquire(ctx context.Context) (*sql.Conn, error) {
+	conn, err := r.store.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", r.opts.LockName).Scan(&got); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if got.Int64 != 1 {
+		_ = conn.Close()
+		return nil, nil
+	}
+	return conn, nil
+}
+
+func (r *Relay) release(conn *sql.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", r.opts.LockName); err != nil {
+		hlog.Warnf("发件箱释放锁失败 [lock: %s]: %v", r.opts.LockName, err)
+	}
+	_ = conn.Close()
+}
+
+// lead 持锁期间循环投递，ctx 取消或持锁的连接失效时返回
+func (r *Relay) lead(ctx context.Context, conn *sql.Conn) {
+	r.metrics.setLeader(r.store.opts.Table, true)
+	defer r.metrics.setLeader(r.store.opts.Table, false)
+
+	var lastCleanup time.Time
+	for ctx.Err() == nil {
+		n, err := r.relayBatch(ctx, conn)
+		if err == nil {
+			err = r.observeLag(ctx, conn)
+		}
+		if err == nil && time.Since(lastCleanup) >= r.opts.CleanupInterval {
+			err = r.cleanup(ctx, conn)
+			lastCleanup = time.Now()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			hlog.CtxWarnf(ctx, "发件箱投递失败 [table: %s]: %v", r.store.opts.Table, err)
+			if pingErr := conn.PingContext(ctx); pingErr != nil {
+				hlog.CtxWarnf(ctx, "发件箱持锁的连接已失效 [lock: %s]: %v", r.opts.LockName, pingErr)
+				return
+			}
+		}
+		if err == nil && n == r.opts.BatchSize {
+			// 还有积压，立即读取下一批
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.opts.Interval):
+		}
+	}
+}
+
+type record struct {
+	id       int64
+	topic    string
+	key      string
+	headers  string
+	body     []byte
+	attempts int
+}
+
+// relayBatch 按 ID 顺序发送一批待发送的消息，遇到发送失败即停止，保证后写入的消息不会先于前面的消息发出；
+// 失败次数与原因记录在 attempts、last_error 列中，下一轮从失败的消息重新发送。
+// 失败次数达到 MaxAttempts 或错误不可重试时搁置该消息并继续发送后面的消息
+func (r *Relay) relayBatch(ctx context.Context, conn *sql.Conn) (int, error) {
+	table := r.store.opts.Table
+	rows, err := conn.QueryContext(ctx,
+		"SELECT `id`, `topic`, `msg_key`, `headers`, `body`, `attempts` FROM `"+table+
+			"` WHERE `sent_at` IS NULL AND `attempts` < ? ORDER BY `id` LIMIT ?",
+		r.opts.MaxAttempts, r.opts.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("读取发件箱失败: %w", err)
+	}
+	var batch []record
+	for rows.Next() {
+		var rec record
+		if err := rows.Scan(&rec.id, &rec.topic, &rec.key, &rec.headers, &rec.body, &rec.attempts); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("读取发件箱失败: %w", err)
+		}
+		batch = append(batch, rec)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("读取发件箱失败: %w", err)
+	}
+
+	var sent []int64
+	var pubErr error
+	for _, rec := range batch {
+		if pubErr = r.publish(ctx, rec); pubErr != nil {
+			attempts := rec.attempts + 1
+			parked := mq.IsPermanent(pubErr) || attempts >= r.opts.MaxAttempts
+			if parked {
+				attempts = max(attempts, r.opts.MaxAttempts)
+				r.metrics.observePublished(table, rec.topic, "failed")
+				hlog.CtxErrorf(ctx, "发件箱消息发送失败，已搁置 [table: %s, id: %d, attempts: %d]: %v",
+					table, rec.id, attempts, pubErr)
+			} else {
+				r.metrics.observePublished(table, rec.topic, "error")
+			}
+			if _, err := conn.ExecContext(ctx,
+				"UPDATE `"+table+"` SET `attempts` = ?, `last_error` = ? WHERE `id` = ?",
+				attempts, truncate(pubErr.Error(), 1024), rec.id); err != nil {
+				hlog.CtxWarnf(ctx, "发件箱记录失败原因失败 [id: %d]: %v", rec.id, err)
+				// 未能搁置时停在这条消息，下一轮重试
+				break
+			}
+			if !parked {
+				break
+			}
+			pubErr = nil
+			continue
+		}
+		r.metrics.observePublished(table, rec.topic, "ok")
+		sent = append(sent, rec.id)
+	}
+	if err := r.markSent(ctx, conn, sent); err != nil {
+		return len(sent), err
+	}
+	return len(sent), pubErr
+}
+
+func (r *Relay) publish(ctx context.Context, rec record) error {
+	msg := &mq.Message{Key: rec.key, Body: rec.body}
+	if err := json.Unmarshal([]byte(rec.headers), &msg.Headers); err != nil {
+		return mq.Permanent(fmt.Errorf("解析消息头失败 [id: %d]: %w", rec.id, err))
+	}
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]string)
+	}
+	msg.Headers[HeaderOutboxID] = strconv.FormatInt(rec.id, 10)
+	// 恢复写入发件箱时的租户与链路，发送 Span 挂在原链路下
+	return r.producer.Publish(mq.Extract(ctx, r.store.opts.Propagator, msg), rec.topic, msg)
+}
+
+func (r *Relay) markSent(ctx context.Context, conn *sql.Conn, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	if _, err := conn.ExecContext(ctx,
+		"UPDATE `"+r.store.opts.Table+"` SET `sent_at` = UTC_TIMESTAMP(6) WHERE `id` IN ("+placeholders+")", args...); err != nil {
+		return fmt.Errorf("标记已发送失败: %w", err)
+	}
+	return nil
+}
+
+// observeLag 更新待发送条数、最早一条待发送消息的等待时间与失败次数，以及已搁置的消息数
+func (r *Relay) observeLag(ctx context.Context, conn *sql.Conn) error {
+	if r.metrics == nil {
+		return nil
+	}
+	table := r.store.opts.Table
+	var pending int64
+	var lagMicros, headAttempts sql.NullInt64
+	err := conn.QueryRowContext(ctx,
+		"SELECT COUNT(*), TIMESTAMPDIFF(MICROSECOND, MIN(`created_at`), UTC_TIMESTAMP(6)), "+
+			"(SELECT `attempts` FROM `"+table+"` WHERE `sent_at` IS NULL AND `attempts` < ? ORDER BY `id` LIMIT 1) FROM `"+
+			table+"` WHERE `sent_at` IS NULL AND `attempts` < ?",
+		r.opts.MaxAttempts, r.opts.MaxAttempts).Scan(&pending, &lagMicros, &headAttempts)
+	if err != nil {
+		return fmt.Errorf("查询发件箱积压失败: %w", err)
+	}
+	var failed int64
+	if err := conn.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM `"+table+"` WHERE `sent_at` IS NULL AND `attempts` >= ?",
+		r.opts.MaxAttempts).Scan(&failed); err != nil {
+		return fmt.Errorf("查询发件箱失败消息失败: %w", err)
+	}
+	r.metrics.pending.WithLabelValues(table).Set(float64(pending))
+	r.metrics.lag.WithLabelValues(table).Set((time.Duration(lagMicros.Int64) * time.Microsecond).Seconds())
+	r.metrics.headAttempts.WithLabelValues(table).Set(float64(headAttempts.Int64))
+	r.metrics.failed.WithLabelValues(table).Set(float64(failed))
+	return nil
+}
+
+// cleanup 删除超过保留时间的已发送消息，每次最多删除 1000 条以免长时间锁表
+func (r *Relay) cleanup(ctx context.Context, conn *sql.Conn) error {
+	for {
+		res, err := conn.ExecContext(ctx,
+			"DELETE FROM `"+r.store.opts.Table+"` WHERE `sent_at` IS NOT NULL AND `sent_at` < UTC_TIMESTAMP(6) - INTERVAL ? SECOND LIMIT 1000",
+			int64(r.opts.Retention.Seconds()))
+		if err != nil {
+			return fmt.Errorf("清理发件箱失败: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil || n < 1000 {
+			return nil
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+type relayMetrics struct {
+	leader       *prometheus.GaugeVec
+	pending      *prometheus.GaugeVec
+	lag          *prometheus.GaugeVec
+	headAttempts *prometheus.GaugeVec
+	failed       *prometheus.GaugeVec
+	published    *prometheus.CounterVec
+}
+
+func newRelayMetrics(reg prometheus.Registerer) (*relayMetrics, error) {
+	m := &relayMetrics{}
+	var err error
+	if m.leader, err = monitor.RegisterOrExisting(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hertzcommon_outbox_leader",
+		Help: "当前实例是否持有发件箱投递锁，1 为是",
+	}, []string{"table"})); err != nil {
+		return nil, err
+	}
+	if m.pending, err = monitor.RegisterOrExisting(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hertzcommon_outbox_pending",
+		Help: "发件箱中待发送的消息数",
+	}, []string{"table"})); err != nil {
+		return nil, err
+	}
+	if m.lag, err = monitor.RegisterOrExisting(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hertzcommon_outbox_lag_seconds",
+		Help: "发件箱中最早一条待发送消息已等待的时间",
+	}, []string{"table"})); err != nil {
+		return nil, err
+	}
+	if m.headAttempts, err = monitor.RegisterOrExisting(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hertzcommon_outbox_head_attempts",
+		Help: "发件箱中最早一条待发送消息已失败的次数，持续增长说明该消息阻塞了后续消息",
+	}, []string{"table"})); err != nil {
+		return nil, err
+	}
+	if m.failed, err = monitor.RegisterOrExisting(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hertzcommon_outbox_failed",
+		Help: "发件箱中达到最大发送次数被搁置的消息数",
+	}, []string{"table"})); err != nil {
+		return nil, err
+	}
+	if m.published, err = monitor.RegisterOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hertzcommon_outbox_published_total",
+		Help: "发件箱发送的消息数，status 为 ok、error 或 failed（已搁置）",
+	}, []string{"table", "topic", "status"})); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *relayMetrics) setLeader(table string, leader bool) {
+	if m == nil {
+		return
+	}
+	v := 0.0
+	if leader {
+		v = 1
+	}
+	m.leader.WithLabelValues(table).Set(v)
+}
+
+func (m *relayMetrics) observePublished(table, topic, status string) {
+	if m == nil {
+		return
+	}
+	m.published.WithLabelValues(table, topic, status).Inc()
+}
@@ -0,0 +1,153 @@
+package ctxx
+
+import (
+	"context"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BaggageConflict metainfo 与 baggage 中同一键的值不一致
+type BaggageConflict struct {
+	Key          string
+	BaggageKey   string
+	MetaValue    string
+	BaggageValue string
+}
+
+// BaggageBridgeOptions ctxx 与 W3C Baggage 的映射配置
+type BaggageBridgeOptions struct {
+	// Mapping ctxx 键 -> baggage 键，默认使用已注册字段的 Baggage 配置（内置 tenant_id、merchant_id、user_id、app_type 同名映射）
+	Mapping map[string]string
+	// Allow 允许从入站 baggage 读取的 ctxx 键，默认 Mapping 中的全部键；写入 baggage 不受限制
+	Allow []string
+	// OnConflict 入站 baggage 与 metainfo 冲突时回调，以 metainfo 为准；默认记录告警日志
+	OnConflict func(ctx context.Context, conflict BaggageConflict)
+}
+
+// BaggageBridge ctxx 与 W3C Baggage 的桥接，由服务端与客户端中间件各自持有，通过 WithBaggageBridge 随请求 ctx 传递
+type BaggageBridge struct {
+	mapping    map[string]string
+	allow      map[string]struct{}
+	onConflict func(ctx context.Context, conflict BaggageConflict)
+}
+
+type bridgeKey struct{}
+
+// NewBaggageBridge 创建 baggage 桥接
+func NewBaggageBridge(opts *BaggageBridgeOptions) *BaggageBridge {
+	if opts == nil {
+		opts = &BaggageBridgeOptions{}
+	}
+	b := &BaggageBridge{mapping: opts.Mapping, onConflict: opts.OnConflict}
+	if b.mapping == nil {
+		b.mapping = make(map[string]string)
+		for _, k := range RegisteredKeys() {
+			if k.opts.Baggage != "" {
+				b.mapping[k.name] = k.opts.Baggage
+			}
+		}
+	}
+	allow := opts.Allow
+	if allow == nil {
+		for k := range b.mapping {
+			allow = append(allow, k)
+		}
+	}
+	b.allow = make(map[string]struct{}, len(allow))
+	for _, k := range allow {
+		b.allow[k] = struct{}{}
+	}
+	if b.onConflict == nil {
+		b.onConflict = func(ctx context.Context, c BaggageConflict) {
+			hlog.CtxWarnf(ctx, "baggage 与 metainfo 冲突，以 metainfo 为准 [key: %s, metainfo: %s, baggage: %s]",
+				c.Key, c.MetaValue, c.BaggageValue)
+		}
+	}
+	return b
+}
+
+// WithBaggageBridge 在 ctx 上开启桥接：之后通过 ctxx 设置的映射键会同步写入 baggage，
+// 由 OTel 传播器随出站请求传递给非 Hertz 服务；b 为空时关闭桥接
+func WithBaggageBridge(ctx context.Context, b *BaggageBridge) context.Context {
+	return context.WithValue(ctx, bridgeKey{}, b)
+}
+
+func bridgeFromContext(ctx context.Context) *BaggageBridge {
+	b, _ := ctx.Value(bridgeKey{}).(*BaggageBridge)
+	return b
+}
+
+// Mirror 把 ctx 中已有的映射键写入 baggage，用于开启桥接前已设置的值
+func (b *BaggageBridge) Mirror(ctx context.Context) context.Context {
+	for key := range b.mapping {
+		if v := GetMetaInfo(ctx, key); v != "" {
+			ctx = b.mirror(ctx, key, v)
+		}
+	}
+	return ctx
+}
+
+// Extract 从入站 baggage 读取允许的键写入 ctxx；allowed 为空时只按 Allow 过滤，
+// 否则还需 allowed 返回 true（如按请求来源限制可设置的键）。ctx 中已有不同的值时报告冲突并保留原值
+//
+// bag 应在通过 ctxx 设置其他值之前取出，否则同步写入的值会覆盖入站 baggage，冲突无法被发现
+func (b *BaggageBridge) Extract(ctx context.Context, bag baggage.Baggage, allowed func(key string) bool) context.Context {
+	if bag.Len() == 0 {
+		return ctx
+	}
+	for key, bagKey := range b.mapping {
+		if _, ok := b.allow[key]; !ok {
+			continue
+		}
+		if allowed != nil && !allowed(key) {
+			continue
+		}
+		bagValue := bag.Member(bagKey).Value()
+		if bagValue == "" {
+			continue
+		}
+		metaValue := GetMetaInfo(ctx, key)
+		switch {
+		case metaValue == "":
+			ctx = SetInbound(ctx, key, bagValue)
+		case metaValue != bagValue:
+			conflict := BaggageConflict{Key: key, BaggageKey: bagKey, MetaValue: metaValue, BaggageValue: bagValue}
+			trace.SpanFromContext(ctx).AddEvent("ctxx.baggage_conflict", trace.WithAttributes(
+				attribute.String("ctxx.key", key),
+				attribute.String("ctxx.metainfo_value", metaValue),
+				attribute.String("ctxx.baggage_value", bagValue),
+			))
+			b.onConflict(ctx, conflict)
+		}
+	}
+	return ctx
+}
+
+// mirrorToBaggage ctx 上开启了桥接时把映射键同步写入 baggage
+func mirrorToBaggage(ctx context.Context, key, value string) context.Context {
+	b := bridgeFromContext(ctx)
+	if b == nil {
+		return ctx
+	}
+	if _, ok := b.mapping[key]; !ok {
+		return ctx
+	}
+	return b.mirror(ctx, key, value)
+}
+
+func (b *BaggageBridge) mirror(ctx context.Context, key, value string) context.Context {
+	member, err := baggage.NewMemberRaw(b.mapping[key], value)
+	if err != nil {
+		hlog.CtxDebugf(ctx, "写入 baggage 失败 [key: %s]: %v", key, err)
+		return ctx
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		hlog.CtxDebugf(ctx, "写入 baggage 失败 [key: %s]: %v", key, err)
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
@@ -0,0 +1,59 @@
+package ctxx
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestBaggageBridge(t *testing.T) {
+	var conflicts []BaggageConflict
+	b := NewBaggageBridge(&BaggageBridgeOptions{
+		Mapping: map[string]string{TenantKey: "tenant.id", UserKey: "user.id", AppTypeKey: "app.type"},
+		Allow:   []string{TenantKey, UserKey},
+		OnConflict: func(ctx context.Context, c BaggageConflict) {
+			conflicts = append(conflicts, c)
+		},
+	})
+
+	// 出站：ctxx 设置的映射键同步写入 baggage
+	ctx := WithTenantID(WithBaggageBridge(context.Background(), b), "t1")
+	ctx = WithMerchantID(ctx, "m1")
+	bag := baggage.FromContext(ctx)
+	if bag.Member("tenant.id").Value() != "t1" || bag.Member(MerchantKey).Key() != "" {
+		t.Errorf("baggage = %s", bag.String())
+	}
+
+	// 入站：只读取 Allow 中的键，已有的 metainfo 值优先
+	inbound, err := baggage.Parse("tenant.id=t2,user.id=u%201,app.type=pos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := b.Extract(ctx, inbound, nil)
+	if GetTenantID(got) != "t1" || GetUserID(got) != "u 1" || GetAppType(got) != "" {
+		t.Errorf("tenant = %q, user = %q, app_type = %q", GetTenantID(got), GetUserID(got), GetAppType(got))
+	}
+	if len(conflicts) != 1 || conflicts[0].Key != TenantKey || conflicts[0].BaggageValue != "t2" {
+		t.Errorf("conflicts = %+v", conflicts)
+	}
+
+	// allowed 进一步限制
+	got = b.Extract(context.Background(), inbound, func(key string) bool { return key == TenantKey })
+	if GetTenantID(got) != "t2" || GetUserID(got) != "" {
+		t.Errorf("tenant = %q, user = %q", GetTenantID(got), GetUserID(got))
+	}
+
+	if bag := baggage.FromContext(WithTenantID(context.Background(), "t1")); bag.Len() != 0 {
+		t.Errorf("没有桥接的 ctx 不应写入 baggage: %s", bag.String())
+	}
+	if bag := baggage.FromContext(WithTenantID(WithBaggageBridge(ctx, nil), "t3")); bag.Member("tenant.id").Value() != "t1" {
+		t.Errorf("关闭桥接后不应写入 baggage: %s", bag.String())
+	}
+
+	// 开启桥接前已设置的值
+	mirrored := baggage.FromContext(b.Mirror(WithUserID(context.Background(), "u2")))
+	if mirrored.Member("user.id").Value() != "u2" {
+		t.Errorf("baggage = %s", mirrored.String())
+	}
+}
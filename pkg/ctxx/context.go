@@ -2,21 +2,17 @@ package ctxx
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/bytedance/gopkg/cloud/metainfo"
 )
 
 // Define key types for context values to avoid conflicts
 type (
-	tenantKey            struct{}
-	userIDKey            struct{}
-	requestIDKey         struct{}
-	merchantIDKey        struct{}
-	tenantIsolationKey   struct{}
-	merchantIsolationKey struct{}
-	appTypeKey           struct{}
-
-	adminKey struct{}
+	adminKey       struct{}
+	rolesKey       struct{}
+	permissionsKey struct{}
+	peerKey        struct{}
 )
 
 const (
@@ -32,68 +28,41 @@ const (
 )
 
 // SetMetaInfo 设置 metainfo 值，同时设置到 context 和 metainfo 中
+// 已注册字段按传递策略写入：持久字段同时写入持久值与临时值（兼容直接用 metainfo.GetValue 读取的调用方），
+// 本地字段只写入 context；未注册的 key 按临时值处理
 func SetMetaInfo(ctx context.Context, key string, value string) context.Context {
+	k, registered := LookupKey(key)
+	if registered && k.opts.Propagation == PropagationLocal {
+		// 本地字段只设置到 context 中
+		return context.WithValue(ctx, valueKey{key}, value)
+	}
 	// 设置到 metainfo 中
+	if registered && k.opts.Propagation == PropagationPersistent {
+		ctx = metainfo.WithPersistentValue(ctx, key, value)
+	}
 	ctx = metainfo.WithValue(ctx, key, value)
-	// 根据 key 类型设置到对应的 context 中
-	switch key {
-	case TenantKey:
-		return context.WithValue(ctx, tenantKey{}, value)
-	case UserKey:
-		return context.WithValue(ctx, userIDKey{}, value)
-	case RequestKey:
-		return context.WithValue(ctx, requestIDKey{}, value)
-	case MerchantKey:
-		return context.WithValue(ctx, merchantIDKey{}, value)
-	case AppTypeKey:
-		return context.WithValue(ctx, appTypeKey{}, value)
-	default:
-		// 对于其他 key，只设置到 metainfo 中
+	// 开启 baggage 桥接时同步写入 baggage
+	ctx = mirrorToBaggage(ctx, key, value)
+	if !registered {
+		// 对于未注册的 key，只设置到 metainfo 中
 		return ctx
 	}
+	return context.WithValue(ctx, valueKey{key}, value)
 }
 
-// GetMetaInfo 获取 metainfo 值，支持 fallback 机制
+// GetMetaInfo 获取 metainfo 值，支持 fallback 机制：
+// 已注册字段依次尝试 metainfo、别名与大写形式，最后从 context 中获取
 func GetMetaInfo(ctx context.Context, key string) string {
 	if ctx == nil {
 		return ""
 	}
-
-	// 首先尝试从 metainfo 中获取
-	if value, ok := metainfo.GetValue(ctx, key); ok && value != "" {
-		return value
+	if k, ok := LookupKey(key); ok {
+		v, _ := k.lookup(ctx)
+		return v
 	}
-
-	// 最后尝试从 context 中获取
-	switch key {
-	case TenantKey:
-		if value, ok := ctx.Value(tenantKey{}).(string); ok {
-			return value
-		} else {
-			return ""
-		}
-	case UserKey:
-		if value, ok := ctx.Value(userIDKey{}).(string); ok {
-			return value
-		}
-	case RequestKey:
-		if value, ok := ctx.Value(requestIDKey{}).(string); ok {
-			return value
-		}
-	case MerchantKey:
-		if value, ok := ctx.Value(merchantIDKey{}).(string); ok {
-			return value
-		} else {
-			return ""
-		}
-	case AppTypeKey:
-		if value, ok := ctx.Value(appTypeKey{}).(string); ok {
-			return value
-		} else {
-			return ""
-		}
+	if value, ok := metainfo.GetValue(ctx, key); ok {
+		return value
 	}
-
 	return ""
 }
 
@@ -160,38 +129,26 @@ func GetMerchantID(ctx context.Context) string {
 
 // WithTenantIsolation enables or disables tenant isolation for the context
 func WithTenantIsolation(ctx context.Context, enabled bool) context.Context {
-	return context.WithValue(ctx, tenantIsolationKey{}, enabled)
+	return tenantIsolationKeyDef.With(ctx, strconv.FormatBool(enabled))
 }
 
 // IsTenantIsolationEnabled checks if tenant isolation is enabled for the context
 func IsTenantIsolationEnabled(ctx context.Context) bool {
-	if ctx == nil {
-		return true // 默认启用租户隔离
-	}
-
-	if enabled, ok := ctx.Value(tenantIsolationKey{}).(bool); ok {
-		return enabled
-	}
-
-	return true // 默认启用租户隔离
+	// 默认启用租户隔离
+	v, ok := tenantIsolationKeyDef.lookup(ctx)
+	return !ok || v != "false"
 }
 
 // WithMerchantIsolation enables or disables merchant isolation for the context
 func WithMerchantIsolation(ctx context.Context, enabled bool) context.Context {
-	return context.WithValue(ctx, merchantIsolationKey{}, enabled)
+	return merchantIsolationKeyDef.With(ctx, strconv.FormatBool(enabled))
 }
 
 // IsMerchantIsolationEnabled checks if merchant isolation is enabled for the context
 func IsMerchantIsolationEnabled(ctx context.Context) bool {
-	if ctx == nil {
-		return true // 默认启用商户隔离
-	}
-
-	if enabled, ok := ctx.Value(merchantIsolationKey{}).(bool); ok {
-		return enabled
-	}
-
-	return true // 默认启用商户隔离
+	// 默认启用商户隔离
+	v, ok := merchantIsolationKeyDef.lookup(ctx)
+	return !ok || v != "false"
 }
 
 func WithIsAdmin(ctx context.Context, isAdmin bool) context.Context {
@@ -233,11 +190,10 @@ func GetContextInfo(ctx context.Context) *ContextInfo {
 func GetAllMetaInfo(ctx context.Context) map[string]string {
 	result := make(map[string]string)
 
-	// 获取所有预定义的键
-	keys := []string{TenantKey, UserKey, RequestKey, MerchantKey}
-	for _, key := range keys {
-		if value := GetMetaInfo(ctx, key); value != "" {
-			result[key] = value
+	// 获取所有已注册的键，包括自定义字段与隔离开关
+	for _, k := range RegisteredKeys() {
+		if value, _ := k.lookup(ctx); value != "" {
+			result[k.name] = value
 		}
 	}
 
@@ -254,10 +210,16 @@ func SetMultipleMetaInfo(ctx context.Context, values map[string]string) context.
 
 // CopyMetaInfo 从源 context 复制 metainfo 到目标 context
 func CopyMetaInfo(fromCtx, toCtx context.Context) context.Context {
-	keys := []string{TenantKey, UserKey, RequestKey, MerchantKey}
-	for _, key := range keys {
-		if value := GetMetaInfo(fromCtx, key); value != "" {
-			toCtx = SetMetaInfo(toCtx, key, value)
+	for _, k := range RegisteredKeys() {
+		value, _ := k.lookup(fromCtx)
+		if value == "" {
+			continue
+		}
+		// 上游传入的临时值复制后仍只在本服务内可见
+		if k.opts.Propagation == PropagationTransient && k.outboundValue(fromCtx) == "" {
+			toCtx = SetInbound(toCtx, k.name, value)
+		} else {
+			toCtx = SetMetaInfo(toCtx, k.name, value)
 		}
 	}
 	return toCtx
@@ -285,3 +247,65 @@ func WithAppType(ctx context.Context, appType string) context.Context {
 func GetAppType(ctx context.Context) string {
 	return GetMetaInfo(ctx, AppTypeKey)
 }
+
+// WithRoles 设置当前主体的角色列表（仅在进程内传递）
+func WithRoles(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, rolesKey{}, roles)
+}
+
+// GetRoles 获取当前主体的角色列表
+func GetRoles(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	if roles, ok := ctx.Value(rolesKey{}).([]string); ok {
+		return roles
+	}
+	return nil
+}
+
+// WithPermissions 设置当前主体被直接授予的权限（如 API Key 的 scopes，仅在进程内传递）
+func WithPermissions(ctx context.Context, permissions []string) context.Context {
+	return context.WithValue(ctx, permissionsKey{}, permissions)
+}
+
+// GetPermissions 获取当前主体被直接授予的权限
+func GetPermissions(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	if permissions, ok := ctx.Value(permissionsKey{}).([]string); ok {
+		return permissions
+	}
+	return nil
+}
+
+// PeerIdentity mTLS 客户端证书中的身份信息
+type PeerIdentity struct {
+	CommonName   string
+	Organization []string
+	DNSNames     []string
+	// URIs 证书中的 URI SAN，如 spiffe://cluster.local/ns/default/sa/order
+	URIs         []string
+	SerialNumber string
+	// Fingerprint 证书 DER 的 SHA-256 十六进制摘要
+	Fingerprint string
+	// Verified 证书链已通过客户端 CA 校验，未校验的身份不能作为可信网关
+	Verified bool
+}
+
+// WithPeerIdentity 设置 mTLS 客户端身份（仅在进程内传递）
+func WithPeerIdentity(ctx context.Context, peer *PeerIdentity) context.Context {
+	return context.WithValue(ctx, peerKey{}, peer)
+}
+
+// GetPeerIdentity 获取 mTLS 客户端身份，非 mTLS 请求返回 nil
+func GetPeerIdentity(ctx context.Context) *PeerIdentity {
+	if ctx == nil {
+		return nil
+	}
+	if peer, ok := ctx.Value(peerKey{}).(*PeerIdentity); ok {
+		return peer
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package ctxx
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+
+type detachedLinkKey struct{}
+
+// Detach 返回不会随原请求取消、也没有截止时间的 context，用于请求结束后继续执行的后台任务。
+// 新 context 携带全部已注册字段与隔离开关、其他 metainfo 持久值、管理员标记、角色、权限、mTLS 身份、baggage 与 baggage 桥接，
+// 但不携带原请求的 Span，只保留一个指向它的 link，见 DetachedLinks
+func Detach(ctx context.Context) context.Context {
+	detached := CopyMetaInfo(ctx, context.Background())
+	for k, v := range metainfo.GetAllPersistentValues(ctx) {
+		if _, ok := metainfo.GetPersistentValue(detached, k); !ok {
+			detached = metainfo.WithPersistentValue(detached, k, v)
+		}
+	}
+	if isAdmin := IsAdmin(ctx); isAdmin != nil {
+		detached = WithIsAdmin(detached, *isAdmin)
+	}
+	if roles := GetRoles(ctx); roles != nil {
+		detached = WithRoles(detached, roles)
+	}
+	if permissions := GetPermissions(ctx); permissions != nil {
+		detached = WithPermissions(detached, permissions)
+	}
+	if peer := GetPeerIdentity(ctx); peer != nil {
+		detached = WithPeerIdentity(detached, peer)
+	}
+	if bag := baggage.FromContext(ctx); bag.Len() > 0 {
+		detached = baggage.ContextWithBaggage(detached, bag)
+	}
+	if b := bridgeFromContext(ctx); b != nil {
+		detached = WithBaggageBridge(detached, b)
+	}
+
+	links := DetachedLinks(ctx)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		links = append(links[:len(links):len(links)], trace.Link{
+			SpanContext: sc,
+			Attributes:  []attribute.KeyValue{attribute.String("link.type", "detached_from")},
+		})
+	}
+	if len(links) > 0 {
+		detached = context.WithValue(detached, detachedLinkKey{}, links)
+	}
+	return detached
+}
+
+// DetachedLinks 返回 Detach 记录的原请求 Span link，在分离的 context 上创建 Span 时传入 trace.WithLinks
+func DetachedLinks(ctx context.Context) []trace.Link {
+	if ctx == nil {
+		return nil
+	}
+	links, _ := ctx.Value(detachedLinkKey{}).([]trace.Link)
+	return links
+}
+
+// Go 在分离的 context 中启动后台 goroutine：不随请求取消，保留身份信息，
+// 以原请求 Span 的子 Span 记录执行过程，返回错误或 panic 时记录日志并把 Span 标记为失败，panic 不会导致进程退出
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	parent := trace.SpanContextFromContext(ctx)
+	detached := Detach(ctx)
+	go func() {
+		spanCtx := detached
+		if parent.IsValid() {
+			spanCtx = trace.ContextWithSpanContext(detached, parent)
+		}
+		ctx, span := otel.Tracer(tracerName).Start(spanCtx, name,
+			trace.WithAttributes(SpanAttributes(detached)...),
+			trace.WithAttributes(attribute.String("goroutine.name", name)))
+		defer span.End()
+
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("goroutine %s panic: %v", name, r)
+				hlog.CtxErrorf(ctx, "后台任务 panic [name: %s]: %v\n%s", name, r, debug.Stack())
+				span.RecordError(err, trace.WithStackTrace(true))
+				span.SetStatus(codes.Error, err.Error())
+			}
+		}()
+
+		if err := fn(ctx); err != nil {
+			hlog.CtxErrorf(ctx, "后台任务失败 [name: %s]: %v", name, err)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}()
+}
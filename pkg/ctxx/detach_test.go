@@ -0,0 +1,129 @@
+package ctxx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDetach(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	reqCtx, span := tp.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+
+	reqCtx, cancel := context.WithTimeout(reqCtx, time.Minute)
+	reqCtx = WithTenantID(reqCtx, "t1")
+	reqCtx = WithAppType(reqCtx, "pos")
+	reqCtx = WithTenantIsolation(reqCtx, false)
+	reqCtx = WithIsAdmin(reqCtx, true)
+	reqCtx = WithRoles(reqCtx, []string{"owner"})
+	reqCtx = metainfo.WithPersistentValue(reqCtx, "trace_tag", "canary")
+
+	ctx := Detach(reqCtx)
+	cancel()
+
+	if ctx.Err() != nil {
+		t.Fatalf("分离的 context 不应随请求取消: %v", ctx.Err())
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("分离的 context 不应有截止时间")
+	}
+	if GetTenantID(ctx) != "t1" || GetAppType(ctx) != "pos" {
+		t.Errorf("ctxx 字段丢失: %v", GetAllMetaInfo(ctx))
+	}
+	if IsTenantIsolationEnabled(ctx) {
+		t.Error("隔离开关丢失")
+	}
+	if isAdmin := IsAdmin(ctx); isAdmin == nil || !*isAdmin {
+		t.Error("管理员标记丢失")
+	}
+	if roles := GetRoles(ctx); len(roles) != 1 || roles[0] != "owner" {
+		t.Errorf("roles = %v", roles)
+	}
+	if v, _ := metainfo.GetPersistentValue(ctx, "trace_tag"); v != "canary" {
+		t.Errorf("metainfo 持久值丢失: %q", v)
+	}
+
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		t.Error("分离的 context 不应携带原请求 Span")
+	}
+	links := DetachedLinks(ctx)
+	if len(links) != 1 || !links[0].SpanContext.Equal(span.SpanContext()) {
+		t.Errorf("links = %v", links)
+	}
+}
+
+func TestGo(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	reqCtx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	reqCtx = WithTenantID(reqCtx, "t1")
+	reqCtx, cancel := context.WithCancel(reqCtx)
+
+	done := make(chan string, 3)
+	Go(reqCtx, "send-mail", func(ctx context.Context) error {
+		<-time.After(10 * time.Millisecond)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		done <- GetTenantID(ctx)
+		return nil
+	})
+	Go(reqCtx, "panics", func(ctx context.Context) error {
+		defer func() { done <- "panic" }()
+		panic("boom")
+	})
+	Go(reqCtx, "fails", func(ctx context.Context) error {
+		defer func() { done <- "error" }()
+		return errors.New("下游不可用")
+	})
+	cancel()
+	parent.End()
+
+	got := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		select {
+		case v := <-done:
+			got[v] = true
+		case <-time.After(time.Second):
+			t.Fatal("后台任务未完成")
+		}
+	}
+	if !got["t1"] || !got["panic"] || !got["error"] {
+		t.Errorf("results = %v", got)
+	}
+
+	// 等待 Span 结束
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.Ended()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	status := map[string]codes.Code{}
+	for _, s := range recorder.Ended() {
+		if s.Name() == "request" {
+			continue
+		}
+		if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s 应为请求 Span 的子 Span", s.Name())
+		}
+		status[s.Name()] = s.Status().Code
+	}
+	want := map[string]codes.Code{"send-mail": codes.Unset, "panics": codes.Error, "fails": codes.Error}
+	for name, code := range want {
+		if c, ok := status[name]; !ok || c != code {
+			t.Errorf("%s status = %v (recorded: %v), want %v", name, c, ok, code)
+		}
+	}
+}
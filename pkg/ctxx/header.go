@@ -0,0 +1,49 @@
+package ctxx
+
+import "context"
+
+// 服务间 HTTP 调用传递 ctxx 元数据使用的请求头
+const (
+	HeaderTenantID   = "X-Tenant-Id"
+	HeaderMerchantID = "X-Merchant-Id"
+	HeaderUserID     = "X-User-Id"
+	HeaderAppType    = "X-App-Type"
+	HeaderRequestID  = "X-Request-Id"
+)
+
+// HeaderMapping ctxx 键与请求头的对应关系
+type HeaderMapping struct {
+	Key    string
+	Header string
+}
+
+// IdentityHeaders 携带身份信息的请求头，服务间签名会覆盖这些请求头，防止调用方伪造租户
+var IdentityHeaders = []HeaderMapping{
+	{Key: TenantKey, Header: HeaderTenantID},
+	{Key: MerchantKey, Header: HeaderMerchantID},
+	{Key: UserKey, Header: HeaderUserID},
+	{Key: AppTypeKey, Header: HeaderAppType},
+}
+
+// InjectHeaders 把 ctxx 中配置了请求头的字段（身份信息、请求 ID 与自定义字段）按传递策略写入请求头，
+// 上游传入的临时字段不会继续传递，set 一般为 req.Header.Set
+func InjectHeaders(ctx context.Context, set func(key, value string)) {
+	for _, k := range RegisteredKeys() {
+		if k.opts.Header == "" {
+			continue
+		}
+		if v := k.outboundValue(ctx); v != "" {
+			set(k.opts.Header, v)
+		}
+	}
+}
+
+// ExtractHeaders 从请求头中读取配置了请求头的字段写入 ctxx（见 SetInbound），get 一般为 c.Request.Header.Get
+func ExtractHeaders(ctx context.Context, get func(key string) string) context.Context {
+	for _, m := range HeaderKeys() {
+		if v := get(m.Header); v != "" {
+			ctx = SetInbound(ctx, m.Key, v)
+		}
+	}
+	return ctx
+}
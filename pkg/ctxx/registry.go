@@ -0,0 +1,252 @@
+package ctxx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Propagation 字段的跨服务传递策略
+type Propagation int
+
+const (
+	// PropagationTransient 只传给直接下游（一跳），下游继续调用时不再携带，对应 metainfo.WithValue
+	PropagationTransient Propagation = iota
+	// PropagationPersistent 沿调用链逐跳传递，对应 metainfo.WithPersistentValue
+	PropagationPersistent
+	// PropagationLocal 只在进程内传递，不写入 metainfo，也不会随请求头或 baggage 传给下游；上游传入的值会被忽略
+	PropagationLocal
+)
+
+func (p Propagation) String() string {
+	switch p {
+	case PropagationTransient:
+		return "transient"
+	case PropagationPersistent:
+		return "persistent"
+	case PropagationLocal:
+		return "local"
+	default:
+		return fmt.Sprintf("Propagation(%d)", int(p))
+	}
+}
+
+// KeyOptions 自定义上下文字段的行为配置
+type KeyOptions struct {
+	// Header 服务间 HTTP 调用使用的请求头，为空时不通过请求头传递
+	Header string
+	// SpanAttribute 写入 Span 的属性名，为空时不写入
+	SpanAttribute string
+	// Baggage 开启 baggage 桥接时默认映射的 baggage 键，为空时不映射
+	Baggage string
+	// Aliases 读取时依次尝试的备选 metainfo 键名；键名的大写形式（网关请求头转换后的 CGI 形式）总会被尝试
+	Aliases []string
+	// Propagation 跨服务传递策略，默认 PropagationTransient
+	Propagation Propagation
+}
+
+// Key 已注册的上下文字段
+type Key struct {
+	name string
+	opts KeyOptions
+}
+
+// valueKey 注册字段在 context 中的存储键
+type valueKey struct{ name string }
+
+var (
+	registryMu   sync.RWMutex
+	registry     = make(map[string]*Key)
+	registryKeys []*Key
+)
+
+// 内置字段，与自定义字段走同一套注册逻辑
+var (
+	_ = MustRegisterKey(TenantKey, KeyOptions{Header: HeaderTenantID, SpanAttribute: "tenant.id", Baggage: TenantKey, Propagation: PropagationPersistent})
+	_ = MustRegisterKey(MerchantKey, KeyOptions{Header: HeaderMerchantID, SpanAttribute: "merchant.id", Baggage: MerchantKey, Propagation: PropagationPersistent})
+	_ = MustRegisterKey(UserKey, KeyOptions{Header: HeaderUserID, SpanAttribute: "user.id", Baggage: UserKey, Propagation: PropagationPersistent})
+	_ = MustRegisterKey(AppTypeKey, KeyOptions{Header: HeaderAppType, SpanAttribute: "app.type", Baggage: AppTypeKey, Propagation: PropagationPersistent})
+	_ = MustRegisterKey(RequestKey, KeyOptions{Header: HeaderRequestID, SpanAttribute: "request.id", Propagation: PropagationPersistent})
+
+	tenantIsolationKeyDef   = MustRegisterKey(TenantIsolationKey, KeyOptions{Propagation: PropagationLocal})
+	merchantIsolationKeyDef = MustRegisterKey(MerchantIsolationKey, KeyOptions{Propagation: PropagationLocal})
+)
+
+// RegisterKey 注册自定义上下文字段（如 locale、灰度标记、设备 ID），注册后在 Get/Set/Copy/GetAll、
+// 请求头传递与 Span 属性中与内置字段行为一致。应在 init 或服务启动时、创建中间件之前调用
+func RegisterKey(name string, opts KeyOptions) (*Key, error) {
+	if name == "" {
+		return nil, fmt.Errorf("注册上下文字段失败: 名称不能为空")
+	}
+	if opts.Propagation < PropagationTransient || opts.Propagation > PropagationLocal {
+		return nil, fmt.Errorf("注册上下文字段失败: %s 的传递策略无效: %d", name, int(opts.Propagation))
+	}
+	if opts.Propagation == PropagationLocal && (opts.Header != "" || opts.Baggage != "") {
+		return nil, fmt.Errorf("注册上下文字段失败: 本地字段 %s 不能配置请求头或 baggage", name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		return nil, fmt.Errorf("注册上下文字段失败: %s 已注册", name)
+	}
+	if opts.Header != "" {
+		for _, k := range registryKeys {
+			if strings.EqualFold(k.opts.Header, opts.Header) {
+				return nil, fmt.Errorf("注册上下文字段失败: 请求头 %s 已被 %s 使用", opts.Header, k.name)
+			}
+		}
+	}
+	opts.Aliases = append([]string(nil), opts.Aliases...)
+	k := &Key{name: name, opts: opts}
+	registry[name] = k
+	registryKeys = append(registryKeys, k)
+	return k, nil
+}
+
+// MustRegisterKey 同 RegisterKey，失败时 panic
+func MustRegisterKey(name string, opts KeyOptions) *Key {
+	k, err := RegisterKey(name, opts)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// LookupKey 按名称查找已注册的字段
+func LookupKey(name string) (*Key, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	k, ok := registry[name]
+	return k, ok
+}
+
+// RegisteredKeys 按注册顺序返回全部字段
+func RegisteredKeys() []*Key {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]*Key(nil), registryKeys...)
+}
+
+// Name 字段名
+func (k *Key) Name() string { return k.name }
+
+// Options 字段配置
+func (k *Key) Options() KeyOptions { return k.opts }
+
+// With 设置字段值
+func (k *Key) With(ctx context.Context, value string) context.Context {
+	return SetMetaInfo(ctx, k.name, value)
+}
+
+// Get 读取字段值
+func (k *Key) Get(ctx context.Context) string {
+	return GetMetaInfo(ctx, k.name)
+}
+
+// lookup 返回字段的值以及是否被显式设置过（包括设置为空字符串）
+func (k *Key) lookup(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	if k.opts.Propagation != PropagationLocal {
+		if v := k.metaValue(ctx, k.name); v != "" {
+			return v, true
+		}
+		for _, alias := range k.fallbackKeys() {
+			if v := k.metaValue(ctx, alias); v != "" {
+				return v, true
+			}
+		}
+	}
+	v, ok := ctx.Value(valueKey{k.name}).(string)
+	return v, ok
+}
+
+// metaValue 从 metainfo 读取，优先读取与传递策略一致的类型
+func (k *Key) metaValue(ctx context.Context, name string) string {
+	if k.opts.Propagation == PropagationPersistent {
+		if v, ok := metainfo.GetPersistentValue(ctx, name); ok && v != "" {
+			return v
+		}
+	}
+	if v, ok := metainfo.GetValue(ctx, name); ok && v != "" {
+		return v
+	}
+	if k.opts.Propagation == PropagationTransient {
+		if v, ok := metainfo.GetPersistentValue(ctx, name); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (k *Key) fallbackKeys() []string {
+	keys := k.opts.Aliases
+	if upper := strings.ToUpper(k.name); upper != k.name {
+		keys = append(keys[:len(keys):len(keys)], upper)
+	}
+	return keys
+}
+
+// outboundValue 出站请求应携带的值：持久字段沿调用链继续传递，临时字段只携带本服务设置的值，本地字段不传递
+func (k *Key) outboundValue(ctx context.Context) string {
+	switch k.opts.Propagation {
+	case PropagationPersistent:
+		v, _ := k.lookup(ctx)
+		return v
+	case PropagationTransient:
+		// TransferForward 丢弃上游传入的临时值，只保留本跳设置的值
+		v, _ := metainfo.GetValue(metainfo.TransferForward(ctx), k.name)
+		return v
+	default:
+		return ""
+	}
+}
+
+// SetInbound 写入从上游请求（请求头、baggage 等）收到的值：持久字段与 SetMetaInfo 相同；
+// 临时字段只在本服务内可见，不会继续传给下游；本地字段不接受上游传入，原样返回 ctx
+func SetInbound(ctx context.Context, key, value string) context.Context {
+	k, ok := LookupKey(key)
+	if !ok {
+		return metainfo.SetMetaInfoFromMap(ctx, map[string]string{metainfo.PrefixTransientUpstream + key: value})
+	}
+	switch k.opts.Propagation {
+	case PropagationLocal:
+		return ctx
+	case PropagationTransient:
+		ctx = metainfo.SetMetaInfoFromMap(ctx, map[string]string{metainfo.PrefixTransientUpstream + key: value})
+		return context.WithValue(ctx, valueKey{key}, value)
+	default:
+		return SetMetaInfo(ctx, key, value)
+	}
+}
+
+// HeaderKeys 返回配置了请求头的全部字段
+func HeaderKeys() []HeaderMapping {
+	var out []HeaderMapping
+	for _, k := range RegisteredKeys() {
+		if k.opts.Header != "" {
+			out = append(out, HeaderMapping{Key: k.name, Header: k.opts.Header})
+		}
+	}
+	return out
+}
+
+// SpanAttributes 返回 ctx 中已设置、且配置了 SpanAttribute 的字段对应的 Span 属性
+func SpanAttributes(ctx context.Context) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, k := range RegisteredKeys() {
+		if k.opts.SpanAttribute == "" {
+			continue
+		}
+		if v := k.Get(ctx); v != "" {
+			attrs = append(attrs, attribute.String(k.opts.SpanAttribute, v))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,152 @@
+package ctxx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bytedance/gopkg/cloud/metainfo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var testLocaleKey = MustRegisterKey("test_locale", KeyOptions{
+	Header:        "X-Test-Locale",
+	SpanAttribute: "test.locale",
+	Aliases:       []string{"lang"},
+})
+
+var testPolicyHopKey = MustRegisterKey("test_policy_hop", KeyOptions{Header: "X-Test-Policy-Hop"})
+
+func TestRegisterKeyValidation(t *testing.T) {
+	cases := []struct {
+		name string
+		key  string
+		opts KeyOptions
+	}{
+		{"空名称", "", KeyOptions{}},
+		{"重复注册内置字段", TenantKey, KeyOptions{}},
+		{"请求头冲突", "test_dup_header", KeyOptions{Header: "x-tenant-id"}},
+		{"本地字段配置请求头", "test_local_header", KeyOptions{Propagation: PropagationLocal, Header: "X-Test-Local"}},
+	}
+	for _, tc := range cases {
+		if _, err := RegisterKey(tc.key, tc.opts); err == nil {
+			t.Errorf("%s: 应返回错误", tc.name)
+		}
+	}
+	if _, ok := LookupKey("test_dup_header"); ok {
+		t.Error("注册失败的字段不应出现在注册表中")
+	}
+}
+
+func TestRegisteredKeyBehavesLikeBuiltin(t *testing.T) {
+	ctx := WithTenantID(context.Background(), "t1")
+	ctx = testLocaleKey.With(ctx, "zh-CN")
+
+	if got := GetMetaInfo(ctx, "test_locale"); got != "zh-CN" {
+		t.Errorf("GetMetaInfo = %q, want zh-CN", got)
+	}
+	if got := testLocaleKey.Get(context.WithValue(ctx, struct{}{}, 1)); got != "zh-CN" {
+		t.Errorf("Get = %q, want zh-CN", got)
+	}
+	if got := testLocaleKey.Get(metainfo.WithValue(context.Background(), "lang", "en")); got != "en" {
+		t.Errorf("别名读取 = %q, want en", got)
+	}
+
+	all := GetAllMetaInfo(ctx)
+	if all["test_locale"] != "zh-CN" || all[TenantKey] != "t1" {
+		t.Errorf("GetAllMetaInfo = %v", all)
+	}
+
+	copied := CopyMetaInfo(ctx, context.Background())
+	if got := testLocaleKey.Get(copied); got != "zh-CN" {
+		t.Errorf("CopyMetaInfo 后 = %q, want zh-CN", got)
+	}
+
+	headers := map[string]string{}
+	InjectHeaders(ctx, func(k, v string) { headers[k] = v })
+	if headers["X-Test-Locale"] != "zh-CN" || headers[HeaderTenantID] != "t1" {
+		t.Errorf("InjectHeaders = %v", headers)
+	}
+	extracted := ExtractHeaders(context.Background(), func(k string) string { return headers[k] })
+	if got := testLocaleKey.Get(extracted); got != "zh-CN" {
+		t.Errorf("ExtractHeaders 后 = %q, want zh-CN", got)
+	}
+
+	attrs := attribute.NewSet(SpanAttributes(ctx)...)
+	if v, ok := attrs.Value("test.locale"); !ok || v.AsString() != "zh-CN" {
+		t.Errorf("SpanAttributes 缺少 test.locale: %v", attrs)
+	}
+	if v, ok := attrs.Value("tenant.id"); !ok || v.AsString() != "t1" {
+		t.Errorf("SpanAttributes 缺少 tenant.id: %v", attrs)
+	}
+}
+
+func TestAllMetaInfoIncludesAppTypeAndIsolation(t *testing.T) {
+	ctx := WithAppType(context.Background(), "merchant")
+	ctx = WithTenantIsolation(ctx, false)
+	ctx = WithMerchantIsolation(ctx, true)
+
+	all := GetAllMetaInfo(ctx)
+	want := map[string]string{AppTypeKey: "merchant", TenantIsolationKey: "false", MerchantIsolationKey: "true"}
+	for k, v := range want {
+		if all[k] != v {
+			t.Errorf("GetAllMetaInfo[%s] = %q, want %q", k, all[k], v)
+		}
+	}
+
+	copied := CopyMetaInfo(ctx, context.Background())
+	if GetAppType(copied) != "merchant" {
+		t.Error("CopyMetaInfo 丢失 app_type")
+	}
+	if IsTenantIsolationEnabled(copied) {
+		t.Error("CopyMetaInfo 丢失租户隔离开关")
+	}
+
+	// 隔离开关只在进程内生效，不能随 metainfo 传给下游
+	if _, ok := metainfo.GetValue(ctx, TenantIsolationKey); ok {
+		t.Error("隔离开关不应写入 metainfo")
+	}
+	headers := map[string]string{}
+	InjectHeaders(ctx, func(k, v string) { headers[k] = v })
+	if len(headers) != 1 {
+		t.Errorf("InjectHeaders = %v, 只应包含 app_type", headers)
+	}
+}
+
+func TestPropagationPolicy(t *testing.T) {
+	hopKey := testPolicyHopKey
+	ctx := WithTenantID(context.Background(), "t1")
+	ctx = hopKey.With(ctx, "h1")
+	ctx = WithMerchantIsolation(ctx, false)
+	if v, ok := metainfo.GetPersistentValue(ctx, TenantKey); !ok || v != "t1" {
+		t.Errorf("持久字段应写入 metainfo 持久值, got %q", v)
+	}
+	if _, ok := metainfo.GetPersistentValue(ctx, hopKey.Name()); ok {
+		t.Error("临时字段不应写入 metainfo 持久值")
+	}
+
+	// 模拟经过请求头的两跳调用
+	forward := func(ctx context.Context) context.Context {
+		headers := map[string]string{}
+		InjectHeaders(ctx, func(k, v string) { headers[k] = v })
+		return ExtractHeaders(context.Background(), func(k string) string { return headers[k] })
+	}
+	hop1 := forward(ctx)
+	if GetTenantID(hop1) != "t1" || hopKey.Get(hop1) != "h1" || !IsMerchantIsolationEnabled(hop1) {
+		t.Errorf("第一跳: %v", GetAllMetaInfo(hop1))
+	}
+	if copied := CopyMetaInfo(hop1, context.Background()); hopKey.Get(forward(copied)) != "" {
+		t.Error("复制后的上游临时字段不应继续传递")
+	}
+	hop2 := forward(hop1)
+	if GetTenantID(hop2) != "t1" {
+		t.Error("持久字段应继续传递")
+	}
+	if v := hopKey.Get(hop2); v != "" {
+		t.Errorf("临时字段只传一跳, got %q", v)
+	}
+
+	// 本地字段不接受上游传入
+	if ctx := SetInbound(context.Background(), TenantIsolationKey, "false"); !IsTenantIsolationEnabled(ctx) {
+		t.Error("本地字段不应被上游设置")
+	}
+}
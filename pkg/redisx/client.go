@@ -0,0 +1,32 @@
+package redisx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/redis/go-redis/v9"
+)
+
+// NewClient 根据 hdmodel.Redis 创建 Redis 客户端
+func NewClient(cfg *hdmodel.Redis) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.Address,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+}
+
+// NewClientWithPing 创建 Redis 客户端并检查连通性
+func NewClientWithPing(ctx context.Context, cfg *hdmodel.Redis) (*redis.Client, error) {
+	client := NewClient(cfg)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("连接 Redis 失败 [%s]: %w", cfg.Address, err)
+	}
+	return client, nil
+}
@@ -0,0 +1,67 @@
+package signature
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultNoncePrefix = "hc:nonce:"
+
+// NonceCache 记录已使用的 nonce
+type NonceCache interface {
+	// Add 记录 nonce 并保留 ttl，nonce 此前未出现过时返回 true
+	Add(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// MemoryNonceCache 进程内 nonce 缓存，只适用于单实例部署
+type MemoryNonceCache struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	nextSweep time.Time
+}
+
+// NewMemoryNonceCache 创建进程内 nonce 缓存
+func NewMemoryNonceCache() *MemoryNonceCache {
+	return &MemoryNonceCache{entries: make(map[string]time.Time)}
+}
+
+func (m *MemoryNonceCache) Add(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// 每个 ttl 周期清理一次过期记录
+	if now.After(m.nextSweep) {
+		for k, exp := range m.entries {
+			if now.After(exp) {
+				delete(m.entries, k)
+			}
+		}
+		m.nextSweep = now.Add(ttl)
+	}
+	if exp, ok := m.entries[nonce]; ok && now.Before(exp) {
+		return false, nil
+	}
+	m.entries[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// RedisNonceCache 基于 Redis SETNX 的 nonce 缓存，多实例共享
+type RedisNonceCache struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisNonceCache 创建 Redis nonce 缓存，prefix 为空时使用 hc:nonce:
+func NewRedisNonceCache(client redis.UniversalClient, prefix string) *RedisNonceCache {
+	if prefix == "" {
+		prefix = defaultNoncePrefix
+	}
+	return &RedisNonceCache{client: client, prefix: prefix}
+}
+
+func (r *RedisNonceCache) Add(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, r.prefix+nonce, 1, ttl).Result()
+}
@@ -0,0 +1,76 @@
+package signature
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/kvconfig"
+)
+
+// SecretProvider 按调用方提供签名密钥，第一个密钥用于签名，全部密钥均可用于校验（支持轮换）
+type SecretProvider interface {
+	Secrets(caller string) [][]byte
+}
+
+// StaticSecrets 固定的调用方密钥
+type StaticSecrets map[string][]string
+
+func (s StaticSecrets) Secrets(caller string) [][]byte {
+	return toBytes(s[caller])
+}
+
+// SecretsConfig 配置中心中 signkeys 配置的格式
+//
+//	callers:
+//	  order-svc: ["new-secret", "old-secret"]
+//	  payment-svc: ["secret"]
+type SecretsConfig struct {
+	Callers map[string][]string `yaml:"callers" json:"callers"`
+}
+
+// WatchedSecrets 从配置中心加载并热更新的调用方密钥
+type WatchedSecrets struct {
+	callers atomic.Pointer[map[string][][]byte]
+}
+
+// NewWatchedSecrets 读取 signkeys 配置并监听变化
+func NewWatchedSecrets(f *kvconfig.ConfigFactory, group string) (*WatchedSecrets, error) {
+	conf, err := kvconfig.GetYamlConfig[SecretsConfig](f, kvconfig.SignKeysDataId, group)
+	if err != nil {
+		return nil, fmt.Errorf("读取签名密钥配置失败: %w", err)
+	}
+	w := &WatchedSecrets{}
+	w.Update(conf)
+	err = kvconfig.WatchYamlConfig(f, kvconfig.SignKeysDataId, group, func(conf *SecretsConfig) {
+		w.Update(conf)
+		hlog.Infof("签名密钥已更新 [group: %s, callers: %d]", group, len(conf.Callers))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("监听签名密钥配置失败: %w", err)
+	}
+	return w, nil
+}
+
+// Update 替换调用方密钥
+func (w *WatchedSecrets) Update(conf *SecretsConfig) {
+	callers := make(map[string][][]byte, len(conf.Callers))
+	for caller, secrets := range conf.Callers {
+		callers[caller] = toBytes(secrets)
+	}
+	w.callers.Store(&callers)
+}
+
+func (w *WatchedSecrets) Secrets(caller string) [][]byte {
+	return (*w.callers.Load())[caller]
+}
+
+func toBytes(secrets []string) [][]byte {
+	out := make([][]byte, 0, len(secrets))
+	for _, s := range secrets {
+		if s != "" {
+			out = append(out, []byte(s))
+		}
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package signature
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app/client"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+)
+
+// 签名使用的请求头
+const (
+	HeaderCaller    = "X-Hc-Caller"
+	HeaderTimestamp = "X-Hc-Timestamp"
+	HeaderNonce     = "X-Hc-Nonce"
+	HeaderSignature = "X-Hc-Signature"
+	// HeaderAudience 请求的目标服务，默认为请求的 Host，防止签名被重放到其他服务
+	HeaderAudience = "X-Hc-Audience"
+
+	algorithm = "HC-HMAC-SHA256"
+)
+
+var ErrNoSecret = errors.New("没有可用的签名密钥")
+
+// Signer 为出站请求签名
+type Signer struct {
+	caller  string
+	secrets SecretProvider
+}
+
+// NewSigner 创建签名器，使用 caller 在 secrets 中的第一个密钥签名
+func NewSigner(caller string, secrets SecretProvider) *Signer {
+	return &Signer{caller: caller, secrets: secrets}
+}
+
+// Sign 写入 ctx 中的身份信息（不覆盖已设置的请求头）并为请求签名
+//
+// 签名覆盖目标服务（X-Hc-Audience，未设置时取请求的 Host）、方法、路径与查询串、请求体摘要、时间戳、nonce
+// 以及 ctxx.IdentityHeaders 中的请求头，请求体须已完整写入 req（不支持流式请求体）
+func (s *Signer) Sign(ctx context.Context, req *protocol.Request) error {
+	secrets := s.secrets.Secrets(s.caller)
+	if len(secrets) == 0 {
+		return fmt.Errorf("%w [caller: %s]", ErrNoSecret, s.caller)
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("生成 nonce 失败: %w", err)
+	}
+
+	ctxx.InjectHeaders(ctx, func(key, value string) {
+		if len(req.Header.Peek(key)) == 0 {
+			req.Header.Set(key, value)
+		}
+	})
+	if len(req.Header.Peek(HeaderAudience)) == 0 {
+		req.Header.Set(HeaderAudience, string(req.Host()))
+	}
+	req.Header.Set(HeaderCaller, s.caller)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set(HeaderNonce, hex.EncodeToString(nonce))
+	req.Header.Set(HeaderSignature, sign(secrets[0], canonicalRequest(req)))
+	return nil
+}
+
+// ClientMiddleware 返回为请求签名的 Hertz 客户端中间件
+func (s *Signer) ClientMiddleware() client.Middleware {
+	return func(next client.Endpoint) client.Endpoint {
+		return func(ctx context.Context, req *protocol.Request, resp *protocol.Response) error {
+			if err := s.Sign(ctx, req); err != nil {
+				return err
+			}
+			return next(ctx, req, resp)
+		}
+	}
+}
+
+// canonicalRequest 构造待签名字符串
+func canonicalRequest(req *protocol.Request) string {
+	bodyHash := sha256.Sum256(req.Body())
+	var b strings.Builder
+	b.WriteString(algorithm)
+	b.WriteByte('\n')
+	b.WriteString(req.Header.Get(HeaderCaller))
+	b.WriteByte('\n')
+	b.WriteString(req.Header.Get(HeaderAudience))
+	b.WriteByte('\n')
+	b.WriteString(req.Header.Get(HeaderTimestamp))
+	b.WriteByte('\n')
+	b.WriteString(req.Header.Get(HeaderNonce))
+	b.WriteByte('\n')
+	b.Write(req.Method())
+	b.WriteByte('\n')
+	b.Write(req.URI().Path())
+	b.WriteByte('\n')
+	b.Write(req.URI().QueryString())
+	b.WriteByte('\n')
+	for _, m := range ctxx.IdentityHeaders {
+		b.WriteString(strings.ToLower(m.Header))
+		b.WriteByte(':')
+		b.WriteString(req.Header.Get(m.Header))
+		b.WriteByte('\n')
+	}
+	b.WriteString(hex.EncodeToString(bodyHash[:]))
+	return b.String()
+}
+
+func sign(secret []byte, canonical string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(canonical))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,140 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloudwego/hertz/pkg/protocol"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/grayscalecloud/hertzcommon/pkg/redisx"
+)
+
+func newSignedRequest(t *testing.T, signer *Signer) *protocol.Request {
+	t.Helper()
+	req := protocol.NewRequest("POST", "http://order-svc/api/orders?page=1", nil)
+	req.SetBodyString(`{"sku":"A1"}`)
+	ctx := ctxx.WithTenantID(context.Background(), "t1")
+	ctx = ctxx.WithUserID(ctx, "u1")
+	if err := signer.Sign(ctx, req); err != nil {
+		t.Fatalf("签名失败: %v", err)
+	}
+	return req
+}
+
+func TestSignAndVerify(t *testing.T) {
+	ctx := context.Background()
+	secrets := StaticSecrets{"gateway": {"s1"}}
+	signer := NewSigner("gateway", secrets)
+	verifier := NewVerifier(secrets, nil)
+
+	req := newSignedRequest(t, signer)
+	if req.Header.Get(ctxx.HeaderTenantID) != "t1" {
+		t.Fatal("签名时应写入 ctxx 请求头")
+	}
+	caller, err := verifier.Verify(ctx, req)
+	if err != nil || caller != "gateway" {
+		t.Fatalf("Verify() = %q, %v", caller, err)
+	}
+	if _, err := verifier.Verify(ctx, req); !errors.Is(err, ErrNonceReused) {
+		t.Errorf("重放 err = %v, want %v", err, ErrNonceReused)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(req *protocol.Request)
+		want   error
+	}{
+		{name: "伪造租户", tamper: func(req *protocol.Request) { req.Header.Set(ctxx.HeaderTenantID, "t2") }, want: ErrBadSignature},
+		{name: "添加商户", tamper: func(req *protocol.Request) { req.Header.Set(ctxx.HeaderMerchantID, "m1") }, want: ErrBadSignature},
+		{name: "修改请求体", tamper: func(req *protocol.Request) { req.SetBodyString(`{"sku":"B2"}`) }, want: ErrBadSignature},
+		{name: "修改查询串", tamper: func(req *protocol.Request) { req.SetRequestURI("http://order-svc/api/orders?page=2") }, want: ErrBadSignature},
+		{name: "修改方法", tamper: func(req *protocol.Request) { req.SetMethod("PUT") }, want: ErrBadSignature},
+		{name: "时间戳过期", tamper: func(req *protocol.Request) {
+			req.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10))
+		}, want: ErrTimestampSkew},
+		{name: "未知调用方", tamper: func(req *protocol.Request) { req.Header.Set(HeaderCaller, "other") }, want: ErrUnknownCaller},
+		{name: "缺少签名", tamper: func(req *protocol.Request) { req.Header.Del(HeaderSignature) }, want: ErrMissingSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newSignedRequest(t, signer)
+			tt.tamper(req)
+			if _, err := verifier.Verify(ctx, req); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignAudience(t *testing.T) {
+	ctx := context.Background()
+	secrets := StaticSecrets{"gateway": {"s1"}}
+	signer := NewSigner("gateway", secrets)
+
+	req := newSignedRequest(t, signer)
+	if got := req.Header.Get(HeaderAudience); got != "order-svc" {
+		t.Fatalf("audience = %q, want order-svc", got)
+	}
+	// 发给 order-svc 的请求重放到 pay-svc
+	replayed := &protocol.Request{}
+	req.CopyTo(replayed)
+	replayed.SetHost("pay-svc")
+	if _, err := NewVerifier(secrets, nil).Verify(ctx, replayed); !errors.Is(err, ErrWrongAudience) {
+		t.Errorf("其他 Host err = %v, want %v", err, ErrWrongAudience)
+	}
+	if _, err := NewVerifier(secrets, &VerifierOptions{Audiences: []string{"pay-svc"}}).Verify(ctx, req); !errors.Is(err, ErrWrongAudience) {
+		t.Errorf("其他服务名 err = %v, want %v", err, ErrWrongAudience)
+	}
+	// 改写目标服务后签名不匹配
+	req.Header.Set(HeaderAudience, "pay-svc")
+	if _, err := NewVerifier(secrets, &VerifierOptions{Audiences: []string{"pay-svc"}}).Verify(ctx, req); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("篡改目标服务 err = %v, want %v", err, ErrBadSignature)
+	}
+
+	req = newSignedRequest(t, signer)
+	if _, err := NewVerifier(secrets, &VerifierOptions{Audiences: []string{"order-svc"}}).Verify(ctx, req); err != nil {
+		t.Errorf("配置的服务名 err = %v", err)
+	}
+}
+
+func TestSecretRotation(t *testing.T) {
+	ctx := context.Background()
+	oldSigner := NewSigner("gateway", StaticSecrets{"gateway": {"old"}})
+	secrets := &WatchedSecrets{}
+	secrets.Update(&SecretsConfig{Callers: map[string][]string{"gateway": {"new", "old"}}})
+	verifier := NewVerifier(secrets, nil)
+
+	if _, err := verifier.Verify(ctx, newSignedRequest(t, oldSigner)); err != nil {
+		t.Fatalf("轮换期间旧密钥应可校验: %v", err)
+	}
+	secrets.Update(&SecretsConfig{Callers: map[string][]string{"gateway": {"new"}}})
+	if _, err := verifier.Verify(ctx, newSignedRequest(t, oldSigner)); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("轮换完成后 err = %v, want %v", err, ErrBadSignature)
+	}
+	if _, err := verifier.Verify(ctx, newSignedRequest(t, NewSigner("gateway", secrets))); err != nil {
+		t.Errorf("新密钥校验失败: %v", err)
+	}
+}
+
+func TestRedisNonceCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redisx.NewClient(&hdmodel.Redis{Address: mr.Addr()})
+	defer client.Close()
+	secrets := StaticSecrets{"gateway": {"s1"}}
+	nonces := NewRedisNonceCache(client, "")
+	a := NewVerifier(secrets, &VerifierOptions{Nonces: nonces})
+	b := NewVerifier(secrets, &VerifierOptions{Nonces: nonces})
+
+	req := newSignedRequest(t, NewSigner("gateway", secrets))
+	if _, err := a.Verify(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Verify(context.Background(), req); !errors.Is(err, ErrNonceReused) {
+		t.Errorf("跨实例重放 err = %v, want %v", err, ErrNonceReused)
+	}
+}
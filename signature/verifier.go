@@ -0,0 +1,124 @@
+package signature
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/protocol"
+)
+
+const defaultMaxSkew = 5 * time.Minute
+
+var (
+	ErrMissingSignature = errors.New("缺少请求签名")
+	ErrUnknownCaller    = errors.New("未知的调用方")
+	ErrTimestampSkew    = errors.New("请求时间戳超出允许范围")
+	ErrBadSignature     = errors.New("请求签名不匹配")
+	ErrNonceReused      = errors.New("nonce 已使用，疑似重放请求")
+	ErrWrongAudience    = errors.New("请求签名的目标服务不是本服务")
+)
+
+// VerifierOptions 签名校验配置
+type VerifierOptions struct {
+	// MaxSkew 允许的时钟偏差，默认 5 分钟；nonce 至少保留两倍时长
+	MaxSkew time.Duration
+	// Nonces nonce 缓存，默认使用进程内缓存；多实例部署时应使用 RedisNonceCache
+	Nonces NonceCache
+	// Audiences 本服务接受的目标服务名（X-Hc-Audience），如服务发现中的服务名；
+	// 为空时只接受目标为本次请求 Host 的签名
+	Audiences []string
+}
+
+// Verifier 校验入站请求签名
+type Verifier struct {
+	secrets   SecretProvider
+	maxSkew   time.Duration
+	nonces    NonceCache
+	audiences []string
+}
+
+// NewVerifier 创建签名校验器
+func NewVerifier(secrets SecretProvider, opts *VerifierOptions) *Verifier {
+	o := VerifierOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxSkew <= 0 {
+		o.MaxSkew = defaultMaxSkew
+	}
+	if o.Nonces == nil {
+		o.Nonces = NewMemoryNonceCache()
+	}
+	return &Verifier{secrets: secrets, maxSkew: o.MaxSkew, nonces: o.Nonces, audiences: o.Audiences}
+}
+
+// Verify 校验请求签名，返回调用方名称
+//
+// 签名通过后才记录 nonce，避免未签名的请求污染 nonce 缓存
+func (v *Verifier) Verify(ctx context.Context, req *protocol.Request) (string, error) {
+	caller := req.Header.Get(HeaderCaller)
+	ts := req.Header.Get(HeaderTimestamp)
+	nonce := req.Header.Get(HeaderNonce)
+	signature := req.Header.Get(HeaderSignature)
+	if caller == "" || ts == "" || nonce == "" || signature == "" {
+		return "", ErrMissingSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return caller, fmt.Errorf("%w: %s", ErrTimestampSkew, ts)
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > v.maxSkew || skew < -v.maxSkew {
+		return caller, fmt.Errorf("%w: %s", ErrTimestampSkew, skew)
+	}
+
+	if audience := req.Header.Get(HeaderAudience); !v.accepts(req, audience) {
+		return caller, fmt.Errorf("%w: %q", ErrWrongAudience, audience)
+	}
+
+	secrets := v.secrets.Secrets(caller)
+	if len(secrets) == 0 {
+		return caller, fmt.Errorf("%w: %s", ErrUnknownCaller, caller)
+	}
+	canonical := canonicalRequest(req)
+	matched := false
+	for _, secret := range secrets {
+		if hmac.Equal([]byte(sign(secret, canonical)), []byte(signature)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return caller, ErrBadSignature
+	}
+
+	fresh, err := v.nonces.Add(ctx, caller+":"+nonce, 2*v.maxSkew)
+	if err != nil {
+		return caller, fmt.Errorf("记录 nonce 失败: %w", err)
+	}
+	if !fresh {
+		return caller, ErrNonceReused
+	}
+	return caller, nil
+}
+
+// accepts 判断签名的目标服务是否是本服务
+func (v *Verifier) accepts(req *protocol.Request, audience string) bool {
+	if audience == "" {
+		return false
+	}
+	if len(v.audiences) == 0 {
+		return strings.EqualFold(audience, string(req.Host()))
+	}
+	for _, a := range v.audiences {
+		if strings.EqualFold(audience, a) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+)
+
+// ErrInvalidSignature 签名地址无效或已过期
+var ErrInvalidSignature = errors.New("签名地址无效或已过期")
+
+// Local 本地文件系统存储，签名地址由 Handler 校验并提供读写
+type Local struct {
+	root      string
+	baseURL   string
+	publicURL string
+	signKey   []byte
+	expiry    time.Duration
+	now       func() time.Time
+}
+
+// NewLocal 创建本地文件系统存储：Local.Root 为根目录，不存在时创建；
+// Local.BaseURL 为挂载 Handler 的地址，Local.SignKey 为签名密钥（至少 16 字节）
+func NewLocal(cfg *hdmodel.Storage) (*Local, error) {
+	if cfg.Local.Root == "" {
+		return nil, errors.New("创建本地存储失败: 未配置 root")
+	}
+	if len(cfg.Local.SignKey) < 16 {
+		return nil, errors.New("创建本地存储失败: sign_key 至少需要 16 字节")
+	}
+	root, err := filepath.Abs(cfg.Local.Root)
+	if err != nil {
+		return nil, fmt.Errorf("创建本地存储失败: %w", err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("创建本地存储失败: %w", err)
+	}
+	return &Local{
+		root:      root,
+		baseURL:   strings.TrimSuffix(cfg.Local.BaseURL, "/"),
+		publicURL: cfg.PublicURL,
+		signKey:   []byte(cfg.Local.SignKey),
+		expiry:    urlExpiry(cfg),
+		now:       time.Now,
+	}, nil
+}
+
+// path 返回对象的文件路径
+func (l *Local) path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put 实现 Storage，先写入临时文件再重命名，读取方不会看到写了一半的对象；本地存储不保存 contentType
+func (l *Local) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("写入对象失败 [%s]: %w", key, err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("写入对象失败 [%s]: %w", key, err)
+	}
+	defer os.Remove(f.Name())
+	n, err := io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("写入对象失败 [%s]: %w", key, err)
+	}
+	if size >= 0 && n != size {
+		return fmt.Errorf("写入对象失败 [%s]: 长度 %d 与声明的 %d 不一致", key, n, size)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return fmt.Errorf("写入对象失败 [%s]: %w", key, err)
+	}
+	return nil
+}
+
+// Get 实现 Storage
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取对象失败 [%s]: %w", key, err)
+	}
+	return f, nil
+}
+
+// Delete 实现 Storage
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("删除对象失败 [%s]: %w", key, err)
+	}
+	return nil
+}
+
+// URL 实现 Storage
+func (l *Local) URL(ctx context.Context, key string) (string, error) {
+	if _, err := CleanKey(key); err != nil {
+		return "", err
+	}
+	if l.publicURL != "" {
+		return publicURL(l.publicURL, key), nil
+	}
+	return l.PresignGet(ctx, key, l.expiry)
+}
+
+// PresignGet 实现 Storage
+func (l *Local) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return l.presign(http.MethodGet, key, expiry)
+}
+
+// PresignPut 实现 Storage
+func (l *Local) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return l.presign(http.MethodPut, key, expiry)
+}
+
+func (l *Local) presign(method, key string, expiry time.Duration) (string, error) {
+	if _, err := CleanKey(key); err != nil {
+		return "", err
+	}
+	if err := checkExpiry(expiry); err != nil {
+		return "", err
+	}
+	if l.baseURL == "" {
+		return "", errors.New("生成签名地址失败: 未配置 base_url")
+	}
+	expires := strconv.FormatInt(l.now().Add(expiry).Unix(), 10)
+	q := url.Values{"expires": {expires}, "sig": {l.sign(method, key, expires)}}
+	return l.baseURL + "/" + escapeKey(key) + "?" + q.Encode(), nil
+}
+
+// sign 对方法、对象键与过期时间签名，GET 地址不能用于 PUT
+func (l *Local) sign(method, key, expires string) string {
+	mac := hmac.New(sha256.New, l.signKey)
+	mac.Write([]byte(method + "\n" + key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名地址的参数，签名不匹配或已过期时返回 ErrInvalidSignature
+func (l *Local) Verify(method, key, expires, sig string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || l.now().Unix() > exp {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(l.sign(method, key, expires))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Handler 提供签名地址的读写，路由需要以 *key 结尾，如 h.Any("/files/*key", l.Handler())；
+// 支持 GET、HEAD 与 PUT，HEAD 使用 GET 的签名
+func (l *Local) Handler() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		key := strings.TrimPrefix(c.Param("key"), "/")
+		method := string(c.Method())
+		signed := method
+		switch method {
+		case http.MethodGet, http.MethodHead:
+			signed = http.MethodGet
+		case http.MethodPut:
+		default:
+			c.AbortWithStatus(http.StatusMethodNotAllowed)
+			return
+		}
+		if _, err := CleanKey(key); err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		if err := l.Verify(signed, key, c.Query("expires"), c.Query("sig")); err != nil {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		if method == http.MethodPut {
+			body := c.Request.Body()
+			if err := l.Put(ctx, key, bytes.NewReader(body), int64(len(body)), string(c.ContentType())); err != nil {
+				hlog.CtxErrorf(ctx, "%v", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			c.Status(http.StatusOK)
+			return
+		}
+
+		rc, err := l.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			hlog.CtxErrorf(ctx, "%v", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			hlog.CtxErrorf(ctx, "读取对象失败 [%s]: %v", key, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		contentType := mime.TypeByExtension(path.Ext(key))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.Data(http.StatusOK, contentType, data)
+	}
+}
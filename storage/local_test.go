@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	l, err := NewLocal(&hdmodel.Storage{
+		Type:  TypeLocal,
+		Local: hdmodel.LocalStorage{Root: t.TempDir(), BaseURL: "http://files.example.com/files/", SignKey: "0123456789abcdef"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func readAll(t *testing.T, s Storage, key string) string {
+	t.Helper()
+	rc, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// requestURI 去掉签名地址的协议与主机，用于 ut.PerformRequest
+func requestURI(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.RequestURI()
+}
+
+func TestLocalPutGetDelete(t *testing.T) {
+	l := newTestLocal(t)
+	ctx := context.Background()
+	if err := l.Put(ctx, "a/b/c.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, l, "a/b/c.txt"); got != "hello" {
+		t.Errorf("Get = %q", got)
+	}
+	if err := l.Put(ctx, "a/b/c.txt", strings.NewReader("hi"), 5, ""); err == nil {
+		t.Error("长度不一致应返回错误")
+	}
+	if got := readAll(t, l, "a/b/c.txt"); got != "hello" {
+		t.Errorf("写入失败后原对象应保留, got %q", got)
+	}
+	if err := l.Delete(ctx, "a/b/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Get(ctx, "a/b/c.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除后 Get err = %v", err)
+	}
+	if err := l.Delete(ctx, "a/b/c.txt"); err != nil {
+		t.Errorf("删除不存在的对象 err = %v", err)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b", "a\\b", "a/./b"} {
+		if err := l.Put(ctx, key, strings.NewReader(""), 0, ""); err == nil {
+			t.Errorf("key %q 应被拒绝", key)
+		}
+	}
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	l := newTestLocal(t)
+	now := time.Unix(1_700_000_000, 0)
+	l.now = func() time.Time { return now }
+	engine := route.NewEngine(config.NewOptions(nil))
+	engine.Any("/files/*key", l.Handler())
+
+	putURL, err := l.PresignPut(context.Background(), "tenants/t1/头像 1.png", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(putURL, "http://files.example.com/files/tenants/t1/%E5%A4%B4%E5%83%8F%201.png?") {
+		t.Errorf("PresignPut = %s", putURL)
+	}
+	body := "png-data"
+	w := ut.PerformRequest(engine, consts.MethodPut, requestURI(t, putURL), &ut.Body{Body: bytes.NewBufferString(body), Len: len(body)})
+	if w.Code != consts.StatusOK {
+		t.Fatalf("PUT status = %d", w.Code)
+	}
+	if got := readAll(t, l, "tenants/t1/头像 1.png"); got != body {
+		t.Errorf("PUT 写入 = %q", got)
+	}
+
+	getURL, err := l.URL(context.Background(), "tenants/t1/头像 1.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = ut.PerformRequest(engine, consts.MethodGet, requestURI(t, getURL), nil)
+	if w.Code != consts.StatusOK || w.Body.String() != body {
+		t.Fatalf("GET status = %d, body = %q", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// GET 签名不能用于 PUT
+	w = ut.PerformRequest(engine, consts.MethodPut, requestURI(t, getURL), &ut.Body{Body: bytes.NewBufferString("x"), Len: 1})
+	if w.Code != consts.StatusForbidden {
+		t.Errorf("用 GET 签名 PUT status = %d", w.Code)
+	}
+	// 篡改对象键
+	tampered := strings.Replace(requestURI(t, getURL), "/t1/", "/t2/", 1)
+	if w = ut.PerformRequest(engine, consts.MethodGet, tampered, nil); w.Code != consts.StatusForbidden {
+		t.Errorf("篡改对象键 status = %d", w.Code)
+	}
+	// 过期
+	now = now.Add(l.expiry + time.Second)
+	if w = ut.PerformRequest(engine, consts.MethodGet, requestURI(t, getURL), nil); w.Code != consts.StatusForbidden {
+		t.Errorf("过期 status = %d", w.Code)
+	}
+
+	if _, err := l.PresignGet(context.Background(), "a.txt", 8*24*time.Hour); err == nil {
+		t.Error("超过最长有效期应返回错误")
+	}
+}
+
+func TestLocalPublicURL(t *testing.T) {
+	l, err := NewLocal(&hdmodel.Storage{
+		Local:     hdmodel.LocalStorage{Root: t.TempDir(), SignKey: "0123456789abcdef"},
+		PublicURL: "https://cdn.example.com/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := l.URL(context.Background(), "tenants/t1/a b.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://cdn.example.com/tenants/t1/a%20b.png" {
+		t.Errorf("URL = %s", got)
+	}
+	if _, err := NewLocal(&hdmodel.Storage{Local: hdmodel.LocalStorage{Root: t.TempDir(), SignKey: "short"}}); err == nil {
+		t.Error("签名密钥过短应返回错误")
+	}
+}
+
+func TestForTenant(t *testing.T) {
+	l := newTestLocal(t)
+	ctx := context.Background()
+	s, err := ForTenant(l, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, "avatars/1.png", strings.NewReader("x"), 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, l, "tenants/t1/avatars/1.png"); got != "x" {
+		t.Errorf("租户对象 = %q", got)
+	}
+	if err := s.Put(ctx, "../t2/avatars/1.png", strings.NewReader("x"), 1, ""); err == nil {
+		t.Error("越过租户前缀的 key 应被拒绝")
+	}
+	if _, err := ForTenant(l, "../t2"); err == nil {
+		t.Error("非法租户 ID 应返回错误")
+	}
+	if _, err := TenantKey("t1", ""); err == nil {
+		t.Error("空 key 应返回错误")
+	}
+}
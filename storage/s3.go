@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 S3 兼容存储（AWS S3、MinIO、OSS/COS 的 S3 接口等）
+type S3 struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+	expiry    time.Duration
+}
+
+// NewS3 创建 S3 兼容存储，使用配置中的静态 AccessKey/SecretKey；不会检查 Bucket 是否存在
+func NewS3(cfg *hdmodel.Storage) (*S3, error) {
+	c := cfg.S3
+	if c.Endpoint == "" || c.Bucket == "" {
+		return nil, errors.New("创建 S3 存储失败: 未配置 endpoint 或 bucket")
+	}
+	lookup := minio.BucketLookupAuto
+	if c.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(c.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(c.AccessKey, c.SecretKey, ""),
+		Secure:       c.UseSSL,
+		Region:       c.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 S3 存储失败: %w", err)
+	}
+	return &S3{client: client, bucket: c.Bucket, publicURL: cfg.PublicURL, expiry: urlExpiry(cfg)}, nil
+}
+
+// Client 返回底层的 minio 客户端
+func (s *S3) Client() *minio.Client {
+	return s.client
+}
+
+// Put 实现 Storage，size 为 -1 时按分片上传
+func (s *S3) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if _, err := CleanKey(key); err != nil {
+		return err
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, body, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("写入对象失败 [%s]: %w", key, err)
+	}
+	return nil
+}
+
+// Get 实现 Storage
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if _, err := CleanKey(key); err != nil {
+		return nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("读取对象失败 [%s]: %w", key, err)
+	}
+	// GetObject 不会发出请求，先 Stat 以便区分对象不存在
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("读取对象失败 [%s]: %w", key, err)
+	}
+	return obj, nil
+}
+
+// Delete 实现 Storage
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if _, err := CleanKey(key); err != nil {
+		return err
+	}
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("删除对象失败 [%s]: %w", key, err)
+	}
+	return nil
+}
+
+// URL 实现 Storage
+func (s *S3) URL(ctx context.Context, key string) (string, error) {
+	if _, err := CleanKey(key); err != nil {
+		return "", err
+	}
+	if s.publicURL != "" {
+		return publicURL(s.publicURL, key), nil
+	}
+	return s.PresignGet(ctx, key, s.expiry)
+}
+
+// PresignGet 实现 Storage
+func (s *S3) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if _, err := CleanKey(key); err != nil {
+		return "", err
+	}
+	if err := checkExpiry(expiry); err != nil {
+		return "", err
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiry, nil)
+	if err != nil {
+		return "", fmt.Errorf("生成签名地址失败 [%s]: %w", key, err)
+	}
+	return u.String(), nil
+}
+
+// PresignPut 实现 Storage
+func (s *S3) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if _, err := CleanKey(key); err != nil {
+		return "", err
+	}
+	if err := checkExpiry(expiry); err != nil {
+		return "", err
+	}
+	u, err := s.client.PresignedPutObject(ctx, s.bucket, key, expiry)
+	if err != nil {
+		return "", fmt.Errorf("生成签名地址失败 [%s]: %w", key, err)
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+// newTestS3 使用内存中的 S3 兼容服务
+func newTestS3(t *testing.T) *S3 {
+	t.Helper()
+	backend := s3mem.New()
+	if err := backend.CreateBucket("avatars"); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(gofakes3.New(backend).Server())
+	t.Cleanup(srv.Close)
+
+	s, err := New(&hdmodel.Storage{
+		Type: TypeS3,
+		S3: hdmodel.S3Storage{
+			Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+			Region:    "us-east-1",
+			Bucket:    "avatars",
+			AccessKey: "test",
+			SecretKey: "test-secret",
+			PathStyle: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.(*S3)
+}
+
+func TestS3PutGetDelete(t *testing.T) {
+	s := newTestS3(t)
+	ctx := context.Background()
+	if err := s.Put(ctx, "tenants/t1/a.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, s, "tenants/t1/a.txt"); got != "hello" {
+		t.Errorf("Get = %q", got)
+	}
+	if err := s.Delete(ctx, "tenants/t1/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "tenants/t1/a.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除后 Get err = %v", err)
+	}
+	if err := s.Put(ctx, "../a.txt", strings.NewReader(""), 0, ""); err == nil {
+		t.Error("非法 key 应被拒绝")
+	}
+}
+
+func TestS3PresignedURL(t *testing.T) {
+	s := newTestS3(t)
+	ctx := context.Background()
+
+	putURL, err := s.PresignPut(ctx, "tenants/t1/b.png", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPut, putURL, strings.NewReader("png-data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT status = %d", resp.StatusCode)
+	}
+
+	getURL, err := s.URL(ctx, "tenants/t1/b.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(getURL, "X-Amz-Expires=900") {
+		t.Errorf("默认有效期应为 15 分钟: %s", getURL)
+	}
+	resp, err = http.Get(getURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "png-data" {
+		t.Errorf("GET status = %d, body = %q", resp.StatusCode, body)
+	}
+
+	if _, err := s.PresignGet(ctx, "tenants/t1/b.png", 0); err == nil {
+		t.Error("有效期为 0 应返回错误")
+	}
+}
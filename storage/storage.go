@@ -0,0 +1,250 @@
+// Package storage 对象存储：本地文件系统与 S3 兼容存储，支持按租户划分的键与带有效期的签名地址
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/kvconfig"
+)
+
+const (
+	// TypeLocal 本地文件系统
+	TypeLocal = "local"
+	// TypeS3 S3 兼容存储
+	TypeS3 = "s3"
+
+	defaultURLExpiry = 15 * time.Minute
+	// MaxURLExpiry 签名地址的最长有效期，与 S3 SigV4 的上限一致
+	MaxURLExpiry = 7 * 24 * time.Hour
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Storage 对象存储，key 为以 / 分隔的相对路径
+type Storage interface {
+	// Put 写入对象，已存在时覆盖
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// URL 返回对象的访问地址：配置了 PublicURL 时为公开地址，否则为默认有效期的签名 GET 地址
+	URL(ctx context.Context, key string) (string, error)
+	// PresignGet 生成有效期为 expiry 的签名 GET 地址
+	PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// PresignPut 生成有效期为 expiry 的签名 PUT 地址，供客户端直传
+	PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// New 根据配置创建对象存储
+func New(cfg *hdmodel.Storage) (Storage, error) {
+	switch cfg.Type {
+	case TypeLocal:
+		return NewLocal(cfg)
+	case TypeS3:
+		return NewS3(cfg)
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %q", cfg.Type)
+	}
+}
+
+// CleanKey 校验对象键：不能为空、不能以 / 开头、不能包含空段、. 或 .. 段与反斜杠
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.ContainsAny(key, "\\\x00") {
+		return "", fmt.Errorf("对象键不合法: %q", key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", fmt.Errorf("对象键不合法: %q", key)
+		}
+	}
+	return key, nil
+}
+
+// TenantKey 返回租户隔离的对象键 tenants/<租户>/<key>
+func TenantKey(tenantID, key string) (string, error) {
+	if !tenantIDPattern.MatchString(tenantID) {
+		return "", fmt.Errorf("租户 ID 不合法: %q", tenantID)
+	}
+	if _, err := CleanKey(key); err != nil {
+		return "", err
+	}
+	return "tenants/" + tenantID + "/" + key, nil
+}
+
+// ForTenant 返回只能访问租户前缀下对象的存储，所有键自动加上 tenants/<租户>/ 前缀
+func ForTenant(s Storage, tenantID string) (Storage, error) {
+	if !tenantIDPattern.MatchString(tenantID) {
+		return nil, fmt.Errorf("租户 ID 不合法: %q", tenantID)
+	}
+	return &tenantStorage{s: s, tenantID: tenantID}, nil
+}
+
+type tenantStorage struct {
+	s        Storage
+	tenantID string
+}
+
+func (t *tenantStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	k, err := TenantKey(t.tenantID, key)
+	if err != nil {
+		return err
+	}
+	return t.s.Put(ctx, k, body, size, contentType)
+}
+
+func (t *tenantStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	k, err := TenantKey(t.tenantID, key)
+	if err != nil {
+		return nil, err
+	}
+	return t.s.Get(ctx, k)
+}
+
+func (t *tenantStorage) Delete(ctx context.Context, key string) error {
+	k, err := TenantKey(t.tenantID, key)
+	if err != nil {
+		return err
+	}
+	return t.s.Delete(ctx, k)
+}
+
+func (t *tenantStorage) URL(ctx context.Context, key string) (string, error) {
+	k, err := TenantKey(t.tenantID, key)
+	if err != nil {
+		return "", err
+	}
+	return t.s.URL(ctx, k)
+}
+
+func (t *tenantStorage) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	k, err := TenantKey(t.tenantID, key)
+	if err != nil {
+		return "", err
+	}
+	return t.s.PresignGet(ctx, k, expiry)
+}
+
+func (t *tenantStorage) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	k, err := TenantKey(t.tenantID, key)
+	if err != nil {
+		return "", err
+	}
+	return t.s.PresignPut(ctx, k, expiry)
+}
+
+// Watched 从配置中心读取存储配置，配置变化（如轮换密钥）时重建存储
+type Watched struct {
+	current atomic.Pointer[storageHolder]
+}
+
+type storageHolder struct {
+	s Storage
+}
+
+// NewWatched 从配置中心的 storage 配置创建存储，并在配置变化时热更新；新配置无效时保留旧的存储
+func NewWatched(f *kvconfig.ConfigFactory, group string) (*Watched, error) {
+	cfg, err := kvconfig.GetYamlConfig[hdmodel.Storage](f, kvconfig.StorageDataId, group)
+	if err != nil {
+		return nil, fmt.Errorf("读取存储配置失败: %w", err)
+	}
+	s, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watched{}
+	w.Update(s)
+	err = kvconfig.WatchYamlConfig(f, kvconfig.StorageDataId, group, func(cfg *hdmodel.Storage) {
+		s, err := New(cfg)
+		if err != nil {
+			hlog.Errorf("存储配置无效，继续使用旧配置 [group: %s]: %v", group, err)
+			return
+		}
+		w.Update(s)
+		hlog.Infof("存储配置已更新 [group: %s, type: %s]", group, cfg.Type)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("监听存储配置失败: %w", err)
+	}
+	return w, nil
+}
+
+// Update 替换当前使用的存储
+func (w *Watched) Update(s Storage) {
+	w.current.Store(&storageHolder{s: s})
+}
+
+// Current 返回当前使用的存储
+func (w *Watched) Current() Storage {
+	return w.current.Load().s
+}
+
+// Put 实现 Storage
+func (w *Watched) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	return w.Current().Put(ctx, key, body, size, contentType)
+}
+
+// Get 实现 Storage
+func (w *Watched) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return w.Current().Get(ctx, key)
+}
+
+// Delete 实现 Storage
+func (w *Watched) Delete(ctx context.Context, key string) error {
+	return w.Current().Delete(ctx, key)
+}
+
+// URL 实现 Storage
+func (w *Watched) URL(ctx context.Context, key string) (string, error) {
+	return w.Current().URL(ctx, key)
+}
+
+// PresignGet 实现 Storage
+func (w *Watched) PresignGet(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return w.Current().PresignGet(ctx, key, expiry)
+}
+
+// PresignPut 实现 Storage
+func (w *Watched) PresignPut(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return w.Current().PresignPut(ctx, key, expiry)
+}
+
+func urlExpiry(cfg *hdmodel.Storage) time.Duration {
+	if cfg.URLExpiry <= 0 {
+		return defaultURLExpiry
+	}
+	return min(cfg.URLExpiry, MaxURLExpiry)
+}
+
+func checkExpiry(expiry time.Duration) error {
+	if expiry < time.Second || expiry > MaxURLExpiry {
+		return fmt.Errorf("签名地址有效期需在 1 秒到 7 天之间: %v", expiry)
+	}
+	return nil
+}
+
+// publicURL 拼接公开访问地址，路径段按 URL 编码
+func publicURL(base, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + escapeKey(key)
+}
+
+func escapeKey(key string) string {
+	segs := strings.Split(key, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
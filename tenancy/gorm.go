@@ -0,0 +1,282 @@
+package tenancy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// GormPlugin 返回 GORM 插件：查询、更新、删除时追加隔离条件，创建时填充隔离列
+//
+//	db.Use(enforcer.GormPlugin())
+//
+// 原生 SQL（db.Raw、db.Exec）按 Rewrite 的规则改写，只识别 TenantTables/MerchantTables 中列出的表
+func (e *Enforcer) GormPlugin() gorm.Plugin {
+	return &gormPlugin{e: e}
+}
+
+type gormPlugin struct {
+	e *Enforcer
+}
+
+func (p *gormPlugin) Name() string {
+	return "hertzcommon:tenancy"
+}
+
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("tenancy:query", p.where("SELECT")); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("tenancy:row", p.where("SELECT")); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenancy:update", p.where("UPDATE")); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("tenancy:delete", p.where("DELETE")); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("gorm:raw").Register("tenancy:raw", p.rewriteRaw); err != nil {
+		return err
+	}
+	return cb.Create().Before("gorm:create").Register("tenancy:create", p.fill)
+}
+
+// rewriteRaw 改写已经生成的原生 SQL，无法改写时拒绝执行
+func (p *gormPlugin) rewriteRaw(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.SQL.Len() == 0 {
+		return
+	}
+	query, vars, err := p.e.Rewrite(stmt.Context, stmt.SQL.String(), stmt.Vars...)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	stmt.SQL.Reset()
+	stmt.SQL.WriteString(query)
+	stmt.Vars = vars
+}
+
+// scoped 判断表是否需要隔离：在配置的表中，或模型中有对应的列
+func (p *gormPlugin) scoped(stmt *gorm.Statement) (tenant, merchant bool) {
+	table := strings.ToLower(stmt.Table)
+	if table == "" || p.e.excludeTables[table] {
+		return false, false
+	}
+	tenant = p.e.tenantTables[table] || hasColumn(stmt.Schema, p.e.opts.TenantColumn)
+	merchant = p.e.merchantTables[table] || hasColumn(stmt.Schema, p.e.opts.MerchantColumn)
+	return tenant, merchant
+}
+
+func hasColumn(s *schema.Schema, column string) bool {
+	if s == nil {
+		return false
+	}
+	_, ok := s.FieldsByDBName[column]
+	return ok
+}
+
+func (p *gormPlugin) where(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		if db.Error != nil {
+			return
+		}
+		// 已经生成 SQL 的是原生语句
+		if stmt.SQL.Len() > 0 {
+			p.rewriteRaw(db)
+			return
+		}
+		tenant, merchant := p.scoped(stmt)
+		if !tenant && !merchant {
+			return
+		}
+		// 没有条件的更新与删除交给 GORM 返回 ErrMissingWhereClause，隔离条件不能让它变成租户内的全表操作
+		if op != "SELECT" && !db.AllowGlobalUpdate && !hasConditions(stmt) {
+			return
+		}
+		preds, err := p.e.scope(stmt.Context, stmt.Table, op, "", tenant, merchant)
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		if len(preds) == 0 {
+			return
+		}
+		if op == "UPDATE" {
+			if err := checkAssignments(stmt, preds); err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+		exprs := make([]clause.Expression, len(preds))
+		for i, pred := range preds {
+			exprs[i] = clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: pred.column}, Value: pred.value}
+		}
+		stmt.AddClause(clause.Where{Exprs: exprs})
+	}
+}
+
+// hasConditions 判断更新或删除是否带有条件：WHERE 子句或模型的主键值
+func hasConditions(stmt *gorm.Statement) bool {
+	if _, ok := stmt.Clauses["WHERE"]; ok {
+		return true
+	}
+	if stmt.Schema == nil || len(stmt.Schema.PrimaryFields) == 0 {
+		return false
+	}
+	if _, values := schema.GetIdentityFieldValuesMap(stmt.Context, stmt.ReflectValue, stmt.Schema.PrimaryFields); len(values) > 0 {
+		return true
+	}
+	if stmt.Model != nil {
+		if _, values := schema.GetIdentityFieldValuesMap(stmt.Context, reflect.ValueOf(stmt.Model), stmt.Schema.PrimaryFields); len(values) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAssignments 禁止把记录改到其他租户或商户下
+func checkAssignments(stmt *gorm.Statement, preds []predicate) error {
+	switch dest := stmt.Dest.(type) {
+	case map[string]interface{}:
+		for _, pred := range preds {
+			if err := checkMap(stmt.Schema, dest, pred); err != nil {
+				return err
+			}
+		}
+	default:
+		if stmt.Schema == nil {
+			return nil
+		}
+		rv := reflect.Indirect(reflect.ValueOf(stmt.Dest))
+		if rv.Kind() != reflect.Struct || rv.Type() != stmt.Schema.ModelType {
+			return nil
+		}
+		for _, pred := range preds {
+			field := stmt.Schema.LookUpField(pred.column)
+			if field == nil {
+				continue
+			}
+			if v, zero := field.ValueOf(stmt.Context, rv); !zero && fmt.Sprint(v) != pred.value {
+				return fmt.Errorf("%w [%s: %v]", ErrCrossTenant, pred.column, v)
+			}
+		}
+	}
+	return nil
+}
+
+// checkMap 检查 map 中的隔离列与上下文一致，键可以是列名或字段名
+func checkMap(s *schema.Schema, m map[string]interface{}, pred predicate) error {
+	for k, v := range m {
+		if k != pred.column && (s == nil || s.LookUpField(k) == nil || s.LookUpField(k).DBName != pred.column) {
+			continue
+		}
+		if fmt.Sprint(v) != pred.value {
+			return fmt.Errorf("%w [%s: %v]", ErrCrossTenant, pred.column, v)
+		}
+	}
+	return nil
+}
+
+// fill 创建时填充隔离列，已经填写了其他租户或商户时返回 ErrCrossTenant；
+// 冲突时更新的 upsert（OnConflict、Save 更新不到行后的回退）会覆盖其他租户的行，返回 ErrUnsupported
+func (p *gormPlugin) fill(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil {
+		return
+	}
+	if stmt.SQL.Len() > 0 {
+		p.rewriteRaw(db)
+		return
+	}
+	tenant, merchant := p.scoped(stmt)
+	if !tenant && !merchant {
+		return
+	}
+	preds, err := p.e.scope(stmt.Context, stmt.Table, "INSERT", "", tenant, merchant)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	if len(preds) > 0 && upserts(stmt) {
+		_ = db.AddError(fmt.Errorf("%w: 冲突时会覆盖其他租户的行 [table: %s]", ErrUnsupported, stmt.Table))
+		return
+	}
+	for _, pred := range preds {
+		if err := fillColumn(stmt, pred); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+	}
+}
+
+// upserts 判断创建是否带有冲突时更新的 OnConflict，DoNothing 不修改已有的行
+func upserts(stmt *gorm.Statement) bool {
+	c, ok := stmt.Clauses[clause.OnConflict{}.Name()]
+	if !ok {
+		return false
+	}
+	oc, ok := c.Expression.(clause.OnConflict)
+	return ok && !oc.DoNothing && (oc.UpdateAll || len(oc.DoUpdates) > 0)
+}
+
+func fillColumn(stmt *gorm.Statement, pred predicate) error {
+	switch dest := stmt.Dest.(type) {
+	case map[string]interface{}:
+		return fillMap(stmt.Schema, dest, pred)
+	case []map[string]interface{}:
+		for _, m := range dest {
+			if err := fillMap(stmt.Schema, m, pred); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if stmt.Schema == nil {
+		return fmt.Errorf("隔离表 %s 的创建需要使用模型或 map", stmt.Table)
+	}
+	field := stmt.Schema.LookUpField(pred.column)
+	if field == nil {
+		return fmt.Errorf("隔离表 %s 的模型缺少 %s 字段", stmt.Table, pred.column)
+	}
+	rv := stmt.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := fillField(stmt, field, reflect.Indirect(rv.Index(i)), pred); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return fillField(stmt, field, rv, pred)
+	}
+	return nil
+}
+
+func fillField(stmt *gorm.Statement, field *schema.Field, rv reflect.Value, pred predicate) error {
+	v, zero := field.ValueOf(stmt.Context, rv)
+	if zero {
+		return field.Set(stmt.Context, rv, pred.value)
+	}
+	if fmt.Sprint(v) != pred.value {
+		return fmt.Errorf("%w [%s: %v]", ErrCrossTenant, pred.column, v)
+	}
+	return nil
+}
+
+func fillMap(s *schema.Schema, m map[string]interface{}, pred predicate) error {
+	for k := range m {
+		if k == pred.column || (s != nil && s.LookUpField(k) != nil && s.LookUpField(k).DBName == pred.column) {
+			return checkMap(s, m, pred)
+		}
+	}
+	m[pred.column] = pred.value
+	return nil
+}
@@ -0,0 +1,230 @@
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+type order struct {
+	ID         int64
+	TenantID   string
+	MerchantID string
+	Amount     int64
+}
+
+type user struct {
+	ID   int64
+	Name string
+}
+
+func newTestGorm(t *testing.T, auditor Auditor) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(&Options{TenantTables: []string{"orders"}, Auditor: auditor, Registerer: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(e.GormPlugin()); err != nil {
+		t.Fatal(err)
+	}
+	return db, mock
+}
+
+func TestGormQuery(t *testing.T) {
+	db, mock := newTestGorm(t, nil)
+	ctx := ctxx.WithMerchantID(ctxx.WithTenantID(context.Background(), "t1"), "m1")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `orders` WHERE amount > ? AND `orders`.`tenant_id` = ? AND `orders`.`merchant_id` = ?")).
+		WithArgs(10, "t1", "m1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "merchant_id", "amount"}).AddRow(1, "t1", "m1", 20))
+	var orders []order
+	if err := db.WithContext(ctx).Where("amount > ?", 10).Find(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 {
+		t.Errorf("orders = %+v", orders)
+	}
+
+	// 没有隔离列的表不受影响
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT * FROM `users` WHERE `users`.`id` = ? ORDER BY `users`.`id` LIMIT ?")).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "a"))
+	var u user
+	if err := db.WithContext(context.Background()).First(&u, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.WithContext(context.Background()).Find(&orders).Error; !errors.Is(err, ErrNoTenant) {
+		t.Errorf("没有租户时 err = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGormCreate(t *testing.T) {
+	db, mock := newTestGorm(t, nil)
+	ctx := ctxx.WithTenantID(context.Background(), "t1")
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `orders` (`tenant_id`,`merchant_id`,`amount`) VALUES (?,?,?),(?,?,?)")).
+		WithArgs("t1", "", 10, "t1", "", 20).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	orders := []order{{Amount: 10}, {Amount: 20}}
+	if err := db.WithContext(ctx).Create(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+	if orders[1].TenantID != "t1" {
+		t.Errorf("未填充租户: %+v", orders)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `orders` (`amount`,`tenant_id`) VALUES (?,?)")).
+		WithArgs(30, "t1").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	if err := db.WithContext(ctx).Model(&order{}).Create(map[string]interface{}{"amount": 30}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.WithContext(ctx).Create(&order{TenantID: "t2"}).Error; !errors.Is(err, ErrCrossTenant) {
+		t.Errorf("写入其他租户 err = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGormUpdateDelete(t *testing.T) {
+	db, mock := newTestGorm(t, nil)
+	ctx := ctxx.WithTenantID(context.Background(), "t1")
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `orders` SET `amount`=? WHERE `orders`.`tenant_id` = ? AND `id` = ?")).
+		WithArgs(50, "t1", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := db.WithContext(ctx).Model(&order{ID: 1}).Update("amount", 50).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `orders` WHERE amount = ? AND `orders`.`tenant_id` = ?")).
+		WithArgs(0, "t1").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	if err := db.WithContext(ctx).Where("amount = ?", 0).Delete(&order{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 隔离条件不能绕过 GORM 对全表更新的保护
+	if err := db.WithContext(ctx).Model(&order{}).Update("amount", 0).Error; !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Errorf("全表更新 err = %v", err)
+	}
+	if err := db.WithContext(ctx).Model(&order{ID: 1}).Update("tenant_id", "t2").Error; !errors.Is(err, ErrCrossTenant) {
+		t.Errorf("修改租户 err = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGormBypass(t *testing.T) {
+	auditor := &recordAuditor{}
+	db, mock := newTestGorm(t, auditor)
+	ctx := ctxx.WithUserID(ctxx.WithTenantIsolation(context.Background(), false), "admin")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM `orders`")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+	var n int64
+	if err := db.WithContext(ctx).Model(&order{}).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 {
+		t.Errorf("count = %d", n)
+	}
+	if len(auditor.events) != 1 || auditor.events[0].UserID != "admin" || auditor.events[0].Table != "orders" {
+		t.Errorf("审计事件 = %+v", auditor.events)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGormRaw(t *testing.T) {
+	db, mock := newTestGorm(t, nil)
+	ctx := ctxx.WithTenantID(context.Background(), "t1")
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM orders WHERE (id IN (?,?)) AND orders.`tenant_id` = ?")).
+		WithArgs(1, 2, "t1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	if err := db.WithContext(ctx).Exec("DELETE FROM orders WHERE id IN ?", []int{1, 2}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT amount FROM orders WHERE orders.`tenant_id` = ? # all")).
+		WithArgs("t1").
+		WillReturnRows(sqlmock.NewRows([]string{"amount"}).AddRow(10))
+	var amounts []int64
+	if err := db.WithContext(ctx).Raw("SELECT amount FROM orders # all").Scan(&amounts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(amounts) != 1 {
+		t.Errorf("amounts = %v", amounts)
+	}
+
+	if err := db.WithContext(context.Background()).Exec("DELETE FROM orders").Error; !errors.Is(err, ErrNoTenant) {
+		t.Errorf("没有租户时 err = %v", err)
+	}
+	if err := db.WithContext(ctx).Raw("SELECT * FROM orders o JOIN users u ON u.id = o.user_id").Scan(&[]order{}).Error; !errors.Is(err, ErrUnsupported) {
+		t.Errorf("JOIN err = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGormUpsert(t *testing.T) {
+	db, mock := newTestGorm(t, nil)
+	ctx := ctxx.WithTenantID(context.Background(), "t1")
+
+	if err := db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&order{ID: 7}).Error; !errors.Is(err, ErrUnsupported) {
+		t.Errorf("冲突时更新 err = %v", err)
+	}
+	if err := db.WithContext(ctx).Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"amount"})}).Create(&order{ID: 7}).Error; !errors.Is(err, ErrUnsupported) {
+		t.Errorf("冲突时更新指定列 err = %v", err)
+	}
+
+	// 其他租户的主键：限定租户的 UPDATE 更新不到行，GORM 回退到 upsert，不能覆盖其他租户的行
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE `orders` SET `tenant_id`=?,`merchant_id`=?,`amount`=? WHERE `orders`.`tenant_id` = ? AND `id` = ?")).
+		WithArgs("", "", 10, "t1", 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := db.WithContext(ctx).Save(&order{ID: 7, Amount: 10}).Error; !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Save 其他租户的行 err = %v", err)
+	}
+
+	// 关闭隔离后审计放行
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `orders` (`tenant_id`,`merchant_id`,`amount`,`id`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE")).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	bypass := ctxx.WithTenantIsolation(ctx, false)
+	if err := db.WithContext(bypass).Clauses(clause.OnConflict{UpdateAll: true}).Create(&order{ID: 7, TenantID: "t2"}).Error; err != nil {
+		t.Errorf("关闭隔离后 upsert err = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
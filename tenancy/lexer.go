@@ -0,0 +1,133 @@
+package tenancy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokWord   tokenKind = iota // 关键字或未加引号的标识符
+	tokQuoted                  // 反引号标识符
+	tokString                  // 字符串
+	tokNumber
+	tokParam // ? 占位符
+	tokPunct
+)
+
+// token SQL 词法单元，pos/end 为在原语句中的字节位置
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	end  int
+}
+
+func (t token) isWord(w string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, w)
+}
+
+func (t token) isPunct(p string) bool {
+	return t.kind == tokPunct && t.text == p
+}
+
+func (t token) isName() bool {
+	return t.kind == tokWord || t.kind == tokQuoted
+}
+
+// name 去掉反引号后的标识符
+func (t token) name() string {
+	if t.kind == tokQuoted {
+		return strings.ReplaceAll(t.text[1:len(t.text)-1], "``", "`")
+	}
+	return t.text
+}
+
+var (
+	errUnterminated = errors.New("SQL 语句中有未闭合的字符串、标识符或注释")
+	// errComment MySQL 会执行 /*! */ 中的内容、/*+ */ 是优化器提示，-- 后跟制表符等控制字符也是注释，
+	// 与词法分析的理解不一致时改写结果不可靠，一律拒绝
+	errComment = fmt.Errorf("%w: 不支持 /*! */、/*+ */ 注释与 -- 后跟空格、换行以外的注释", ErrUnsupported)
+)
+
+// lex 把 MySQL 语句切分为词法单元，跳过空白与注释
+func lex(q string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '-' && strings.HasPrefix(q[i:], "--") && i+2 < len(q) && q[i+2] < ' ' && q[i+2] != '\n':
+			return nil, errComment
+		case c == '#' || c == '-' && strings.HasPrefix(q[i:], "-- ") || strings.HasPrefix(q[i:], "--\n") || q[i:] == "--":
+			j := strings.IndexByte(q[i:], '\n')
+			if j < 0 {
+				i = len(q)
+			} else {
+				i += j + 1
+			}
+		case c == '/' && (strings.HasPrefix(q[i:], "/*!") || strings.HasPrefix(q[i:], "/*+")):
+			return nil, errComment
+		case c == '/' && strings.HasPrefix(q[i:], "/*"):
+			j := strings.Index(q[i+2:], "*/")
+			if j < 0 {
+				return nil, errUnterminated
+			}
+			i += j + 4
+		case c == '\'' || c == '"' || c == '`':
+			j := i + 1
+			for ; j < len(q); j++ {
+				if q[j] == '\\' && c != '`' {
+					j++
+					continue
+				}
+				if q[j] == c {
+					// 连续两个引号表示引号本身
+					if j+1 < len(q) && q[j+1] == c {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(q) {
+				return nil, errUnterminated
+			}
+			kind := tokString
+			if c == '`' {
+				kind = tokQuoted
+			}
+			toks = append(toks, token{kind: kind, text: q[i : j+1], pos: i, end: j + 1})
+			i = j + 1
+		case c == '?':
+			toks = append(toks, token{kind: tokParam, text: "?", pos: i, end: i + 1})
+			i++
+		case isWordByte(c):
+			j := i
+			for j < len(q) && (isWordByte(q[j]) || q[j] >= '0' && q[j] <= '9') {
+				j++
+			}
+			toks = append(toks, token{kind: tokWord, text: q[i:j], pos: i, end: j})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(q) && (isWordByte(q[j]) || q[j] >= '0' && q[j] <= '9' || q[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{kind: tokNumber, text: q[i:j], pos: i, end: j})
+			i = j
+		default:
+			toks = append(toks, token{kind: tokPunct, text: q[i : i+1], pos: i, end: i + 1})
+			i++
+		}
+	}
+	return toks, nil
+}
+
+// isWordByte 标识符字符，非 ASCII 字节按标识符处理
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || c >= 0x80
+}
@@ -0,0 +1,547 @@
+package tenancy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Execer 可执行写入语句的对象，*sql.DB、*sql.Tx、*sql.Conn 均满足
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Queryer 可执行查询的对象，*sql.DB、*sql.Tx、*sql.Conn 均满足
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// ExecContext 改写语句后执行
+func (e *Enforcer) ExecContext(ctx context.Context, db Execer, query string, args ...any) (sql.Result, error) {
+	query, args, err := e.Rewrite(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return db.ExecContext(ctx, query, args...)
+}
+
+// QueryContext 改写语句后查询
+func (e *Enforcer) QueryContext(ctx context.Context, db Queryer, query string, args ...any) (*sql.Rows, error) {
+	query, args, err := e.Rewrite(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return db.QueryContext(ctx, query, args...)
+}
+
+// Rewrite 为访问 TenantTables/MerchantTables 中的表的语句追加隔离条件，参数使用 ? 占位符：
+//   - SELECT/UPDATE/DELETE 单表语句追加 WHERE 条件，已有条件时用括号包住再 AND
+//   - INSERT ... (列) VALUES (...) 追加隔离列与参数，已经写了隔离列时检查与上下文一致
+//   - UPDATE 的 SET 不能把隔离列改成其他值
+//
+// REPLACE 与 ON DUPLICATE KEY UPDATE 冲突时会删除或修改其他租户的行，写隔离表时返回 ErrUnsupported；
+// 访问隔离表的 JOIN、子查询、UNION、INSERT ... SELECT 等语句返回 ErrUnsupported；
+// 含有 /*! */、/*+ */ 注释的语句，以及以其他关键字或括号开头、访问了隔离表的语句同样返回 ErrUnsupported；
+// 不访问隔离表的语句原样返回
+func (e *Enforcer) Rewrite(ctx context.Context, query string, args ...any) (string, []any, error) {
+	toks, err := lex(query)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(toks) == 0 {
+		return query, args, nil
+	}
+	st := &statement{query: query, toks: toks, args: args}
+	switch op := strings.ToUpper(toks[0].text); op {
+	case "SELECT", "UPDATE", "DELETE", "INSERT", "REPLACE":
+		st.op = op
+	case "WITH":
+		st.op = "SELECT"
+		return e.rewriteComplex(ctx, st)
+	default:
+		// (SELECT ...)、TABLE t 等其他语句同样不能绕过隔离表
+		st.op = "OTHER"
+		return e.rewriteComplex(ctx, st)
+	}
+	if !st.parse() {
+		return e.rewriteComplex(ctx, st)
+	}
+
+	tenant, merchant := e.sqlScoped(st.table)
+	if !tenant && !merchant {
+		return query, args, nil
+	}
+	preds, err := e.scope(ctx, st.table, st.auditOp(), query, tenant, merchant)
+	if err != nil || len(preds) == 0 {
+		return query, args, err
+	}
+	if st.op == "REPLACE" || st.dup >= 0 {
+		return "", nil, fmt.Errorf("%w: 冲突时会覆盖其他租户的行 [table: %s]: %s", ErrUnsupported, st.table, truncate(query))
+	}
+	if err := st.checkAssignments(preds); err != nil {
+		return "", nil, err
+	}
+	if st.op == "INSERT" {
+		return st.rewriteInsert(preds)
+	}
+	q, a := st.rewriteWhere(preds)
+	return q, a, nil
+}
+
+func (e *Enforcer) sqlScoped(table string) (tenant, merchant bool) {
+	table = strings.ToLower(table)
+	if e.excludeTables[table] {
+		return false, false
+	}
+	return e.tenantTables[table], e.merchantTables[table]
+}
+
+// rewriteComplex 无法改写的语句：访问了需要追加条件的隔离表时拒绝，关闭了隔离时审计后放行
+func (e *Enforcer) rewriteComplex(ctx context.Context, st *statement) (string, []any, error) {
+	seen := map[string]bool{}
+	for _, t := range st.toks {
+		if t.kind != tokWord && t.kind != tokQuoted {
+			continue
+		}
+		name := t.name()
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		tenant, merchant := e.sqlScoped(name)
+		if !tenant && !merchant {
+			continue
+		}
+		preds, err := e.scope(ctx, name, st.auditOp(), st.query, tenant, merchant)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(preds) > 0 {
+			return "", nil, fmt.Errorf("%w [table: %s]: %s", ErrUnsupported, name, truncate(st.query))
+		}
+	}
+	return st.query, st.args, nil
+}
+
+func truncate(s string) string {
+	if len(s) > 200 {
+		return s[:200] + "..."
+	}
+	return s
+}
+
+// statement 解析后的单表语句
+type statement struct {
+	query string
+	toks  []token
+	args  []any
+	op    string
+
+	table string
+	// qualifier 条件中限定列的表名或别名，保留原文的引号
+	qualifier string
+	// where WHERE 关键字的下标，-1 表示没有
+	where int
+	// end WHERE 条件结束的位置：GROUP BY、ORDER BY、LIMIT 等之前最后一个词法单元的结尾
+	end int
+	// set UPDATE 的 SET 关键字下标
+	set int
+	// columns INSERT 的列名列表括号的下标
+	columnsOpen, columnsClose int
+	// rows INSERT 每行 VALUES 的括号下标
+	rows [][2]int
+	// dup ON DUPLICATE KEY UPDATE 中 UPDATE 的下标，-1 表示没有
+	dup int
+}
+
+func (st *statement) auditOp() string {
+	if st.op == "REPLACE" {
+		return "INSERT"
+	}
+	return st.op
+}
+
+// clauseEnd WHERE 条件之后可能出现的关键字
+var clauseEnd = map[string]bool{
+	"GROUP": true, "HAVING": true, "ORDER": true, "LIMIT": true, "FOR": true, "LOCK": true, "WINDOW": true,
+}
+
+// modifiers 表名之前可能出现的修饰词
+var modifiers = map[string]bool{
+	"LOW_PRIORITY": true, "DELAYED": true, "HIGH_PRIORITY": true, "IGNORE": true, "QUICK": true, "INTO": true,
+}
+
+// parse 解析单表语句，不支持的结构返回 false
+func (st *statement) parse() bool {
+	st.where, st.set, st.dup = -1, -1, -1
+	for _, t := range st.toks {
+		if t.isWord("UNION") || t.isWord("JOIN") || t.isWord("SELECT") && t.pos > 0 {
+			return false
+		}
+	}
+	i := 1
+	switch st.op {
+	case "SELECT":
+		i = st.find(0, "FROM")
+		if i < 0 {
+			// 没有 FROM 的 SELECT 不访问表
+			st.table = ""
+			return true
+		}
+		i++
+	case "DELETE":
+		for i < len(st.toks) && st.toks[i].kind == tokWord && modifiers[strings.ToUpper(st.toks[i].text)] {
+			i++
+		}
+		// 多表删除 DELETE t1 FROM t1 ...
+		if i >= len(st.toks) || !st.toks[i].isWord("FROM") {
+			return false
+		}
+		i++
+	default:
+		for i < len(st.toks) && st.toks[i].kind == tokWord && modifiers[strings.ToUpper(st.toks[i].text)] {
+			i++
+		}
+	}
+
+	i, ok := st.parseTable(i)
+	if !ok {
+		return false
+	}
+	if st.op == "INSERT" || st.op == "REPLACE" {
+		return st.parseInsert(i)
+	}
+	if st.op == "UPDATE" {
+		if i >= len(st.toks) || !st.toks[i].isWord("SET") {
+			return false
+		}
+		st.set = i
+		i++
+	}
+
+	// 条件追加在最后一个词法单元之后，不能落进结尾的注释里
+	st.end = st.toks[len(st.toks)-1].end
+	depth := 0
+	for ; i < len(st.toks); i++ {
+		t := st.toks[i]
+		switch {
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+		case depth > 0:
+		case t.isPunct(";"):
+			// 分号之后不能再有语句
+			st.end = st.toks[i-1].end
+			return i+1 == len(st.toks)
+		case t.isPunct(","):
+			// FROM a, b 形式的连接
+			if st.op != "UPDATE" || st.where >= 0 {
+				return false
+			}
+		case t.isWord("WHERE") && st.where < 0:
+			st.where = i
+		case t.kind == tokWord && clauseEnd[strings.ToUpper(t.text)]:
+			// 取前一个词法单元的结尾，两者之间的注释留在条件之后
+			st.end = st.toks[i-1].end
+			return true
+		case st.op != "UPDATE" && st.where < 0:
+			// 表名之后、WHERE 之前的其他内容，如 PARTITION、USE INDEX
+			return false
+		}
+	}
+	return true
+}
+
+// parseTable 解析表名与别名，返回下一个 token 的下标
+func (st *statement) parseTable(i int) (int, bool) {
+	if i >= len(st.toks) || !st.toks[i].isName() {
+		return i, false
+	}
+	st.table, st.qualifier = st.toks[i].name(), st.toks[i].text
+	i++
+	// 库名.表名
+	if i+1 < len(st.toks) && st.toks[i].isPunct(".") && st.toks[i+1].isName() {
+		st.table, st.qualifier = st.toks[i+1].name(), st.toks[i-1].text+"."+st.toks[i+1].text
+		i += 2
+	}
+	if st.op == "INSERT" || st.op == "REPLACE" {
+		return i, true
+	}
+	// 别名
+	if i < len(st.toks) && st.toks[i].isWord("AS") {
+		i++
+		if i >= len(st.toks) || !st.toks[i].isName() {
+			return i, false
+		}
+		st.qualifier = st.toks[i].text
+		i++
+	} else if i < len(st.toks) && st.toks[i].isName() && !isKeyword(st.toks[i]) {
+		st.qualifier = st.toks[i].text
+		i++
+	}
+	return i, true
+}
+
+func isKeyword(t token) bool {
+	if t.kind != tokWord {
+		return false
+	}
+	u := strings.ToUpper(t.text)
+	return u == "WHERE" || u == "SET" || clauseEnd[u] || u == "JOIN" || u == "INNER" || u == "LEFT" ||
+		u == "RIGHT" || u == "CROSS" || u == "STRAIGHT_JOIN" || u == "NATURAL" || u == "PARTITION" ||
+		u == "USE" || u == "FORCE" || u == "IGNORE" || u == "USING"
+}
+
+// parseInsert 解析 (列) VALUES (...), (...) [AS 别名] [ON DUPLICATE KEY UPDATE ...]
+func (st *statement) parseInsert(i int) bool {
+	if i >= len(st.toks) || !st.toks[i].isPunct("(") {
+		return false
+	}
+	st.columnsOpen = i
+	if st.columnsClose = st.matching(i); st.columnsClose < 0 {
+		return false
+	}
+	i = st.columnsClose + 1
+	if i >= len(st.toks) || !(st.toks[i].isWord("VALUES") || st.toks[i].isWord("VALUE")) {
+		return false
+	}
+	i++
+	for {
+		if i >= len(st.toks) || !st.toks[i].isPunct("(") {
+			return false
+		}
+		closeIdx := st.matching(i)
+		if closeIdx < 0 {
+			return false
+		}
+		st.rows = append(st.rows, [2]int{i, closeIdx})
+		i = closeIdx + 1
+		if i < len(st.toks) && st.toks[i].isPunct(",") {
+			i++
+			continue
+		}
+		break
+	}
+	for ; i < len(st.toks); i++ {
+		if st.toks[i].isWord("UPDATE") && i >= 3 && st.toks[i-1].isWord("KEY") && st.toks[i-2].isWord("DUPLICATE") {
+			st.dup = i
+		}
+	}
+	return true
+}
+
+// matching 返回与下标 i 的左括号匹配的右括号下标
+func (st *statement) matching(i int) int {
+	depth := 0
+	for j := i; j < len(st.toks); j++ {
+		switch {
+		case st.toks[j].isPunct("("):
+			depth++
+		case st.toks[j].isPunct(")"):
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// find 从下标 from 开始查找顶层关键字
+func (st *statement) find(from int, word string) int {
+	depth := 0
+	for i := from; i < len(st.toks); i++ {
+		switch {
+		case st.toks[i].isPunct("("):
+			depth++
+		case st.toks[i].isPunct(")"):
+			depth--
+		case depth == 0 && st.toks[i].isWord(word):
+			return i
+		}
+	}
+	return -1
+}
+
+// placeholdersBefore 位置 pos 之前的占位符个数
+func (st *statement) placeholdersBefore(pos int) int {
+	n := 0
+	for _, t := range st.toks {
+		if t.pos >= pos {
+			break
+		}
+		if t.kind == tokParam {
+			n++
+		}
+	}
+	return n
+}
+
+// checkAssignments 检查 UPDATE 的 SET 中的隔离列
+func (st *statement) checkAssignments(preds []predicate) error {
+	if st.op != "UPDATE" {
+		return nil
+	}
+	end := len(st.toks)
+	if st.where >= 0 {
+		end = st.where
+	}
+	for _, pred := range preds {
+		for i := st.set + 1; i < end; i++ {
+			t := st.toks[i]
+			if !t.isName() || !strings.EqualFold(t.name(), pred.column) || i+1 >= len(st.toks) || !st.toks[i+1].isPunct("=") {
+				continue
+			}
+			if err := st.checkValue(i+2, pred); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkValue 下标 i 处的值必须是单独的占位符且参数与上下文一致
+func (st *statement) checkValue(i int, pred predicate) error {
+	if i >= len(st.toks) || st.toks[i].kind != tokParam ||
+		(i+1 < len(st.toks) && !st.toks[i+1].isPunct(",") && !st.toks[i+1].isPunct(")") && !st.toks[i+1].isPunct(";") && !st.toks[i+1].isWord("WHERE") &&
+			!(st.toks[i+1].kind == tokWord && clauseEnd[strings.ToUpper(st.toks[i+1].text)])) {
+		return fmt.Errorf("%w: %s 只能使用占位符赋值", ErrUnsupported, pred.column)
+	}
+	n := st.placeholdersBefore(st.toks[i].pos)
+	if n >= len(st.args) {
+		return errors.New("语句的参数个数与占位符不一致")
+	}
+	if fmt.Sprint(st.args[n]) != pred.value {
+		return fmt.Errorf("%w [%s: %v]", ErrCrossTenant, pred.column, st.args[n])
+	}
+	return nil
+}
+
+// rewriteWhere 追加 WHERE 条件，参数插入到条件对应的位置
+func (st *statement) rewriteWhere(preds []predicate) (string, []any) {
+	conds := make([]string, len(preds))
+	values := make([]any, len(preds))
+	for i, pred := range preds {
+		conds[i] = st.qualifier + ".`" + pred.column + "` = ?"
+		values[i] = pred.value
+	}
+	cond := strings.Join(conds, " AND ")
+
+	var b strings.Builder
+	if st.where >= 0 {
+		w := st.toks[st.where]
+		b.WriteString(st.query[:w.end])
+		b.WriteString(" (")
+		b.WriteString(strings.TrimSpace(st.query[w.end:st.end]))
+		b.WriteString(") AND ")
+	} else {
+		b.WriteString(strings.TrimRight(st.query[:st.end], " \t\r\n"))
+		b.WriteString(" WHERE ")
+	}
+	b.WriteString(cond)
+	if rest := st.query[st.end:]; rest != "" {
+		b.WriteByte(' ')
+		b.WriteString(strings.TrimLeft(rest, " \t\r\n"))
+	}
+	return b.String(), insertArgs(st.args, st.placeholdersBefore(st.end), values)
+}
+
+// rewriteInsert 追加隔离列与每行的参数；已经写了隔离列时检查每行的值
+func (st *statement) rewriteInsert(preds []predicate) (string, []any, error) {
+	cols := st.columnNames()
+	type insertion struct {
+		pos    int
+		text   string
+		argIdx int
+		values []any
+	}
+	var missing []predicate
+	for _, pred := range preds {
+		idx := -1
+		for i, c := range cols {
+			if strings.EqualFold(c, pred.column) {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			missing = append(missing, pred)
+			continue
+		}
+		for _, row := range st.rows {
+			starts := st.elementStarts(row[0], row[1])
+			if idx >= len(starts) {
+				return "", nil, errors.New("VALUES 的值个数与列数不一致")
+			}
+			if err := st.checkValue(starts[idx], pred); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return st.query, st.args, nil
+	}
+
+	var colText, valText strings.Builder
+	values := make([]any, len(missing))
+	for i, pred := range missing {
+		colText.WriteString(", `" + pred.column + "`")
+		valText.WriteString(", ?")
+		values[i] = pred.value
+	}
+	ins := []insertion{{pos: st.toks[st.columnsClose].pos, text: colText.String()}}
+	for _, row := range st.rows {
+		pos := st.toks[row[1]].pos
+		ins = append(ins, insertion{pos: pos, text: valText.String(), argIdx: st.placeholdersBefore(pos), values: values})
+	}
+	q, args := st.query, st.args
+	// 从后往前插入，前面的位置与参数下标不受影响
+	for i := len(ins) - 1; i >= 0; i-- {
+		in := ins[i]
+		q = q[:in.pos] + in.text + q[in.pos:]
+		if in.values != nil {
+			args = insertArgs(args, in.argIdx, in.values)
+		}
+	}
+	return q, args, nil
+}
+
+// columnNames INSERT 列名列表
+func (st *statement) columnNames() []string {
+	var cols []string
+	for i := st.columnsOpen + 1; i < st.columnsClose; i++ {
+		if st.toks[i].isName() {
+			cols = append(cols, st.toks[i].name())
+		}
+	}
+	return cols
+}
+
+// elementStarts 括号内每个顶层元素第一个 token 的下标
+func (st *statement) elementStarts(open, closeIdx int) []int {
+	starts := []int{open + 1}
+	depth := 0
+	for i := open + 1; i < closeIdx; i++ {
+		switch {
+		case st.toks[i].isPunct("("):
+			depth++
+		case st.toks[i].isPunct(")"):
+			depth--
+		case depth == 0 && st.toks[i].isPunct(","):
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+func insertArgs(args []any, at int, values []any) []any {
+	if at > len(args) {
+		at = len(args)
+	}
+	out := make([]any, 0, len(args)+len(values))
+	out = append(out, args[:at]...)
+	out = append(out, values...)
+	return append(out, args[at:]...)
+}
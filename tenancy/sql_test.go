@@ -0,0 +1,243 @@
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// recordAuditor 记录审计事件
+type recordAuditor struct {
+	mu     sync.Mutex
+	events []BypassEvent
+}
+
+func (a *recordAuditor) Audit(ctx context.Context, ev *BypassEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, *ev)
+}
+
+func newTestEnforcer(t *testing.T) (*Enforcer, *recordAuditor, *prometheus.Registry) {
+	t.Helper()
+	auditor := &recordAuditor{}
+	reg := prometheus.NewRegistry()
+	e, err := New(&Options{
+		TenantTables:   []string{"orders", "order_items", "shops"},
+		MerchantTables: []string{"orders"},
+		Auditor:        auditor,
+		Registerer:     reg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, auditor, reg
+}
+
+func TestRewrite(t *testing.T) {
+	e, _, _ := newTestEnforcer(t)
+	ctx := ctxx.WithTenantID(context.Background(), "t1")
+	merchantCtx := ctxx.WithMerchantID(ctx, "m1")
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		query     string
+		args      []any
+		wantQuery string
+		wantArgs  []any
+	}{
+		{
+			name:      "无条件查询",
+			ctx:       ctx,
+			query:     "SELECT id, amount FROM orders",
+			wantQuery: "SELECT id, amount FROM orders WHERE orders.`tenant_id` = ?",
+			wantArgs:  []any{"t1"},
+		},
+		{
+			name:      "已有条件并带商户",
+			ctx:       merchantCtx,
+			query:     "SELECT * FROM `orders` o WHERE o.status = ? OR o.id = ? ORDER BY id LIMIT ?",
+			args:      []any{1, 2, 10},
+			wantQuery: "SELECT * FROM `orders` o WHERE (o.status = ? OR o.id = ?) AND o.`tenant_id` = ? AND o.`merchant_id` = ? ORDER BY id LIMIT ?",
+			wantArgs:  []any{1, 2, "t1", "m1", 10},
+		},
+		{
+			name:      "库名与分号",
+			ctx:       ctx,
+			query:     "select count(*) from shop.order_items as i where i.order_id in (?, ?) for update;",
+			args:      []any{1, 2},
+			wantQuery: "select count(*) from shop.order_items as i where (i.order_id in (?, ?)) AND i.`tenant_id` = ? for update;",
+			wantArgs:  []any{1, 2, "t1"},
+		},
+		{
+			name:      "更新",
+			ctx:       ctx,
+			query:     "UPDATE orders SET status = ?, note = 'a?b' WHERE id = ? LIMIT 1",
+			args:      []any{2, 7},
+			wantQuery: "UPDATE orders SET status = ?, note = 'a?b' WHERE (id = ?) AND orders.`tenant_id` = ? LIMIT 1",
+			wantArgs:  []any{2, 7, "t1"},
+		},
+		{
+			name:      "删除",
+			ctx:       ctx,
+			query:     "DELETE FROM shops -- 注释 ?\nWHERE id = ?",
+			args:      []any{3},
+			wantQuery: "DELETE FROM shops -- 注释 ?\nWHERE (id = ?) AND shops.`tenant_id` = ?",
+			wantArgs:  []any{3, "t1"},
+		},
+		{
+			name:      "插入多行",
+			ctx:       merchantCtx,
+			query:     "INSERT INTO orders (id, amount) VALUES (?, ?), (?, ?)",
+			args:      []any{1, 10, 2, 20},
+			wantQuery: "INSERT INTO orders (id, amount, `tenant_id`, `merchant_id`) VALUES (?, ?, ?, ?), (?, ?, ?, ?)",
+			wantArgs:  []any{1, 10, "t1", "m1", 2, 20, "t1", "m1"},
+		},
+		{
+			name:      "插入时已填写租户",
+			ctx:       ctx,
+			query:     "INSERT INTO shops (id, tenant_id) VALUES (?, ?)",
+			args:      []any{1, "t1"},
+			wantQuery: "INSERT INTO shops (id, tenant_id) VALUES (?, ?)",
+			wantArgs:  []any{1, "t1"},
+		},
+		{
+			name:      "结尾的井号注释",
+			ctx:       ctx,
+			query:     "DELETE FROM orders # purge",
+			wantQuery: "DELETE FROM orders WHERE orders.`tenant_id` = ? # purge",
+			wantArgs:  []any{"t1"},
+		},
+		{
+			name:      "结尾的双横线注释",
+			ctx:       ctx,
+			query:     "SELECT * FROM orders -- list all",
+			wantQuery: "SELECT * FROM orders WHERE orders.`tenant_id` = ? -- list all",
+			wantArgs:  []any{"t1"},
+		},
+		{
+			name:      "条件之后的注释",
+			ctx:       ctx,
+			query:     "SELECT * FROM orders WHERE id = ? -- x",
+			args:      []any{1},
+			wantQuery: "SELECT * FROM orders WHERE (id = ?) AND orders.`tenant_id` = ? -- x",
+			wantArgs:  []any{1, "t1"},
+		},
+		{
+			name:      "子句之前的注释",
+			ctx:       ctx,
+			query:     "SELECT * FROM orders -- c\nLIMIT 1",
+			wantQuery: "SELECT * FROM orders WHERE orders.`tenant_id` = ? -- c\nLIMIT 1",
+			wantArgs:  []any{"t1"},
+		},
+		{
+			name:      "非隔离表",
+			ctx:       context.Background(),
+			query:     "SELECT * FROM users u JOIN roles r ON r.id = u.role_id",
+			wantQuery: "SELECT * FROM users u JOIN roles r ON r.id = u.role_id",
+		},
+		{
+			name:      "非 DML 语句",
+			ctx:       context.Background(),
+			query:     "SHOW TABLES",
+			wantQuery: "SHOW TABLES",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, args, err := e.Rewrite(tt.ctx, tt.query, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q != tt.wantQuery {
+				t.Errorf("query =\n%s\nwant\n%s", q, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestRewriteRejects(t *testing.T) {
+	e, _, _ := newTestEnforcer(t)
+	ctx := ctxx.WithTenantID(context.Background(), "t1")
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		query string
+		args  []any
+		want  error
+	}{
+		{"没有租户", context.Background(), "DELETE FROM shops WHERE id = ?", []any{1}, ErrNoTenant},
+		{"JOIN", ctx, "SELECT * FROM orders o JOIN order_items i ON i.order_id = o.id", nil, ErrUnsupported},
+		{"子查询", ctx, "SELECT * FROM users WHERE id IN (SELECT user_id FROM orders)", nil, ErrUnsupported},
+		{"逗号连接", ctx, "SELECT * FROM orders, users", nil, ErrUnsupported},
+		{"INSERT SELECT", ctx, "INSERT INTO shops (id) SELECT id FROM tmp", nil, ErrUnsupported},
+		{"插入其他租户", ctx, "INSERT INTO shops (id, tenant_id) VALUES (?, ?)", []any{1, "t2"}, ErrCrossTenant},
+		{"插入租户字面量", ctx, "INSERT INTO shops (id, tenant_id) VALUES (?, 't1')", []any{1}, ErrUnsupported},
+		{"括号包住的查询", ctx, "(SELECT * FROM orders)", nil, ErrUnsupported},
+		{"TABLE 语句", ctx, "TABLE orders", nil, ErrUnsupported},
+		{"可执行注释", ctx, "SELECT * FROM users /*! UNION SELECT * FROM orders */", nil, ErrUnsupported},
+		{"优化器提示", ctx, "SELECT /*+ MAX_EXECUTION_TIME(1) */ * FROM orders", nil, ErrUnsupported},
+		{"制表符注释", ctx, "DELETE FROM orders --\tx", nil, ErrUnsupported},
+		{"修改租户", ctx, "UPDATE shops SET tenant_id = ? WHERE id = ?", []any{"t2", 1}, ErrCrossTenant},
+		{"冲突时修改租户", ctx, "INSERT INTO shops (id) VALUES (?) ON DUPLICATE KEY UPDATE tenant_id = 't2'", []any{1}, ErrUnsupported},
+		{"冲突时更新", ctx, "INSERT INTO orders (id, amount) VALUES (?, ?) ON DUPLICATE KEY UPDATE amount = VALUES(amount)", []any{1, 10}, ErrUnsupported},
+		{"REPLACE", ctx, "REPLACE INTO orders (id, amount) VALUES (?, ?)", []any{1, 10}, ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := e.Rewrite(tt.ctx, tt.query, tt.args...); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteBypass(t *testing.T) {
+	e, auditor, reg := newTestEnforcer(t)
+	ctx := ctxx.WithRequestID(ctxx.WithTenantIsolation(ctxx.WithTenantID(context.Background(), "t1"), false), "req-1")
+
+	q := "SELECT * FROM orders o JOIN order_items i ON i.order_id = o.id"
+	got, _, err := e.Rewrite(ctx, q)
+	if err != nil || got != q {
+		t.Fatalf("关闭隔离后应原样执行: %q, %v", got, err)
+	}
+	got, _, err = e.Rewrite(ctx, "DELETE FROM shops")
+	if err != nil || got != "DELETE FROM shops" {
+		t.Fatalf("关闭隔离后应原样执行: %q, %v", got, err)
+	}
+
+	if len(auditor.events) != 3 {
+		t.Fatalf("审计事件 = %+v, want 3", auditor.events)
+	}
+	ev := auditor.events[0]
+	if ev.Dimension != DimensionTenant || ev.Table != "orders" || ev.Operation != "SELECT" || ev.RequestID != "req-1" || ev.Statement != q {
+		t.Errorf("审计事件 = %+v", ev)
+	}
+	if got := testutil.ToFloat64(e.bypassTotal.WithLabelValues(DimensionTenant, "shops", "DELETE")); got != 1 {
+		t.Errorf("bypass_total = %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(reg, "hertzcommon_tenancy_bypass_total"); n != 3 {
+		t.Errorf("bypass 序列数 = %d, want 3", n)
+	}
+
+	// 只关闭商户隔离时仍然按租户隔离
+	ctx = ctxx.WithMerchantIsolation(ctxx.WithMerchantID(ctxx.WithTenantID(context.Background(), "t1"), "m1"), false)
+	got, args, err := e.Rewrite(ctx, "SELECT * FROM orders")
+	if err != nil || got != "SELECT * FROM orders WHERE orders.`tenant_id` = ?" || len(args) != 1 {
+		t.Errorf("只关闭商户隔离: %q %v %v", got, args, err)
+	}
+	if last := auditor.events[len(auditor.events)-1]; last.Dimension != DimensionMerchant {
+		t.Errorf("审计事件 = %+v", last)
+	}
+}
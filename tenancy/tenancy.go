@@ -0,0 +1,184 @@
+// Package tenancy 数据行按租户/商户隔离：GORM 插件与 database/sql 语句改写，
+// 根据 ctxx 中的租户/商户 ID 自动追加 tenant_id = ?、merchant_id = ? 条件并在插入时填充这两列
+//
+// 租户隔离默认开启，上下文中没有租户 ID 时拒绝访问隔离表；只有通过 ctxx.WithTenantIsolation(ctx, false)
+// 显式关闭隔离才能跨租户访问，每次绕过都会交给 Auditor 审计。商户维度只在上下文中有商户 ID 时生效，
+// 没有商户 ID 表示租户级别的访问
+package tenancy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/monitor"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 隔离维度
+const (
+	DimensionTenant   = "tenant"
+	DimensionMerchant = "merchant"
+)
+
+var (
+	// ErrNoTenant 租户隔离开启但上下文中没有租户 ID
+	ErrNoTenant = errors.New("租户隔离已开启但上下文中没有租户 ID")
+	// ErrCrossTenant 写入或修改的租户/商户列与上下文不一致
+	ErrCrossTenant = errors.New("不能写入其他租户或商户的数据")
+	// ErrUnsupported 语句访问了隔离表，但结构过于复杂无法自动追加条件（JOIN、子查询、UNION 等）
+	ErrUnsupported = errors.New("无法自动隔离的语句")
+)
+
+// Options 隔离配置
+type Options struct {
+	// TenantColumn 租户列名，默认 tenant_id
+	TenantColumn string
+	// MerchantColumn 商户列名，默认 merchant_id
+	MerchantColumn string
+	// TenantTables 按租户隔离的表；GORM 插件还会隔离模型中有 TenantColumn 字段的表，SQL 改写只认这里列出的表
+	TenantTables []string
+	// MerchantTables 按商户隔离的表，规则同 TenantTables
+	MerchantTables []string
+	// ExcludeTables 不隔离的表，如租户表本身
+	ExcludeTables []string
+	// Auditor 审计绕过隔离的访问，默认记录警告日志
+	Auditor Auditor
+	// Registerer 注册指标的 Prometheus 注册表，默认 monitor.Reg；两者都为空时不记录指标
+	Registerer prometheus.Registerer
+}
+
+// BypassEvent 一次绕过隔离的访问
+type BypassEvent struct {
+	// Dimension 绕过的隔离维度：tenant 或 merchant
+	Dimension string
+	Table     string
+	// Operation SELECT、INSERT、UPDATE、DELETE，其他语句为 OTHER
+	Operation string
+	// Statement SQL 改写时为原始语句，GORM 插件中为空
+	Statement  string
+	TenantID   string
+	MerchantID string
+	UserID     string
+	RequestID  string
+}
+
+// Auditor 审计绕过隔离的访问
+type Auditor interface {
+	Audit(ctx context.Context, ev *BypassEvent)
+}
+
+// AuditorFunc 函数形式的 Auditor
+type AuditorFunc func(ctx context.Context, ev *BypassEvent)
+
+// Audit 实现 Auditor
+func (f AuditorFunc) Audit(ctx context.Context, ev *BypassEvent) {
+	f(ctx, ev)
+}
+
+// LogAuditor 以警告日志记录绕过隔离的访问
+var LogAuditor = AuditorFunc(func(ctx context.Context, ev *BypassEvent) {
+	hlog.CtxWarnf(ctx, "绕过%s隔离 [table: %s, op: %s, tenant: %s, merchant: %s, user: %s, request_id: %s]: %s",
+		ev.Dimension, ev.Table, ev.Operation, ev.TenantID, ev.MerchantID, ev.UserID, ev.RequestID, ev.Statement)
+})
+
+// Enforcer 根据上下文为访问隔离表的语句追加条件
+type Enforcer struct {
+	opts           Options
+	tenantTables   map[string]bool
+	merchantTables map[string]bool
+	excludeTables  map[string]bool
+	bypassTotal    *prometheus.CounterVec
+}
+
+// New 创建隔离
+func New(opts *Options) (*Enforcer, error) {
+	e := &Enforcer{}
+	if opts != nil {
+		e.opts = *opts
+	}
+	if e.opts.TenantColumn == "" {
+		e.opts.TenantColumn = "tenant_id"
+	}
+	if e.opts.MerchantColumn == "" {
+		e.opts.MerchantColumn = "merchant_id"
+	}
+	if e.opts.Auditor == nil {
+		e.opts.Auditor = LogAuditor
+	}
+	e.tenantTables = tableSet(e.opts.TenantTables)
+	e.merchantTables = tableSet(e.opts.MerchantTables)
+	e.excludeTables = tableSet(e.opts.ExcludeTables)
+
+	reg := e.opts.Registerer
+	if reg == nil && monitor.Reg != nil {
+		reg = monitor.Reg
+	}
+	if reg != nil {
+		var err error
+		if e.bypassTotal, err = monitor.RegisterOrExisting(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hertzcommon_tenancy_bypass_total",
+			Help: "显式关闭隔离后访问隔离表的语句数",
+		}, []string{"dimension", "table", "operation"})); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func tableSet(tables []string) map[string]bool {
+	m := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		m[strings.ToLower(t)] = true
+	}
+	return m
+}
+
+// predicate 需要追加的等值条件或填充的列
+type predicate struct {
+	column string
+	value  string
+}
+
+// scope 返回访问 table 需要追加的条件；隔离开启但没有租户 ID 时返回 ErrNoTenant，显式关闭隔离时审计
+func (e *Enforcer) scope(ctx context.Context, table, op, statement string, tenantScoped, merchantScoped bool) ([]predicate, error) {
+	var preds []predicate
+	if tenantScoped {
+		switch {
+		case !ctxx.IsTenantIsolationEnabled(ctx):
+			e.audit(ctx, DimensionTenant, table, op, statement)
+		case ctxx.GetTenantID(ctx) == "":
+			return nil, fmt.Errorf("%w [table: %s, op: %s]", ErrNoTenant, table, op)
+		default:
+			preds = append(preds, predicate{column: e.opts.TenantColumn, value: ctxx.GetTenantID(ctx)})
+		}
+	}
+	if merchantScoped {
+		switch {
+		case !ctxx.IsMerchantIsolationEnabled(ctx):
+			e.audit(ctx, DimensionMerchant, table, op, statement)
+		case ctxx.GetMerchantID(ctx) != "":
+			preds = append(preds, predicate{column: e.opts.MerchantColumn, value: ctxx.GetMerchantID(ctx)})
+		}
+	}
+	return preds, nil
+}
+
+func (e *Enforcer) audit(ctx context.Context, dimension, table, op, statement string) {
+	if e.bypassTotal != nil {
+		e.bypassTotal.WithLabelValues(dimension, table, op).Inc()
+	}
+	e.opts.Auditor.Audit(ctx, &BypassEvent{
+		Dimension:  dimension,
+		Table:      table,
+		Operation:  op,
+		Statement:  statement,
+		TenantID:   ctxx.GetTenantID(ctx),
+		MerchantID: ctxx.GetMerchantID(ctx),
+		UserID:     ctxx.GetUserID(ctx),
+		RequestID:  ctxx.GetRequestID(ctx),
+	})
+}
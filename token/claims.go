@@ -0,0 +1,134 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/grayscalecloud/hertzcommon/pkg/ctxx"
+)
+
+// Kind 令牌类型
+type Kind string
+
+const (
+	KindAccess  Kind = "access"
+	KindRefresh Kind = "refresh"
+)
+
+const (
+	// 令牌中承载身份信息的 claim 名称，与 ctxx 中的键名保持一致
+	ClaimTenantID   = ctxx.TenantKey
+	ClaimMerchantID = ctxx.MerchantKey
+	ClaimUserID     = ctxx.UserKey
+	ClaimAppType    = ctxx.AppTypeKey
+	ClaimRoles      = "roles"
+	ClaimIsAdmin    = "is_admin"
+	ClaimKind       = "typ"
+	ClaimSessionID  = "sid"
+)
+
+// Claims 令牌承载的业务身份信息及标准声明
+type Claims struct {
+	TenantID   string
+	MerchantID string
+	UserID     string
+	AppType    string
+	Roles      []string
+	IsAdmin    bool
+
+	// JTI 令牌唯一标识，签发时自动生成
+	JTI string
+	// SessionID 会话标识，同一次登录刷新出来的令牌共享同一个 SessionID
+	SessionID string
+	Kind      Kind
+
+	Issuer    string
+	Audience  string
+	Subject   string
+	IssuedAt  time.Time
+	NotBefore time.Time
+	ExpiresAt time.Time
+}
+
+// toToken 把 Claims 转换为 paseto.Token
+func (c *Claims) toToken() (paseto.Token, error) {
+	tk := paseto.NewToken()
+	tk.SetJti(c.JTI)
+	// iat 保留亚秒精度，吊销时按签发时间精确比较
+	tk.SetString("iat", c.IssuedAt.Format(time.RFC3339Nano))
+	tk.SetNotBefore(c.NotBefore)
+	tk.SetExpiration(c.ExpiresAt)
+	if c.Issuer != "" {
+		tk.SetIssuer(c.Issuer)
+	}
+	if c.Audience != "" {
+		tk.SetAudience(c.Audience)
+	}
+	subject := c.Subject
+	if subject == "" {
+		subject = c.UserID
+	}
+	if subject != "" {
+		tk.SetSubject(subject)
+	}
+
+	tk.SetString(ClaimKind, string(c.Kind))
+	tk.SetString(ClaimTenantID, c.TenantID)
+	tk.SetString(ClaimMerchantID, c.MerchantID)
+	tk.SetString(ClaimUserID, c.UserID)
+	tk.SetString(ClaimAppType, c.AppType)
+	if c.SessionID != "" {
+		tk.SetString(ClaimSessionID, c.SessionID)
+	}
+	roles := c.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	if err := tk.Set(ClaimRoles, roles); err != nil {
+		return tk, fmt.Errorf("设置 roles 失败: %w", err)
+	}
+	if err := tk.Set(ClaimIsAdmin, c.IsAdmin); err != nil {
+		return tk, fmt.Errorf("设置 is_admin 失败: %w", err)
+	}
+	return tk, nil
+}
+
+// ClaimsFromToken 从已校验的 paseto.Token 中解析 Claims，缺失的可选字段保持零值
+func ClaimsFromToken(tk *paseto.Token) *Claims {
+	c := &Claims{}
+	c.TenantID, _ = tk.GetString(ClaimTenantID)
+	c.MerchantID, _ = tk.GetString(ClaimMerchantID)
+	c.UserID, _ = tk.GetString(ClaimUserID)
+	c.AppType, _ = tk.GetString(ClaimAppType)
+	c.SessionID, _ = tk.GetString(ClaimSessionID)
+	_ = tk.Get(ClaimRoles, &c.Roles)
+	_ = tk.Get(ClaimIsAdmin, &c.IsAdmin)
+	if kind, err := tk.GetString(ClaimKind); err == nil {
+		c.Kind = Kind(kind)
+	}
+
+	c.JTI, _ = tk.GetJti()
+	c.Issuer, _ = tk.GetIssuer()
+	c.Audience, _ = tk.GetAudience()
+	c.Subject, _ = tk.GetSubject()
+	c.IssuedAt, _ = tk.GetIssuedAt()
+	c.NotBefore, _ = tk.GetNotBefore()
+	c.ExpiresAt, _ = tk.GetExpiration()
+
+	if c.UserID == "" {
+		c.UserID = c.Subject
+	}
+	return c
+}
+
+// newID 生成 128 位随机标识，用于 jti 和会话 ID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成随机标识失败: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
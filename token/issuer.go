@@ -0,0 +1,236 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+)
+
+const (
+	defaultAccessTTL  = 15 * time.Minute
+	defaultRefreshTTL = 7 * 24 * time.Hour
+)
+
+// IssuerOptions 令牌签发配置
+type IssuerOptions struct {
+	// Issuer 写入令牌的 iss
+	Issuer string
+	// Audience 写入访问令牌的 aud
+	Audience string
+	// AccessTTL 访问令牌有效期，默认 15 分钟
+	AccessTTL time.Duration
+	// RefreshTTL 刷新令牌有效期，默认 7 天
+	RefreshTTL time.Duration
+	// RefreshStore 刷新令牌存储，默认使用内存存储
+	RefreshStore RefreshStore
+	// Revocation 刷新前检查令牌是否已被吊销（如修改密码、租户停用），为空时不检查
+	Revocation RevocationChecker
+}
+
+// Pair 访问令牌与刷新令牌
+type Pair struct {
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        string    `json:"session_id"`
+}
+
+// Issuer 使用 PasetoSecretConfig 签发令牌
+// 访问令牌为 v4.public，可被持有公钥的服务校验；刷新令牌为 v4.local，仅签发方可解密
+// 私钥集可在运行时通过 Update 热更新：新令牌使用 active 密钥签发，退役密钥仍可解密存量刷新令牌
+type Issuer struct {
+	keys   atomic.Pointer[secretKeySet]
+	opts   IssuerOptions
+	parser paseto.Parser
+}
+
+// NewIssuer 创建令牌签发器
+func NewIssuer(cfg *hdmodel.PasetoSecretConfig, opts *IssuerOptions) (*Issuer, error) {
+	keys, err := newSecretKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	o := IssuerOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.AccessTTL <= 0 {
+		o.AccessTTL = defaultAccessTTL
+	}
+	if o.RefreshTTL <= 0 {
+		o.RefreshTTL = defaultRefreshTTL
+	}
+	if o.RefreshStore == nil {
+		o.RefreshStore = NewMemoryRefreshStore()
+	}
+
+	i := &Issuer{
+		opts:   o,
+		parser: newParser(&VerifierOptions{Issuer: o.Issuer}),
+	}
+	i.keys.Store(keys)
+	return i, nil
+}
+
+// Update 替换私钥集，解析失败时保留原私钥集
+func (i *Issuer) Update(cfg *hdmodel.PasetoSecretConfig) error {
+	keys, err := newSecretKeySet(cfg)
+	if err != nil {
+		return err
+	}
+	i.keys.Store(keys)
+	return nil
+}
+
+// PublicKeyHex 返回当前签名私钥对应的公钥
+func (i *Issuer) PublicKeyHex() string {
+	return i.keys.Load().active.sk.Public().ExportHex()
+}
+
+// PublicConfig 返回与私钥集对应的公钥配置，用于发布到 pasetopub
+func (i *Issuer) PublicConfig() *hdmodel.PasetoConfig {
+	return i.keys.Load().publicConfig()
+}
+
+// IssuePublic 签发 v4.public 令牌，返回令牌与补全后的 Claims
+func (i *Issuer) IssuePublic(claims Claims, ttl time.Duration) (string, *Claims, error) {
+	c, err := i.prepare(claims, ttl)
+	if err != nil {
+		return "", nil, err
+	}
+	keys := i.keys.Load()
+	tk, err := c.toToken()
+	if err != nil {
+		return "", nil, err
+	}
+	tk.SetFooter(encodeFooter(keys.active.kid))
+	return tk.V4Sign(keys.active.sk, keys.implicit), c, nil
+}
+
+// IssueLocal 签发 v4.local 加密令牌，返回令牌与补全后的 Claims
+func (i *Issuer) IssueLocal(claims Claims, ttl time.Duration) (string, *Claims, error) {
+	c, err := i.prepare(claims, ttl)
+	if err != nil {
+		return "", nil, err
+	}
+	keys := i.keys.Load()
+	tk, err := c.toToken()
+	if err != nil {
+		return "", nil, err
+	}
+	tk.SetFooter(encodeFooter(keys.active.kid))
+	return tk.V4Encrypt(keys.active.local, keys.implicit), c, nil
+}
+
+// ParseLocal 解密并校验 v4.local 令牌
+func (i *Issuer) ParseLocal(raw string) (*Claims, error) {
+	keys := i.keys.Load()
+	kid := footerKid(paseto.V4Local, raw)
+	candidates := keys.candidates(kid)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: 未知的密钥 kid %s", ErrInvalidToken, kid)
+	}
+
+	var lastErr error
+	for _, key := range candidates {
+		tk, err := i.parser.ParseV4Local(key.local, raw, keys.implicit)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return ClaimsFromToken(tk), nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrInvalidToken, lastErr)
+}
+
+// IssuePair 为一次新的登录签发访问令牌与刷新令牌
+func (i *Issuer) IssuePair(ctx context.Context, claims Claims) (*Pair, error) {
+	sessionID, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	claims.SessionID = sessionID
+	return i.issuePair(ctx, claims)
+}
+
+// Refresh 使用刷新令牌换取新的令牌对；旧刷新令牌随即失效，重复使用会吊销整个会话
+func (i *Issuer) Refresh(ctx context.Context, refreshToken string) (*Pair, error) {
+	claims, err := i.ParseLocal(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Kind != KindRefresh || claims.SessionID == "" {
+		return nil, ErrTokenKind
+	}
+	if i.opts.Revocation != nil {
+		revoked, err := i.opts.Revocation.IsRevoked(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	if err := i.opts.RefreshStore.Consume(ctx, claims.JTI, claims.SessionID); err != nil {
+		return nil, err
+	}
+	return i.issuePair(ctx, *claims)
+}
+
+// RevokeSession 吊销会话，之后该会话的刷新令牌不可再用于刷新
+func (i *Issuer) RevokeSession(ctx context.Context, sessionID string) error {
+	return i.opts.RefreshStore.RevokeSession(ctx, sessionID)
+}
+
+func (i *Issuer) issuePair(ctx context.Context, claims Claims) (*Pair, error) {
+	claims.Kind = KindAccess
+	claims.Audience = i.opts.Audience
+	access, accessClaims, err := i.IssuePublic(claims, i.opts.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	claims.Kind = KindRefresh
+	claims.Audience = ""
+	refresh, refreshClaims, err := i.IssueLocal(claims, i.opts.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.opts.RefreshStore.Save(ctx, refreshClaims.JTI, refreshClaims.SessionID, refreshClaims.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("保存刷新令牌失败: %w", err)
+	}
+
+	return &Pair{
+		AccessToken:      access,
+		AccessExpiresAt:  accessClaims.ExpiresAt,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshClaims.ExpiresAt,
+		SessionID:        claims.SessionID,
+	}, nil
+}
+
+// prepare 补全 jti、签发时间、有效期等标准声明
+func (i *Issuer) prepare(claims Claims, ttl time.Duration) (*Claims, error) {
+	jti, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	claims.JTI = jti
+	claims.IssuedAt = now
+	claims.NotBefore = now
+	claims.ExpiresAt = now.Add(ttl)
+	if claims.Kind == "" {
+		claims.Kind = KindAccess
+	}
+	if claims.Issuer == "" {
+		claims.Issuer = i.opts.Issuer
+	}
+	return &claims, nil
+}
@@ -0,0 +1,50 @@
+package token
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"aidanwoods.dev/go-paseto"
+	"golang.org/x/crypto/hkdf"
+)
+
+// localKeyInfo 从签名私钥派生 v4.local 对称密钥时使用的 HKDF info
+const localKeyInfo = "hertzcommon-paseto-v4-local"
+
+// ParseSecretKey 解析十六进制编码的 v4.public 私钥，支持 32 字节种子或 64 字节完整私钥
+func ParseSecretKey(hexKey string) (paseto.V4AsymmetricSecretKey, error) {
+	var (
+		sk  paseto.V4AsymmetricSecretKey
+		err error
+	)
+	if len(hexKey) == 64 {
+		sk, err = paseto.NewV4AsymmetricSecretKeyFromSeed(hexKey)
+	} else {
+		sk, err = paseto.NewV4AsymmetricSecretKeyFromHex(hexKey)
+	}
+	if err != nil {
+		return sk, fmt.Errorf("解析 Paseto 私钥失败: %w", err)
+	}
+	return sk, nil
+}
+
+// ParsePublicKey 解析十六进制编码的 v4.public 公钥
+func ParsePublicKey(hexKey string) (paseto.V4AsymmetricPublicKey, error) {
+	pk, err := paseto.NewV4AsymmetricPublicKeyFromHex(hexKey)
+	if err != nil {
+		return pk, fmt.Errorf("解析 Paseto 公钥失败: %w", err)
+	}
+	return pk, nil
+}
+
+// deriveLocalKey 通过 HKDF-SHA256 从签名私钥派生 v4.local 对称密钥，
+// 这样签发方只需维护一份 secret_key 即可同时签发 public 与 local 令牌
+func deriveLocalKey(sk paseto.V4AsymmetricSecretKey) (paseto.V4SymmetricKey, error) {
+	okm := make([]byte, 32)
+	r := hkdf.New(sha256.New, sk.ExportBytes(), nil, []byte(localKeyInfo))
+	if _, err := io.ReadFull(r, okm); err != nil {
+		return paseto.V4SymmetricKey{}, fmt.Errorf("派生 v4.local 密钥失败: %w", err)
+	}
+	return paseto.V4SymmetricKeyFromBytes(okm)
+}
@@ -0,0 +1,206 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+)
+
+// footer 令牌 footer，携带签名密钥的 kid 以便校验方选择公钥
+type footer struct {
+	Kid string `json:"kid,omitempty"`
+}
+
+// encodeFooter 编码 footer，kid 为空时不写 footer 以兼容单密钥部署
+func encodeFooter(kid string) []byte {
+	if kid == "" {
+		return nil
+	}
+	b, _ := json.Marshal(footer{Kid: kid})
+	return b
+}
+
+// footerKid 在校验签名前读取 footer 中的 kid，仅用于选择密钥
+func footerKid(protocol paseto.Protocol, raw string) string {
+	b, err := paseto.NewParserWithoutExpiryCheck().UnsafeParseFooter(protocol, raw)
+	if err != nil || len(b) == 0 {
+		return ""
+	}
+	var f footer
+	if err := json.Unmarshal(b, &f); err != nil {
+		return ""
+	}
+	return f.Kid
+}
+
+// publicKeySet 校验方持有的公钥集
+type publicKeySet struct {
+	implicit []byte
+	keys     map[string]paseto.V4AsymmetricPublicKey
+	order    []string
+}
+
+// newPublicKeySet 解析公钥配置，pub_key 视为 kid 为空的密钥
+func newPublicKeySet(cfg *hdmodel.PasetoConfig) (*publicKeySet, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("Paseto 公钥未配置")
+	}
+	s := &publicKeySet{
+		implicit: []byte(cfg.Implicit),
+		keys:     make(map[string]paseto.V4AsymmetricPublicKey),
+	}
+	if cfg.PubKey != "" {
+		pk, err := ParsePublicKey(cfg.PubKey)
+		if err != nil {
+			return nil, err
+		}
+		s.add("", pk)
+	}
+	for _, k := range cfg.Keys {
+		if k.Kid == "" {
+			return nil, fmt.Errorf("Paseto 公钥缺少 kid")
+		}
+		if _, exists := s.keys[k.Kid]; exists {
+			return nil, fmt.Errorf("Paseto 公钥 kid 重复: %s", k.Kid)
+		}
+		pk, err := ParsePublicKey(k.PubKey)
+		if err != nil {
+			return nil, fmt.Errorf("kid %s: %w", k.Kid, err)
+		}
+		s.add(k.Kid, pk)
+	}
+	if len(s.order) == 0 {
+		return nil, fmt.Errorf("Paseto 公钥未配置")
+	}
+	return s, nil
+}
+
+func (s *publicKeySet) add(kid string, pk paseto.V4AsymmetricPublicKey) {
+	s.keys[kid] = pk
+	s.order = append(s.order, kid)
+}
+
+// candidates 返回可用于校验的公钥：带 kid 的令牌只匹配对应公钥，不带 kid 的令牌依次尝试全部公钥
+func (s *publicKeySet) candidates(kid string) []paseto.V4AsymmetricPublicKey {
+	if kid != "" {
+		if pk, ok := s.keys[kid]; ok {
+			return []paseto.V4AsymmetricPublicKey{pk}
+		}
+		return nil
+	}
+	out := make([]paseto.V4AsymmetricPublicKey, 0, len(s.order))
+	for _, k := range s.order {
+		out = append(out, s.keys[k])
+	}
+	return out
+}
+
+// secretKey 私钥集中的单把私钥及其派生的 v4.local 密钥
+type secretKey struct {
+	kid   string
+	sk    paseto.V4AsymmetricSecretKey
+	local paseto.V4SymmetricKey
+}
+
+// secretKeySet 签发方持有的私钥集
+type secretKeySet struct {
+	implicit []byte
+	active   *secretKey
+	keys     map[string]*secretKey
+	order    []*secretKey
+}
+
+// newSecretKeySet 解析私钥配置；secret_key 视为 kid 为空的密钥，
+// 只有一把密钥时它就是签名密钥，多把密钥时必须且只能有一把 status 为 active
+func newSecretKeySet(cfg *hdmodel.PasetoSecretConfig) (*secretKeySet, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("Paseto 私钥未配置")
+	}
+	s := &secretKeySet{
+		implicit: []byte(cfg.Implicit),
+		keys:     make(map[string]*secretKey),
+	}
+	if cfg.SecretKey != "" {
+		if _, err := s.add("", cfg.SecretKey); err != nil {
+			return nil, err
+		}
+	}
+	for _, k := range cfg.Keys {
+		if k.Kid == "" {
+			return nil, fmt.Errorf("Paseto 私钥缺少 kid")
+		}
+		if _, exists := s.keys[k.Kid]; exists {
+			return nil, fmt.Errorf("Paseto 私钥 kid 重复: %s", k.Kid)
+		}
+		key, err := s.add(k.Kid, k.SecretKey)
+		if err != nil {
+			return nil, fmt.Errorf("kid %s: %w", k.Kid, err)
+		}
+		switch k.Status {
+		case hdmodel.PasetoKeyActive:
+			if s.active != nil {
+				return nil, fmt.Errorf("Paseto 私钥集中存在多个 active 密钥")
+			}
+			s.active = key
+		case hdmodel.PasetoKeyRetired, "":
+		default:
+			return nil, fmt.Errorf("kid %s: 未知的密钥状态 %s", k.Kid, k.Status)
+		}
+	}
+	if len(s.order) == 0 {
+		return nil, fmt.Errorf("Paseto 私钥未配置")
+	}
+	if s.active == nil {
+		if len(s.order) > 1 {
+			return nil, fmt.Errorf("Paseto 私钥集中缺少 active 密钥")
+		}
+		s.active = s.order[0]
+	}
+	return s, nil
+}
+
+func (s *secretKeySet) add(kid, hexKey string) (*secretKey, error) {
+	sk, err := ParseSecretKey(hexKey)
+	if err != nil {
+		return nil, err
+	}
+	local, err := deriveLocalKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	key := &secretKey{kid: kid, sk: sk, local: local}
+	s.keys[kid] = key
+	s.order = append(s.order, key)
+	return key, nil
+}
+
+// candidates 返回可用于解密的密钥，规则与 publicKeySet.candidates 一致
+func (s *secretKeySet) candidates(kid string) []*secretKey {
+	if kid != "" {
+		if key, ok := s.keys[kid]; ok {
+			return []*secretKey{key}
+		}
+		return nil
+	}
+	return s.order
+}
+
+// publicConfig 导出与私钥集对应的公钥配置，用于发布到 pasetopub
+func (s *secretKeySet) publicConfig() *hdmodel.PasetoConfig {
+	cfg := &hdmodel.PasetoConfig{Implicit: string(s.implicit)}
+	for _, key := range s.order {
+		pub := key.sk.Public().ExportHex()
+		if key.kid == "" {
+			cfg.PubKey = pub
+			continue
+		}
+		status := hdmodel.PasetoKeyRetired
+		if key == s.active {
+			status = hdmodel.PasetoKeyActive
+		}
+		cfg.Keys = append(cfg.Keys, hdmodel.PasetoPubKey{Kid: key.kid, PubKey: pub, Status: status})
+	}
+	return cfg
+}
@@ -0,0 +1,76 @@
+package token
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ttlCache 带过期时间的 LRU 缓存，用作吊销查询的本地前置缓存
+type ttlCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type ttlEntry struct {
+	key       string
+	value     int64
+	expiresAt time.Time
+}
+
+func newTTLCache(capacity int) *ttlCache {
+	return &ttlCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+// get 读取未过期的缓存值
+func (c *ttlCache) get(key string, now time.Time) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return 0, false
+	}
+	entry := el.Value.(*ttlEntry)
+	if now.After(entry.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return 0, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+// set 写入缓存，超出容量时淘汰最久未使用的条目
+func (c *ttlCache) set(key string, value int64, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*ttlEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&ttlEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*ttlEntry).key)
+	}
+}
+
+// remove 删除缓存条目
+func (c *ttlCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+}
@@ -0,0 +1,101 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("刷新令牌不存在")
+	ErrRefreshTokenReused   = errors.New("刷新令牌已被使用，会话已吊销")
+	ErrSessionRevoked       = errors.New("会话已吊销")
+)
+
+// RefreshStore 记录刷新令牌的使用状态，用于实现刷新令牌轮换与重放检测
+type RefreshStore interface {
+	// Save 登记新签发的刷新令牌
+	Save(ctx context.Context, jti, sessionID string, expiresAt time.Time) error
+	// Consume 标记刷新令牌已使用；重复使用时必须返回 ErrRefreshTokenReused
+	Consume(ctx context.Context, jti, sessionID string) error
+	// RevokeSession 吊销整个会话，之后该会话下的刷新令牌均不可用
+	RevokeSession(ctx context.Context, sessionID string) error
+}
+
+type refreshEntry struct {
+	sessionID string
+	expiresAt time.Time
+	used      bool
+}
+
+// MemoryRefreshStore 基于内存的 RefreshStore，适用于单实例部署与测试
+type MemoryRefreshStore struct {
+	mu       sync.Mutex
+	tokens   map[string]*refreshEntry
+	sessions map[string]time.Time // 已吊销的会话 -> 过期清理时间
+}
+
+// NewMemoryRefreshStore 创建内存刷新令牌存储
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{
+		tokens:   make(map[string]*refreshEntry),
+		sessions: make(map[string]time.Time),
+	}
+}
+
+// Save 登记新签发的刷新令牌
+func (s *MemoryRefreshStore) Save(_ context.Context, jti, sessionID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gcLocked(time.Now())
+	s.tokens[jti] = &refreshEntry{sessionID: sessionID, expiresAt: expiresAt}
+	return nil
+}
+
+// Consume 标记刷新令牌已使用，重复使用时吊销整个会话
+func (s *MemoryRefreshStore) Consume(_ context.Context, jti, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, revoked := s.sessions[sessionID]; revoked {
+		return ErrSessionRevoked
+	}
+	entry, ok := s.tokens[jti]
+	if !ok || entry.sessionID != sessionID {
+		return ErrRefreshTokenNotFound
+	}
+	if entry.used {
+		s.sessions[sessionID] = entry.expiresAt
+		return ErrRefreshTokenReused
+	}
+	entry.used = true
+	return nil
+}
+
+// RevokeSession 吊销整个会话
+func (s *MemoryRefreshStore) RevokeSession(_ context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expiresAt time.Time
+	for _, entry := range s.tokens {
+		if entry.sessionID == sessionID && entry.expiresAt.After(expiresAt) {
+			expiresAt = entry.expiresAt
+		}
+	}
+	s.sessions[sessionID] = expiresAt
+	return nil
+}
+
+// gcLocked 清理已过期的记录，调用方需持有锁
+func (s *MemoryRefreshStore) gcLocked(now time.Time) {
+	for jti, entry := range s.tokens {
+		if now.After(entry.expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+	for sid, expiresAt := range s.sessions {
+		if now.After(expiresAt) {
+			delete(s.sessions, sid)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRevocationPrefix   = "hc:revoke:"
+	defaultRevocationCacheTTL = 5 * time.Second
+	defaultRevocationCacheCap = 10000
+	defaultScopeRevocationTTL = 7 * 24 * time.Hour
+
+	// suspendedCutoff 租户停用时写入的截止时间，使所有令牌都被视为已吊销
+	suspendedCutoff = int64(1<<63 - 1)
+)
+
+var ErrTokenRevoked = errors.New("令牌已吊销")
+
+// RevocationChecker 检查令牌是否已被吊销
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, claims *Claims) (bool, error)
+}
+
+// RedisRevocationOptions Redis 吊销存储配置
+type RedisRevocationOptions struct {
+	// KeyPrefix Redis 键前缀，默认 hc:revoke:
+	KeyPrefix string
+	// CacheTTL 本地缓存未吊销结果的时长，默认 5 秒；其他实例的吊销通过 Pub/Sub 立即失效本地缓存
+	CacheTTL time.Duration
+	// CacheSize 本地 LRU 缓存容量，默认 10000
+	CacheSize int
+	// ScopeTTL 按会话/用户/租户吊销的记录保留时长，应不小于刷新令牌有效期，默认 7 天
+	ScopeTTL time.Duration
+}
+
+// RedisRevocationStore 基于 Redis 的令牌吊销存储，前置本地 LRU 缓存
+//
+// 支持四种粒度：单个令牌（jti）、会话（sid）、用户与租户。后三者记录吊销时间，
+// 签发时间不晚于该时间的令牌均视为已吊销，适用于退出登录、修改密码与租户停用等场景。
+type RedisRevocationStore struct {
+	client   redis.UniversalClient
+	prefix   string
+	cacheTTL time.Duration
+	scopeTTL time.Duration
+	cache    *ttlCache
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRedisRevocationStore 创建吊销存储，并订阅吊销事件以失效本地缓存
+func NewRedisRevocationStore(client redis.UniversalClient, opts *RedisRevocationOptions) *RedisRevocationStore {
+	o := RedisRevocationOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.KeyPrefix == "" {
+		o.KeyPrefix = defaultRevocationPrefix
+	}
+	if o.CacheTTL <= 0 {
+		o.CacheTTL = defaultRevocationCacheTTL
+	}
+	if o.CacheSize <= 0 {
+		o.CacheSize = defaultRevocationCacheCap
+	}
+	if o.ScopeTTL <= 0 {
+		o.ScopeTTL = defaultScopeRevocationTTL
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &RedisRevocationStore{
+		client:   client,
+		prefix:   o.KeyPrefix,
+		cacheTTL: o.CacheTTL,
+		scopeTTL: o.ScopeTTL,
+		cache:    newTTLCache(o.CacheSize),
+		cancel:   cancel,
+	}
+	s.wg.Add(1)
+	go s.subscribe(ctx)
+	return s
+}
+
+// RevokeToken 吊销单个令牌，记录保留到令牌过期
+func (s *RedisRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt) + defaultClockLeeway
+	if ttl <= 0 {
+		return nil
+	}
+	return s.set(ctx, s.jtiKey(jti), time.Now().UnixNano(), ttl)
+}
+
+// RevokeSession 吊销会话内此前签发的全部令牌，用于退出登录
+func (s *RedisRevocationStore) RevokeSession(ctx context.Context, sessionID string) error {
+	return s.set(ctx, s.sessionKey(sessionID), time.Now().UnixNano(), s.scopeTTL)
+}
+
+// RevokeUser 吊销用户此前签发的全部令牌，用于修改密码、强制下线
+func (s *RedisRevocationStore) RevokeUser(ctx context.Context, tenantID, userID string) error {
+	return s.set(ctx, s.userKey(tenantID, userID), time.Now().UnixNano(), s.scopeTTL)
+}
+
+// RevokeTenant 吊销租户此前签发的全部令牌
+func (s *RedisRevocationStore) RevokeTenant(ctx context.Context, tenantID string) error {
+	return s.set(ctx, s.tenantKey(tenantID), time.Now().UnixNano(), s.scopeTTL)
+}
+
+// SuspendTenant 停用租户，在 ResumeTenant 之前该租户的所有令牌（包括新签发的）均视为已吊销
+func (s *RedisRevocationStore) SuspendTenant(ctx context.Context, tenantID string) error {
+	return s.set(ctx, s.tenantKey(tenantID), suspendedCutoff, 0)
+}
+
+// ResumeTenant 恢复被停用的租户
+func (s *RedisRevocationStore) ResumeTenant(ctx context.Context, tenantID string) error {
+	key := s.tenantKey(tenantID)
+	if err := s.client.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("恢复租户失败: %w", err)
+	}
+	s.invalidate(ctx, key)
+	return nil
+}
+
+// IsRevoked 检查令牌是否已被吊销
+func (s *RedisRevocationStore) IsRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	iat := claims.IssuedAt.UnixNano()
+	keys := make([]string, 0, 4)
+	if claims.JTI != "" {
+		keys = append(keys, s.jtiKey(claims.JTI))
+	}
+	if claims.SessionID != "" {
+		keys = append(keys, s.sessionKey(claims.SessionID))
+	}
+	if claims.UserID != "" {
+		keys = append(keys, s.userKey(claims.TenantID, claims.UserID))
+	}
+	if claims.TenantID != "" {
+		keys = append(keys, s.tenantKey(claims.TenantID))
+	}
+
+	now := time.Now()
+	var missing []string
+	for _, key := range keys {
+		cutoff, ok := s.cache.get(key, now)
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		if s.revokedBy(key, cutoff, iat) {
+			return true, nil
+		}
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+
+	values, err := s.client.MGet(ctx, missing...).Result()
+	if err != nil {
+		return false, fmt.Errorf("查询令牌吊销状态失败: %w", err)
+	}
+	revoked := false
+	for idx, key := range missing {
+		var cutoff int64
+		if str, ok := values[idx].(string); ok {
+			cutoff, _ = strconv.ParseInt(str, 10, 64)
+		}
+		expiresAt := now.Add(s.cacheTTL)
+		hit := s.revokedBy(key, cutoff, iat)
+		if hit && !strings.HasPrefix(key, s.prefix+"tenant:") {
+			// 令牌一旦被吊销就不会恢复，可以缓存到令牌过期；租户停用可被撤销，只做短时缓存
+			expiresAt = claims.ExpiresAt
+		}
+		s.cache.set(key, cutoff, expiresAt)
+		if hit {
+			revoked = true
+		}
+	}
+	return revoked, nil
+}
+
+// Close 停止吊销事件订阅
+func (s *RedisRevocationStore) Close() error {
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+// revokedBy 判断吊销记录是否覆盖签发时间为 iat 的令牌
+func (s *RedisRevocationStore) revokedBy(key string, cutoff, iat int64) bool {
+	if cutoff == 0 {
+		return false
+	}
+	if strings.HasPrefix(key, s.prefix+"jti:") {
+		return true
+	}
+	return iat <= cutoff
+}
+
+func (s *RedisRevocationStore) set(ctx context.Context, key string, cutoff int64, ttl time.Duration) error {
+	if err := s.client.Set(ctx, key, cutoff, ttl).Err(); err != nil {
+		return fmt.Errorf("写入吊销记录失败: %w", err)
+	}
+	s.cache.set(key, cutoff, time.Now().Add(s.cacheTTL))
+	s.invalidate(ctx, key)
+	return nil
+}
+
+// invalidate 通知其他实例失效本地缓存
+func (s *RedisRevocationStore) invalidate(ctx context.Context, key string) {
+	if err := s.client.Publish(ctx, s.channel(), key).Err(); err != nil {
+		hlog.CtxWarnf(ctx, "发布吊销事件失败 [%s]: %v", key, err)
+	}
+}
+
+// subscribe 订阅吊销事件，收到后删除本地缓存，下次查询回源 Redis
+func (s *RedisRevocationStore) subscribe(ctx context.Context) {
+	defer s.wg.Done()
+	for {
+		pubsub := s.client.Subscribe(ctx, s.channel())
+		ch := pubsub.Channel()
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				_ = pubsub.Close()
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					break loop
+				}
+				s.cache.remove(msg.Payload)
+			}
+		}
+		_ = pubsub.Close()
+		hlog.Warnf("吊销事件订阅中断，5 秒后重试")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (s *RedisRevocationStore) channel() string { return s.prefix + "events" }
+
+func (s *RedisRevocationStore) jtiKey(jti string) string { return s.prefix + "jti:" + jti }
+
+func (s *RedisRevocationStore) sessionKey(sessionID string) string {
+	return s.prefix + "sid:" + sessionID
+}
+
+func (s *RedisRevocationStore) userKey(tenantID, userID string) string {
+	return s.prefix + "user:" + tenantID + ":" + userID
+}
+
+func (s *RedisRevocationStore) tenantKey(tenantID string) string {
+	return s.prefix + "tenant:" + tenantID
+}
@@ -0,0 +1,130 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/pkg/redisx"
+)
+
+func newTestRevocationStore(t *testing.T) (*miniredis.Miniredis, *RedisRevocationStore) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redisx.NewClient(&hdmodel.Redis{Address: mr.Addr()})
+	store := NewRedisRevocationStore(client, &RedisRevocationOptions{CacheTTL: time.Hour})
+	t.Cleanup(func() {
+		_ = store.Close()
+		_ = client.Close()
+	})
+	return mr, store
+}
+
+func TestRedisRevocationStore(t *testing.T) {
+	_, store := newTestRevocationStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	claims := func(jti, sid, tenant, user string, iat time.Time) *Claims {
+		return &Claims{JTI: jti, SessionID: sid, TenantID: tenant, UserID: user, IssuedAt: iat, ExpiresAt: iat.Add(time.Hour)}
+	}
+	assertRevoked := func(name string, c *Claims, want bool) {
+		t.Helper()
+		got, err := store.IsRevoked(ctx, c)
+		if err != nil {
+			t.Fatalf("%s: 查询吊销状态失败: %v", name, err)
+		}
+		if got != want {
+			t.Errorf("%s: revoked = %v, want %v", name, got, want)
+		}
+	}
+
+	logout := claims("jti-1", "sid-1", "t1", "u1", now)
+	assertRevoked("未吊销", logout, false)
+	if err := store.RevokeToken(ctx, logout.JTI, logout.ExpiresAt); err != nil {
+		t.Fatal(err)
+	}
+	assertRevoked("按 jti 吊销", logout, true)
+
+	if err := store.RevokeSession(ctx, "sid-2"); err != nil {
+		t.Fatal(err)
+	}
+	assertRevoked("会话内旧令牌", claims("jti-2", "sid-2", "t1", "u2", now), true)
+
+	// 修改密码：之前签发的令牌失效，之后重新登录签发的令牌有效
+	before := claims("jti-3", "sid-3", "t1", "u3", time.Now())
+	if err := store.RevokeUser(ctx, "t1", "u3"); err != nil {
+		t.Fatal(err)
+	}
+	assertRevoked("修改密码前签发", before, true)
+	assertRevoked("修改密码后签发", claims("jti-4", "sid-4", "t1", "u3", time.Now()), false)
+	assertRevoked("同名用户不同租户", claims("jti-5", "sid-5", "t2", "u3", now), false)
+
+	if err := store.SuspendTenant(ctx, "t9"); err != nil {
+		t.Fatal(err)
+	}
+	assertRevoked("租户停用后新签发", claims("jti-6", "sid-6", "t9", "u1", time.Now().Add(time.Minute)), true)
+	if err := store.ResumeTenant(ctx, "t9"); err != nil {
+		t.Fatal(err)
+	}
+	assertRevoked("租户恢复", claims("jti-7", "sid-7", "t9", "u1", time.Now()), false)
+}
+
+func TestRedisRevocationStoreCrossInstance(t *testing.T) {
+	mr, a := newTestRevocationStore(t)
+	client := redisx.NewClient(&hdmodel.Redis{Address: mr.Addr()})
+	b := NewRedisRevocationStore(client, &RedisRevocationOptions{CacheTTL: time.Hour})
+	defer b.Close()
+	ctx := context.Background()
+
+	c := &Claims{JTI: "jti-x", TenantID: "t1", UserID: "u1", IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	if revoked, _ := b.IsRevoked(ctx, c); revoked {
+		t.Fatal("不应已吊销")
+	}
+	// 等待 b 完成订阅后再由 a 吊销，b 的本地缓存应通过 Pub/Sub 失效
+	time.Sleep(100 * time.Millisecond)
+	if err := a.RevokeUser(ctx, "t1", "u1"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		revoked, err := b.IsRevoked(ctx, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("其他实例的吊销未使本地缓存失效")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	mr.Close()
+	if _, err := a.IsRevoked(ctx, &Claims{JTI: "jti-y", IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}); err == nil {
+		t.Error("Redis 不可用时应返回错误")
+	}
+}
+
+func TestRefreshAfterRevocation(t *testing.T) {
+	_, store := newTestRevocationStore(t)
+	ctx := context.Background()
+
+	issuer, _ := newTestIssuer(t)
+	issuer.opts.Revocation = store
+
+	pair, err := issuer.IssuePair(ctx, Claims{TenantID: "t1", UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeUser(ctx, "t1", "u1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("修改密码后刷新 err = %v, want %v", err, ErrTokenRevoked)
+	}
+}
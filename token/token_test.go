@@ -0,0 +1,199 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+)
+
+func newTestIssuer(t *testing.T) (*Issuer, *Verifier) {
+	t.Helper()
+	sk := paseto.NewV4AsymmetricSecretKey()
+	issuer, err := NewIssuer(&hdmodel.PasetoSecretConfig{SecretKey: sk.ExportHex(), Implicit: "hertzcommon"}, &IssuerOptions{
+		Issuer:   "auth-service",
+		Audience: "order-service",
+	})
+	if err != nil {
+		t.Fatalf("创建签发器失败: %v", err)
+	}
+	verifier, err := NewVerifier(&hdmodel.PasetoConfig{PubKey: issuer.PublicKeyHex(), Implicit: "hertzcommon"}, &VerifierOptions{
+		Audience: "order-service",
+		Issuer:   "auth-service",
+	})
+	if err != nil {
+		t.Fatalf("创建校验器失败: %v", err)
+	}
+	return issuer, verifier
+}
+
+func TestIssuePairAndVerify(t *testing.T) {
+	issuer, verifier := newTestIssuer(t)
+
+	pair, err := issuer.IssuePair(context.Background(), Claims{
+		TenantID:   "t1",
+		MerchantID: "m1",
+		UserID:     "u1",
+		AppType:    "merchant_app",
+		Roles:      []string{"owner", "cashier"},
+	})
+	if err != nil {
+		t.Fatalf("签发令牌失败: %v", err)
+	}
+	if !strings.HasPrefix(pair.AccessToken, "v4.public.") || !strings.HasPrefix(pair.RefreshToken, "v4.local.") {
+		t.Fatalf("令牌格式不正确: %s / %s", pair.AccessToken, pair.RefreshToken)
+	}
+
+	claims, err := verifier.Verify(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("校验访问令牌失败: %v", err)
+	}
+	if claims.TenantID != "t1" || claims.MerchantID != "m1" || claims.UserID != "u1" || claims.AppType != "merchant_app" {
+		t.Errorf("身份信息不正确: %+v", claims)
+	}
+	if !reflect.DeepEqual(claims.Roles, []string{"owner", "cashier"}) {
+		t.Errorf("roles = %v", claims.Roles)
+	}
+	if claims.JTI == "" || claims.SessionID != pair.SessionID || claims.Kind != KindAccess {
+		t.Errorf("标准声明不正确: %+v", claims)
+	}
+
+	if _, err := verifier.Verify(pair.RefreshToken); err == nil {
+		t.Error("刷新令牌不应通过访问令牌校验")
+	}
+}
+
+func TestRefreshRotation(t *testing.T) {
+	issuer, verifier := newTestIssuer(t)
+	ctx := context.Background()
+
+	first, err := issuer.IssuePair(ctx, Claims{TenantID: "t1", UserID: "u1"})
+	if err != nil {
+		t.Fatalf("签发令牌失败: %v", err)
+	}
+
+	second, err := issuer.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("刷新失败: %v", err)
+	}
+	if second.SessionID != first.SessionID || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("刷新应在同一会话内轮换刷新令牌")
+	}
+	if claims, err := verifier.Verify(second.AccessToken); err != nil || claims.UserID != "u1" {
+		t.Fatalf("刷新后的访问令牌无效: %v", err)
+	}
+
+	// 旧刷新令牌被重放：拒绝并吊销整个会话
+	if _, err := issuer.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("重放旧刷新令牌 err = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if _, err := issuer.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("会话吊销后刷新 err = %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestIssueLocal(t *testing.T) {
+	issuer, _ := newTestIssuer(t)
+
+	raw, _, err := issuer.IssueLocal(Claims{TenantID: "t1", UserID: "u1"}, time.Minute)
+	if err != nil {
+		t.Fatalf("签发 v4.local 令牌失败: %v", err)
+	}
+	claims, err := issuer.ParseLocal(raw)
+	if err != nil {
+		t.Fatalf("解析 v4.local 令牌失败: %v", err)
+	}
+	if claims.TenantID != "t1" || claims.UserID != "u1" {
+		t.Errorf("身份信息不正确: %+v", claims)
+	}
+
+	expired, _, err := issuer.IssueLocal(Claims{UserID: "u1"}, -time.Hour)
+	if err != nil {
+		t.Fatalf("签发 v4.local 令牌失败: %v", err)
+	}
+	if _, err := issuer.ParseLocal(expired); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("过期令牌 err = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	k1 := paseto.NewV4AsymmetricSecretKey()
+	k2 := paseto.NewV4AsymmetricSecretKey()
+	ctx := context.Background()
+
+	issuer, err := NewIssuer(&hdmodel.PasetoSecretConfig{
+		Implicit: "hertzcommon",
+		Keys:     []hdmodel.PasetoSecretKey{{Kid: "k1", SecretKey: k1.ExportHex(), Status: hdmodel.PasetoKeyActive}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("创建签发器失败: %v", err)
+	}
+	verifier, err := NewVerifier(issuer.PublicConfig(), nil)
+	if err != nil {
+		t.Fatalf("创建校验器失败: %v", err)
+	}
+	old, err := issuer.IssuePair(ctx, Claims{UserID: "u1"})
+	if err != nil {
+		t.Fatalf("签发令牌失败: %v", err)
+	}
+
+	// 第一步：校验方先加入新公钥，签发方随后切换 active 密钥，k1 退役
+	rotated := &hdmodel.PasetoSecretConfig{
+		Implicit: "hertzcommon",
+		Keys: []hdmodel.PasetoSecretKey{
+			{Kid: "k1", SecretKey: k1.ExportHex(), Status: hdmodel.PasetoKeyRetired},
+			{Kid: "k2", SecretKey: k2.ExportHex(), Status: hdmodel.PasetoKeyActive},
+		},
+	}
+	if err := issuer.Update(rotated); err != nil {
+		t.Fatalf("更新私钥集失败: %v", err)
+	}
+	if err := verifier.Update(issuer.PublicConfig()); err != nil {
+		t.Fatalf("更新公钥集失败: %v", err)
+	}
+
+	fresh, err := issuer.IssuePair(ctx, Claims{UserID: "u2"})
+	if err != nil {
+		t.Fatalf("签发令牌失败: %v", err)
+	}
+	if kid := footerKid(paseto.V4Public, fresh.AccessToken); kid != "k2" {
+		t.Errorf("新令牌 kid = %q, want k2", kid)
+	}
+	for _, raw := range []string{old.AccessToken, fresh.AccessToken} {
+		if _, err := verifier.Verify(raw); err != nil {
+			t.Errorf("轮换重叠期内令牌应可校验: %v", err)
+		}
+	}
+	// 退役密钥加密的刷新令牌仍可刷新，换出的新令牌使用 k2
+	refreshed, err := issuer.Refresh(ctx, old.RefreshToken)
+	if err != nil {
+		t.Fatalf("退役密钥的刷新令牌应可刷新: %v", err)
+	}
+	if kid := footerKid(paseto.V4Local, refreshed.RefreshToken); kid != "k2" {
+		t.Errorf("刷新后 kid = %q, want k2", kid)
+	}
+
+	// 第二步：移除 k1 后，旧令牌失效
+	if err := verifier.Update(&hdmodel.PasetoConfig{
+		Implicit: "hertzcommon",
+		Keys:     []hdmodel.PasetoPubKey{{Kid: "k2", PubKey: k2.Public().ExportHex()}},
+	}); err != nil {
+		t.Fatalf("更新公钥集失败: %v", err)
+	}
+	if _, err := verifier.Verify(old.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("移除 k1 后旧令牌 err = %v, want %v", err, ErrInvalidToken)
+	}
+
+	// 非法配置不会覆盖当前公钥集
+	if err := verifier.Update(&hdmodel.PasetoConfig{Keys: []hdmodel.PasetoPubKey{{Kid: "bad", PubKey: "zz"}}}); err == nil {
+		t.Error("非法公钥配置应返回错误")
+	}
+	if _, err := verifier.Verify(fresh.AccessToken); err != nil {
+		t.Errorf("非法更新后应保留原公钥集: %v", err)
+	}
+}
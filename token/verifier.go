@@ -0,0 +1,130 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"aidanwoods.dev/go-paseto"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+)
+
+const defaultClockLeeway = 30 * time.Second
+
+var (
+	ErrInvalidToken = errors.New("令牌无效")
+	ErrTokenKind    = errors.New("令牌类型不匹配")
+)
+
+// VerifierOptions 令牌校验配置
+type VerifierOptions struct {
+	// Audience 期望的 aud，为空时不校验
+	Audience string
+	// Issuer 期望的 iss，为空时不校验
+	Issuer string
+	// ClockLeeway 校验 exp/nbf 时允许的时钟偏差，默认 30 秒
+	ClockLeeway time.Duration
+}
+
+// Verifier 校验 v4.public 访问令牌，公钥集可在运行时通过 Update 热更新
+type Verifier struct {
+	keys   atomic.Pointer[publicKeySet]
+	parser paseto.Parser
+}
+
+// NewVerifier 使用 Paseto 公钥配置创建校验器
+func NewVerifier(cfg *hdmodel.PasetoConfig, opts *VerifierOptions) (*Verifier, error) {
+	keys, err := newPublicKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	v := &Verifier{parser: newParser(opts)}
+	v.keys.Store(keys)
+	return v, nil
+}
+
+// Update 替换公钥集，解析失败时保留原公钥集
+func (v *Verifier) Update(cfg *hdmodel.PasetoConfig) error {
+	keys, err := newPublicKeySet(cfg)
+	if err != nil {
+		return err
+	}
+	v.keys.Store(keys)
+	return nil
+}
+
+// Verify 校验访问令牌并返回其中的 Claims
+func (v *Verifier) Verify(raw string) (*Claims, error) {
+	keys := v.keys.Load()
+	kid := footerKid(paseto.V4Public, raw)
+	candidates := keys.candidates(kid)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: 未知的密钥 kid %s", ErrInvalidToken, kid)
+	}
+
+	var lastErr error
+	for _, pk := range candidates {
+		tk, err := v.parser.ParseV4Public(pk, raw, keys.implicit)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		claims := ClaimsFromToken(tk)
+		if claims.Kind != "" && claims.Kind != KindAccess {
+			return nil, ErrTokenKind
+		}
+		return claims, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrInvalidToken, lastErr)
+}
+
+// newParser 根据校验配置构建 paseto.Parser
+func newParser(opts *VerifierOptions) paseto.Parser {
+	if opts == nil {
+		opts = &VerifierOptions{}
+	}
+	leeway := opts.ClockLeeway
+	if leeway <= 0 {
+		leeway = defaultClockLeeway
+	}
+	parser := paseto.MakeParser([]paseto.Rule{notExpired(leeway), notBefore(leeway)})
+	if opts.Audience != "" {
+		parser.AddRule(paseto.ForAudience(opts.Audience))
+	}
+	if opts.Issuer != "" {
+		parser.AddRule(paseto.IssuedBy(opts.Issuer))
+	}
+	return parser
+}
+
+// notExpired 要求令牌包含 exp 且未过期（允许 leeway 的时钟偏差）
+func notExpired(leeway time.Duration) paseto.Rule {
+	return func(tk paseto.Token) error {
+		exp, err := tk.GetExpiration()
+		if err != nil {
+			return err
+		}
+		if time.Now().Add(-leeway).After(exp) {
+			return fmt.Errorf("令牌已过期")
+		}
+		return nil
+	}
+}
+
+// notBefore 若令牌包含 nbf，则要求当前时间不早于 nbf（允许 leeway 的时钟偏差）
+func notBefore(leeway time.Duration) paseto.Rule {
+	return func(tk paseto.Token) error {
+		if _, err := tk.GetString("nbf"); err != nil {
+			return nil
+		}
+		nbf, err := tk.GetNotBefore()
+		if err != nil {
+			return err
+		}
+		if time.Now().Add(leeway).Before(nbf) {
+			return fmt.Errorf("令牌尚未生效")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,55 @@
+package token
+
+import (
+	"fmt"
+
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/grayscalecloud/hertzcommon/hdmodel"
+	"github.com/grayscalecloud/hertzcommon/kvconfig"
+)
+
+// NewWatchedVerifier 从配置中心读取 pasetopub 创建校验器，并在配置变化时热更新公钥集
+func NewWatchedVerifier(f *kvconfig.ConfigFactory, group string, opts *VerifierOptions) (*Verifier, error) {
+	cfg, err := f.GetPasetoPubConfig(group)
+	if err != nil {
+		return nil, err
+	}
+	v, err := NewVerifier(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = f.WatchPasetoPubConfig(group, func(cfg *hdmodel.PasetoConfig) {
+		if err := v.Update(cfg); err != nil {
+			hlog.Errorf("更新 Paseto 公钥集失败，继续使用原公钥集: %v", err)
+			return
+		}
+		hlog.Infof("Paseto 公钥集已更新 [group: %s]", group)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("监听 Paseto 公钥配置失败: %w", err)
+	}
+	return v, nil
+}
+
+// NewWatchedIssuer 从配置中心读取 pasetosecret 创建签发器，并在配置变化时热更新私钥集
+func NewWatchedIssuer(f *kvconfig.ConfigFactory, group string, opts *IssuerOptions) (*Issuer, error) {
+	cfg, err := f.GetPasetoSecretConfig(group)
+	if err != nil {
+		return nil, err
+	}
+	i, err := NewIssuer(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = f.WatchPasetoSecretConfig(group, func(cfg *hdmodel.PasetoSecretConfig) {
+		if err := i.Update(cfg); err != nil {
+			hlog.Errorf("更新 Paseto 私钥集失败，继续使用原私钥集: %v", err)
+			return
+		}
+		hlog.Infof("Paseto 私钥集已更新 [group: %s]", group)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("监听 Paseto 私钥配置失败: %w", err)
+	}
+	return i, nil
+}